@@ -0,0 +1,157 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+/*
+Srt-trace decodes SRT control traffic to diagnose connections that fail
+or misbehave.
+
+Usage:
+
+	srt-trace [flags] -listen addr -target addr
+	srt-trace [flags] -r file.pcap
+
+In relay mode srt-trace listens on a UDP address, forwards every datagram
+to the target SRT listener and traces the packets flowing both ways.
+Point the caller at the listen address instead of the listener.
+
+In capture mode srt-trace reads a capture file in the libpcap format, as
+written by tcpdump -w, and traces the UDP datagrams it contains.
+
+Handshakes are printed with their phase, stream ID, SRT version and
+flags, encryption and reject reason. Shutdown and other control packets
+are printed as they come; ACKs, NAKs and data packets are summarised as
+per second rates for every flow. The flags are:
+
+	-listen addr
+		address the relay listens on
+	-target addr
+		address of the SRT listener to relay to
+	-r file
+		read packets from a pcap file instead of relaying
+	-port n
+		only trace datagrams from or to port n of a capture
+	-json
+		print one JSON object per line
+	-v
+		print every packet, including data, ACK and keepalive packets
+	-interval d
+		print rates every d; 0 disables them (default 1s)
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+var errClosed = errors.New("use of closed relay")
+
+func main() {
+	listen := flag.String("listen", "", "address the relay listens on")
+	target := flag.String("target", "", "address of the SRT listener to relay to")
+	file := flag.String("r", "", "read packets from a pcap file instead of relaying")
+	port := flag.Int("port", 0, "only trace datagrams from or to `port` of a capture")
+	jsonOutput := flag.Bool("json", false, "print one JSON object per line")
+	verbose := flag.Bool("v", false, "print every packet")
+	interval := flag.Duration("interval", time.Second, "print rates every `interval`; 0 disables them")
+	flag.Parse()
+
+	tr := newTracer(os.Stdout, *jsonOutput, *verbose, *interval)
+	var err error
+	switch {
+	case *file != "":
+		err = tracePcap(tr, *file, *port)
+	case *listen != "" && *target != "":
+		err = traceRelay(tr, *listen, *target)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "srt-trace:", err)
+		os.Exit(1)
+	}
+}
+
+// udpAddr resolves an SRT address to the UDP address it is carried on.
+func udpAddr(address string) (*net.UDPAddr, error) {
+	a, err := srt.ResolveSRTAddr("srt", address)
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: a.IP, Port: a.Port, Zone: a.Zone}, nil
+}
+
+func traceRelay(tr *tracer, listen, target string) error {
+	laddr, err := udpAddr(listen)
+	if err != nil {
+		return err
+	}
+	raddr, err := udpAddr(target)
+	if err != nil {
+		return err
+	}
+	r, err := newRelay(laddr, raddr, tr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "relaying %v -> %v\n", r.Addr(), raddr)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	var tick <-chan time.Time
+	if tr.interval > 0 {
+		t := time.NewTicker(tr.interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case now := <-tick:
+			tr.flush(now)
+		case <-sig:
+			err := r.Close()
+			tr.flush(time.Now())
+			return err
+		}
+	}
+}
+
+func tracePcap(tr *tracer, name string, port int) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pr, err := newPcapReader(f)
+	if err != nil {
+		return err
+	}
+	var last time.Time
+	for {
+		d, err := pr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if port != 0 && d.src.Port != port && d.dst.Port != port {
+			continue
+		}
+		tr.handle(d.time, d.src, d.dst, d.payload)
+		last = d.time
+	}
+	if !last.IsZero() {
+		tr.flush(last)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Link types of the pcap file format.
+const (
+	linkNull      = 0
+	linkEthernet  = 1
+	linkRaw       = 101
+	linkLinuxSLL  = 113
+	linkIPv4      = 228
+	linkIPv6      = 229
+	ipProtoUDP    = 17
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+)
+
+var errNotUDP = errors.New("not a udp datagram")
+
+// A pcapReader reads UDP datagrams from a capture file in the classic
+// libpcap format.
+type pcapReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	nano  bool
+	link  uint32
+	hdr   [16]byte
+	buf   []byte
+}
+
+// A datagram is a UDP payload read from a capture.
+type datagram struct {
+	time     time.Time
+	src, dst *net.UDPAddr
+	payload  []byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var h [24]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, fmt.Errorf("pcap: reading file header: %v", err)
+	}
+	pr := &pcapReader{r: r}
+	switch binary.LittleEndian.Uint32(h[0:]) {
+	case 0xa1b2c3d4:
+		pr.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		pr.order, pr.nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		pr.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		pr.order, pr.nano = binary.BigEndian, true
+	case 0x0a0d0d0a:
+		return nil, errors.New("pcap: pcapng files are not supported; convert with editcap -F pcap")
+	default:
+		return nil, errors.New("pcap: not a capture file")
+	}
+	pr.link = pr.order.Uint32(h[20:])
+	switch pr.link {
+	case linkNull, linkEthernet, linkRaw, linkLinuxSLL, linkIPv4, linkIPv6:
+	default:
+		return nil, fmt.Errorf("pcap: unsupported link type %d", pr.link)
+	}
+	return pr, nil
+}
+
+// next returns the next UDP datagram of the capture. It returns io.EOF at
+// the end of the file.
+func (pr *pcapReader) next() (*datagram, error) {
+	for {
+		if _, err := io.ReadFull(pr.r, pr.hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errors.New("pcap: truncated record header")
+			}
+			return nil, err
+		}
+		sec := pr.order.Uint32(pr.hdr[0:])
+		frac := pr.order.Uint32(pr.hdr[4:])
+		n := pr.order.Uint32(pr.hdr[8:])
+		if n > 1<<18 {
+			return nil, fmt.Errorf("pcap: record of %d bytes", n)
+		}
+		if cap(pr.buf) < int(n) {
+			pr.buf = make([]byte, n)
+		}
+		b := pr.buf[:n]
+		if _, err := io.ReadFull(pr.r, b); err != nil {
+			return nil, errors.New("pcap: truncated record")
+		}
+		d, err := pr.decode(b)
+		if err == errNotUDP {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if pr.nano {
+			d.time = time.Unix(int64(sec), int64(frac))
+		} else {
+			d.time = time.Unix(int64(sec), int64(frac)*1000)
+		}
+		return d, nil
+	}
+}
+
+// decode strips the link, network and transport headers of a frame.
+func (pr *pcapReader) decode(b []byte) (*datagram, error) {
+	var etherType uint16
+	switch pr.link {
+	case linkNull:
+		if len(b) < 4 {
+			return nil, errNotUDP
+		}
+		// The address family is in the byte order of the capturing host.
+		family := binary.LittleEndian.Uint32(b)
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(b)
+		}
+		switch family {
+		case 2:
+			etherType = etherTypeIPv4
+		case 10, 24, 28, 30:
+			etherType = etherTypeIPv6
+		default:
+			return nil, errNotUDP
+		}
+		b = b[4:]
+	case linkEthernet:
+		if len(b) < 14 {
+			return nil, errNotUDP
+		}
+		etherType = binary.BigEndian.Uint16(b[12:])
+		b = b[14:]
+		for etherType == etherTypeVLAN && len(b) >= 4 {
+			etherType = binary.BigEndian.Uint16(b[2:])
+			b = b[4:]
+		}
+	case linkLinuxSLL:
+		if len(b) < 16 {
+			return nil, errNotUDP
+		}
+		etherType = binary.BigEndian.Uint16(b[14:])
+		b = b[16:]
+	case linkRaw, linkIPv4, linkIPv6:
+		if len(b) == 0 {
+			return nil, errNotUDP
+		}
+		switch b[0] >> 4 {
+		case 4:
+			etherType = etherTypeIPv4
+		case 6:
+			etherType = etherTypeIPv6
+		}
+	}
+	var src, dst net.IP
+	switch etherType {
+	case etherTypeIPv4:
+		if len(b) < 20 || b[0]>>4 != 4 {
+			return nil, errNotUDP
+		}
+		ihl := int(b[0]&0xf) * 4
+		fragOffset := binary.BigEndian.Uint16(b[6:]) & 0x1fff
+		if ihl < 20 || len(b) < ihl || b[9] != ipProtoUDP || fragOffset != 0 {
+			return nil, errNotUDP
+		}
+		src, dst = net.IP(b[12:16]), net.IP(b[16:20])
+		b = b[ihl:]
+	case etherTypeIPv6:
+		if len(b) < 40 || b[0]>>4 != 6 || b[6] != ipProtoUDP {
+			return nil, errNotUDP
+		}
+		src, dst = net.IP(b[8:24]), net.IP(b[24:40])
+		b = b[40:]
+	default:
+		return nil, errNotUDP
+	}
+	if len(b) < 8 {
+		return nil, errNotUDP
+	}
+	n := int(binary.BigEndian.Uint16(b[4:]))
+	if n < 8 || n > len(b) {
+		// Snapped or malformed; keep what was captured.
+		n = len(b)
+	}
+	return &datagram{
+		src:     &net.UDPAddr{IP: append(net.IP(nil), src...), Port: int(binary.BigEndian.Uint16(b[0:]))},
+		dst:     &net.UDPAddr{IP: append(net.IP(nil), dst...), Port: int(binary.BigEndian.Uint16(b[2:]))},
+		payload: b[8:n],
+	}, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const maxDatagram = 65536
+
+// sessionIdleTimeout is how long a caller may stay silent, in both
+// directions, before its session is closed. SRT peers send keepalives
+// every second, so only callers that are gone reach it.
+const sessionIdleTimeout = 30 * time.Second
+
+// A relay forwards UDP datagrams between callers and a target and hands
+// every datagram to a tracer. Each caller gets its own socket towards the
+// target so that the target sees distinct peers.
+type relay struct {
+	conn   *net.UDPConn
+	target *net.UDPAddr
+	tr     *tracer
+
+	// idle is the idle timeout of sessions.
+	idle time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*relaySession
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// A relaySession is the socket of one caller towards the target.
+type relaySession struct {
+	conn *net.UDPConn
+	last int64 // time of the last datagram in UnixNano, accessed atomically
+}
+
+func (s *relaySession) touch(t time.Time) {
+	atomic.StoreInt64(&s.last, t.UnixNano())
+}
+
+func (s *relaySession) lastActive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.last))
+}
+
+func newRelay(laddr, target *net.UDPAddr, tr *tracer) (*relay, error) {
+	c, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	r := &relay{
+		conn:     c,
+		target:   target,
+		tr:       tr,
+		idle:     sessionIdleTimeout,
+		sessions: make(map[string]*relaySession),
+	}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+// Addr returns the address callers should connect to.
+func (r *relay) Addr() *net.UDPAddr {
+	return r.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (r *relay) serve() {
+	defer r.wg.Done()
+	b := make([]byte, maxDatagram)
+	for {
+		n, from, err := r.conn.ReadFromUDP(b)
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		s, err := r.session(from, now)
+		if err != nil {
+			continue
+		}
+		r.tr.handle(now, from, r.target, b[:n])
+		s.conn.Write(b[:n])
+	}
+}
+
+// session returns the session of the caller at from, creating it if
+// needed, and marks it active at now. It does so under r.mu so that expire
+// cannot close the session before the datagram is written to it.
+func (r *relay) session(from *net.UDPAddr, now time.Time) (*relaySession, error) {
+	key := from.String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[key]; ok {
+		s.touch(now)
+		return s, nil
+	}
+	if r.closed {
+		return nil, errClosed
+	}
+	c, err := net.DialUDP("udp", nil, r.target)
+	if err != nil {
+		return nil, err
+	}
+	s := &relaySession{conn: c}
+	s.touch(now)
+	r.sessions[key] = s
+	r.wg.Add(1)
+	go r.reply(s, key, from)
+	return s, nil
+}
+
+// reply forwards the datagrams of the target back to the caller at to,
+// until the relay is closed or the session is idle for r.idle.
+func (r *relay) reply(s *relaySession, key string, to *net.UDPAddr) {
+	defer r.wg.Done()
+	b := make([]byte, maxDatagram)
+	for {
+		s.conn.SetReadDeadline(s.lastActive().Add(r.idle))
+		n, err := s.conn.Read(b)
+		if err != nil {
+			if r.isClosed() {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() && r.expire(s, key) {
+				return
+			}
+			// ICMP port unreachable while the target restarts, or
+			// datagrams from the caller extended the session.
+			continue
+		}
+		now := time.Now()
+		s.touch(now)
+		r.tr.handle(now, r.target, to, b[:n])
+		r.conn.WriteToUDP(b[:n], to)
+	}
+}
+
+// expire closes and forgets s if it has been idle for r.idle.
+func (r *relay) expire(s *relaySession, key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(s.lastActive()) < r.idle {
+		return false
+	}
+	if r.sessions[key] == s {
+		delete(r.sessions, key)
+	}
+	s.conn.Close()
+	return true
+}
+
+func (r *relay) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Close stops the relay and waits for its goroutines.
+func (r *relay) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errClosed
+	}
+	r.closed = true
+	err := r.conn.Close()
+	for _, s := range r.sessions {
+		s.conn.Close()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,358 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/srt/packet"
+)
+
+// An event is one line of output.
+type event struct {
+	Time      time.Time      `json:"time"`
+	Src       string         `json:"src"`
+	Dst       string         `json:"dst"`
+	Type      string         `json:"type"`
+	SocketID  uint32         `json:"socket_id"`
+	Handshake *handshakeInfo `json:"handshake,omitempty"`
+	ACK       *ackInfo       `json:"ack,omitempty"`
+	NAK       *nakInfo       `json:"nak,omitempty"`
+	Stats     *statsInfo     `json:"stats,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type handshakeInfo struct {
+	Version    uint32 `json:"version"`
+	Phase      string `json:"phase"`
+	Reject     string `json:"reject,omitempty"`
+	SocketID   uint32 `json:"socket_id"`
+	Cookie     uint32 `json:"cookie,omitempty"`
+	PeerIP     string `json:"peer_ip"`
+	MTU        uint32 `json:"mtu"`
+	StreamID   string `json:"stream_id,omitempty"`
+	Encryption string `json:"encryption,omitempty"`
+	KMState    string `json:"km_state,omitempty"`
+	SRTVersion string `json:"srt_version,omitempty"`
+	Flags      string `json:"flags,omitempty"`
+	RecvDelay  uint16 `json:"recv_delay_ms,omitempty"`
+	SendDelay  uint16 `json:"send_delay_ms,omitempty"`
+	Congestion string `json:"congestion,omitempty"`
+	Filter     string `json:"filter,omitempty"`
+}
+
+type ackInfo struct {
+	Seq  uint32  `json:"seq"`
+	RTT  float64 `json:"rtt_ms"`
+	Rate uint32  `json:"rate_pps,omitempty"`
+}
+
+type nakInfo struct {
+	Lost   int    `json:"lost"`
+	Ranges string `json:"ranges"`
+}
+
+// statsInfo holds the per second rates of a flow over an interval.
+type statsInfo struct {
+	Interval float64 `json:"interval_s"`
+	Data     float64 `json:"data_pps"`
+	Rexmit   float64 `json:"rexmit_pps"`
+	ACK      float64 `json:"ack_ps"`
+	NAK      float64 `json:"nak_ps"`
+	Lost     float64 `json:"lost_pps"`
+	RTT      float64 `json:"rtt_ms,omitempty"`
+}
+
+// counters accumulate the traffic of a flow between two stats events.
+type counters struct {
+	data, rexmit, ack, nak, lost int
+	rtt                          uint32
+}
+
+// A tracer decodes SRT packets and writes events.
+type tracer struct {
+	w        io.Writer
+	json     bool
+	verbose  bool
+	interval time.Duration
+
+	mu    sync.Mutex
+	flows map[string]*counters
+	since time.Time
+}
+
+func newTracer(w io.Writer, jsonOutput, verbose bool, interval time.Duration) *tracer {
+	return &tracer{
+		w:        w,
+		json:     jsonOutput,
+		verbose:  verbose,
+		interval: interval,
+		flows:    make(map[string]*counters),
+	}
+}
+
+// handle traces a UDP payload sent from src to dst at t.
+func (tr *tracer) handle(t time.Time, src, dst net.Addr, b []byte) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.since.IsZero() {
+		tr.since = t
+	}
+	if tr.interval > 0 && t.Sub(tr.since) >= tr.interval {
+		tr.flushLocked(t)
+	}
+	key := src.String() + " -> " + dst.String()
+	c := tr.flows[key]
+	if c == nil {
+		c = new(counters)
+		tr.flows[key] = c
+	}
+	e := event{Time: t, Src: src.String(), Dst: dst.String()}
+	p, err := packet.Parse(b)
+	if err != nil {
+		if tr.verbose {
+			e.Type = "invalid"
+			e.Error = err.Error()
+			tr.emit(&e)
+		}
+		return
+	}
+	if dp, ok := p.(*packet.DataPacket); ok {
+		c.data++
+		if dp.Retransmitted {
+			c.rexmit++
+		}
+		if tr.verbose {
+			e.Type = "data"
+			e.SocketID = dp.DestinationSocketID
+			tr.emit(&e)
+		}
+		return
+	}
+	cp := p.(*packet.ControlPacket)
+	e.Type = cp.Type.String()
+	e.SocketID = cp.DestinationSocketID
+	quiet := false
+	switch cp.Type {
+	case packet.TypeHandshake:
+		h, err := cp.Handshake()
+		if err != nil {
+			e.Error = err.Error()
+			break
+		}
+		e.Handshake = describeHandshake(h)
+	case packet.TypeACK:
+		c.ack++
+		quiet = true
+		a, err := cp.ACK()
+		if err != nil {
+			e.Error = err.Error()
+			break
+		}
+		if a.Fields != packet.ACKLight {
+			c.rtt = a.RTT
+		}
+		e.ACK = &ackInfo{Seq: a.LastACKPacketSequenceNumber, RTT: float64(a.RTT) / 1000, Rate: a.PacketsReceivingRate}
+	case packet.TypeNAK:
+		c.nak++
+		quiet = true
+		n, err := cp.NAK()
+		if err != nil {
+			e.Error = err.Error()
+			break
+		}
+		c.lost += n.Lost()
+		e.NAK = &nakInfo{Lost: n.Lost(), Ranges: formatLosses(n.Losses)}
+	case packet.TypeKeepalive, packet.TypeACKACK:
+		quiet = true
+	case packet.TypeUserDefined:
+		ext, _ := cp.Extension()
+		e.Type = "user/" + ext.Type.String()
+		e.Handshake = describeExtensions(new(handshakeInfo), []packet.Extension{ext})
+	}
+	if !quiet || tr.verbose || e.Error != "" {
+		tr.emit(&e)
+	}
+}
+
+// flush writes the stats of all flows seen since the last flush.
+func (tr *tracer) flush(t time.Time) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.flushLocked(t)
+}
+
+func (tr *tracer) flushLocked(t time.Time) {
+	d := t.Sub(tr.since).Seconds()
+	if d <= 0 {
+		return
+	}
+	keys := make([]string, 0, len(tr.flows))
+	for k := range tr.flows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c := tr.flows[k]
+		if *c == (counters{}) {
+			continue
+		}
+		addrs := strings.SplitN(k, " -> ", 2)
+		tr.emit(&event{
+			Time: t,
+			Src:  addrs[0],
+			Dst:  addrs[1],
+			Type: "stats",
+			Stats: &statsInfo{
+				Interval: d,
+				Data:     float64(c.data) / d,
+				Rexmit:   float64(c.rexmit) / d,
+				ACK:      float64(c.ack) / d,
+				NAK:      float64(c.nak) / d,
+				Lost:     float64(c.lost) / d,
+				RTT:      float64(c.rtt) / 1000,
+			},
+		})
+		*c = counters{}
+	}
+	tr.since = t
+}
+
+func describeHandshake(h *packet.Handshake) *handshakeInfo {
+	hi := &handshakeInfo{
+		Version:  h.Version,
+		Phase:    h.Type.String(),
+		SocketID: h.SocketID,
+		Cookie:   h.SYNCookie,
+		PeerIP:   h.PeerAddr().String(),
+		MTU:      h.MTU,
+	}
+	if h.Type.IsRejection() {
+		hi.Phase = "rejection"
+		hi.Reject = h.Type.RejectReason().String()
+	}
+	if h.Version >= 5 && h.Type == packet.HandshakeInduction && h.EncryptionField != 0 {
+		hi.Encryption = fmt.Sprintf("aes-%d", h.KeyLength()*8)
+	}
+	return describeExtensions(hi, h.Extensions)
+}
+
+func describeExtensions(hi *handshakeInfo, exts []packet.Extension) *handshakeInfo {
+	for _, e := range exts {
+		switch e.Type {
+		case packet.ExtensionHSReq, packet.ExtensionHSRsp:
+			hs, err := e.HS()
+			if err != nil {
+				continue
+			}
+			hi.SRTVersion = hs.VersionString()
+			hi.Flags = hs.Flags.String()
+			hi.RecvDelay = hs.RecvTSBPDDelay
+			hi.SendDelay = hs.SendTSBPDDelay
+		case packet.ExtensionKMReq, packet.ExtensionKMRsp:
+			if s, ok := e.KMState(); ok {
+				hi.KMState = s.String()
+				continue
+			}
+			km, err := e.KeyMaterial()
+			if err != nil {
+				continue
+			}
+			hi.Encryption = fmt.Sprintf("aes-%d", km.KeyLength*8)
+			if e.Type == packet.ExtensionKMRsp {
+				hi.KMState = packet.KMStateSecured.String()
+			}
+		case packet.ExtensionSID:
+			hi.StreamID = e.Text()
+		case packet.ExtensionCongestion:
+			hi.Congestion = e.Text()
+		case packet.ExtensionFilter:
+			hi.Filter = e.Text()
+		}
+	}
+	return hi
+}
+
+func formatLosses(losses []packet.LossRange) string {
+	s := make([]string, len(losses))
+	for i, r := range losses {
+		if r.From == r.To {
+			s[i] = fmt.Sprint(r.From)
+		} else {
+			s[i] = fmt.Sprintf("%d-%d", r.From, r.To)
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (tr *tracer) emit(e *event) {
+	if tr.json {
+		b, _ := json.Marshal(e)
+		tr.w.Write(append(b, '\n'))
+		return
+	}
+	fmt.Fprintln(tr.w, e.String())
+}
+
+// String formats e as a line of text.
+func (e *event) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s -> %s %s", e.Time.Format("15:04:05.000000"), e.Src, e.Dst, e.Type)
+	if e.Type != "stats" {
+		fmt.Fprintf(&b, " dst=%#x", e.SocketID)
+	}
+	if h := e.Handshake; h != nil {
+		if h.Phase != "" {
+			fmt.Fprintf(&b, " v%d %s", h.Version, h.Phase)
+		}
+		if h.Reject != "" {
+			fmt.Fprintf(&b, " reason=%s", h.Reject)
+		}
+		if h.Phase != "" {
+			fmt.Fprintf(&b, " socket=%#x cookie=%#x peer=%s mtu=%d", h.SocketID, h.Cookie, h.PeerIP, h.MTU)
+		}
+		if h.StreamID != "" {
+			fmt.Fprintf(&b, " sid=%q", h.StreamID)
+		}
+		if h.SRTVersion != "" {
+			fmt.Fprintf(&b, " srt=%s flags=%s latency=%d/%dms", h.SRTVersion, h.Flags, h.RecvDelay, h.SendDelay)
+		}
+		if h.Encryption != "" {
+			fmt.Fprintf(&b, " enc=%s", h.Encryption)
+		}
+		if h.KMState != "" {
+			fmt.Fprintf(&b, " km=%s", h.KMState)
+		}
+		if h.Congestion != "" {
+			fmt.Fprintf(&b, " congestion=%s", h.Congestion)
+		}
+		if h.Filter != "" {
+			fmt.Fprintf(&b, " filter=%q", h.Filter)
+		}
+	}
+	if a := e.ACK; a != nil {
+		fmt.Fprintf(&b, " seq=%d rtt=%.3fms", a.Seq, a.RTT)
+	}
+	if n := e.NAK; n != nil {
+		fmt.Fprintf(&b, " lost=%d [%s]", n.Lost, n.Ranges)
+	}
+	if s := e.Stats; s != nil {
+		fmt.Fprintf(&b, " data=%.1f/s rexmit=%.1f/s ack=%.1f/s nak=%.1f/s lost=%.1f/s",
+			s.Data, s.Rexmit, s.ACK, s.NAK, s.Lost)
+		if s.RTT != 0 {
+			fmt.Fprintf(&b, " rtt=%.3fms", s.RTT)
+		}
+	}
+	if e.Error != "" {
+		fmt.Fprintf(&b, " error=%q", e.Error)
+	}
+	return b.String()
+}
@@ -0,0 +1,347 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srt"
+	"github.com/openfresh/gosrt/srt/packet"
+)
+
+var (
+	caller   = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 40000}
+	listener = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 9000}
+)
+
+func mustMarshal(t *testing.T, p packet.Packet) []byte {
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func handshakePacket(t *testing.T, h *packet.Handshake) []byte {
+	cif, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mustMarshal(t, &packet.ControlPacket{Type: packet.TypeHandshake, CIF: cif})
+}
+
+func conclusion(t *testing.T) []byte {
+	km := &packet.KeyMaterial{
+		Version:    packet.KMVersion,
+		PacketType: packet.KMPacketType,
+		Sign:       packet.KMSign,
+		Keys:       packet.KeyEven,
+		Cipher:     packet.CipherAESCTR,
+		SE:         2,
+		KeyLength:  16,
+		Salt:       make([]byte, 16),
+		WrappedKey: make([]byte, 24),
+	}
+	kmreq, err := km.Extension(packet.ExtensionKMReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := &packet.HSExtension{
+		Version:        0x010401,
+		Flags:          packet.FlagTSBPDSnd | packet.FlagTSBPDRcv | packet.FlagCrypt,
+		RecvTSBPDDelay: 120,
+		SendTSBPDDelay: 120,
+	}
+	return handshakePacket(t, &packet.Handshake{
+		Version:        5,
+		ExtensionField: uint16(packet.FlagHSReq | packet.FlagKMReq | packet.FlagConfig),
+		MTU:            1500,
+		Type:           packet.HandshakeConclusion,
+		SocketID:       0x1234,
+		SYNCookie:      0x5678,
+		Extensions: []packet.Extension{
+			hs.Extension(packet.ExtensionHSReq),
+			kmreq,
+			packet.TextExtension(packet.ExtensionSID, "#!::r=live,m=publish"),
+		},
+	})
+}
+
+func TestTraceHandshake(t *testing.T) {
+	var buf bytes.Buffer
+	tr := newTracer(&buf, false, false, 0)
+	tr.handle(time.Now(), caller, listener, conclusion(t))
+	out := buf.String()
+	for _, want := range []string{
+		"v5 conclusion",
+		"socket=0x1234",
+		`sid="#!::r=live,m=publish"`,
+		"srt=1.4.1 flags=tsbpdsnd|tsbpdrcv|crypt latency=120/120ms",
+		"enc=aes-128",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q does not contain %q", out, want)
+		}
+	}
+}
+
+func TestTraceRejection(t *testing.T) {
+	var buf bytes.Buffer
+	tr := newTracer(&buf, true, false, 0)
+	tr.handle(time.Now(), listener, caller, handshakePacket(t, &packet.Handshake{
+		Version: 5,
+		Type:    packet.RejectionType(packet.RejectBadSecret),
+	}))
+	var e event
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != "handshake" || e.Handshake == nil || e.Handshake.Phase != "rejection" || e.Handshake.Reject != "badsecret" {
+		t.Errorf("unexpected event %s", buf.Bytes())
+	}
+}
+
+func TestTraceRates(t *testing.T) {
+	var buf bytes.Buffer
+	tr := newTracer(&buf, true, false, time.Second)
+	start := time.Unix(1000, 0)
+	ack := &packet.ACK{LastACKPacketSequenceNumber: 1, RTT: 20000}
+	cif, _ := ack.MarshalBinary()
+	nak := &packet.NAK{Losses: []packet.LossRange{{From: 5, To: 9}}}
+	nakCIF, _ := nak.MarshalBinary()
+	for i := 0; i < 10; i++ {
+		now := start.Add(time.Duration(i) * 100 * time.Millisecond)
+		tr.handle(now, caller, listener, mustMarshal(t, &packet.DataPacket{SequenceNumber: uint32(i)}))
+		tr.handle(now, listener, caller, mustMarshal(t, &packet.ControlPacket{Type: packet.TypeACK, CIF: cif}))
+	}
+	tr.handle(start, listener, caller, mustMarshal(t, &packet.ControlPacket{Type: packet.TypeNAK, CIF: nakCIF}))
+	if buf.Len() != 0 {
+		t.Fatalf("quiet packets printed: %s", buf.Bytes())
+	}
+	tr.flush(start.Add(time.Second))
+
+	stats := make(map[string]*statsInfo)
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		stats[e.Src] = e.Stats
+	}
+	if s := stats[caller.String()]; s == nil || s.Data != 10 {
+		t.Errorf("caller stats %+v; want 10 data packets per second", s)
+	}
+	if s := stats[listener.String()]; s == nil || s.ACK != 10 || s.NAK != 1 || s.Lost != 5 || s.RTT != 20 {
+		t.Errorf("listener stats %+v", s)
+	}
+}
+
+// writePcap writes an Ethernet capture of UDP datagrams.
+func writePcap(t *testing.T, datagrams []datagram) string {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	hdr := make([]byte, 24)
+	le.PutUint32(hdr[0:], 0xa1b2c3d4)
+	le.PutUint16(hdr[4:], 2)
+	le.PutUint16(hdr[6:], 4)
+	le.PutUint32(hdr[16:], 65535)
+	le.PutUint32(hdr[20:], linkEthernet)
+	b.Write(hdr)
+	for _, d := range datagrams {
+		frame := make([]byte, 14+20+8, 14+20+8+len(d.payload))
+		binary.BigEndian.PutUint16(frame[12:], etherTypeIPv4)
+		ip := frame[14:]
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+8+len(d.payload)))
+		ip[8] = 64
+		ip[9] = ipProtoUDP
+		copy(ip[12:], d.src.IP.To4())
+		copy(ip[16:], d.dst.IP.To4())
+		udp := ip[20:]
+		binary.BigEndian.PutUint16(udp[0:], uint16(d.src.Port))
+		binary.BigEndian.PutUint16(udp[2:], uint16(d.dst.Port))
+		binary.BigEndian.PutUint16(udp[4:], uint16(8+len(d.payload)))
+		frame = append(frame, d.payload...)
+
+		rec := make([]byte, 16)
+		le.PutUint32(rec[0:], uint32(d.time.Unix()))
+		le.PutUint32(rec[4:], uint32(d.time.Nanosecond()/1000))
+		le.PutUint32(rec[8:], uint32(len(frame)))
+		le.PutUint32(rec[12:], uint32(len(frame)))
+		b.Write(rec)
+		b.Write(frame)
+	}
+	dir, err := ioutil.TempDir("", "srt-trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "trace.pcap")
+	if err := ioutil.WriteFile(name, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestTracePcap(t *testing.T) {
+	other := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 53}
+	name := writePcap(t, []datagram{
+		{time: time.Unix(1000, 0), src: caller, dst: listener, payload: conclusion(t)},
+		{time: time.Unix(1000, 1000), src: caller, dst: other, payload: []byte("not srt")},
+		{time: time.Unix(1001, 0), src: listener, dst: caller, payload: mustMarshal(t, &packet.ControlPacket{Type: packet.TypeShutdown})},
+	})
+	defer os.RemoveAll(filepath.Dir(name))
+
+	var buf bytes.Buffer
+	if err := tracePcap(newTracer(&buf, false, false, 0), name, listener.Port); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines; want 2:\n%s", len(lines), buf.String())
+	}
+	if want := "10.0.0.1:40000 -> 10.0.0.2:9000 handshake"; !strings.Contains(lines[0], want) {
+		t.Errorf("%q does not contain %q", lines[0], want)
+	}
+	if want := "10.0.0.2:9000 -> 10.0.0.1:40000 shutdown"; !strings.Contains(lines[1], want) {
+		t.Errorf("%q does not contain %q", lines[1], want)
+	}
+}
+
+func TestPcapNotACapture(t *testing.T) {
+	if _, err := newPcapReader(bytes.NewReader(make([]byte, 24))); err == nil {
+		t.Error("zero header accepted")
+	}
+	if _, err := newPcapReader(bytes.NewReader([]byte{0x0a, 0x0d, 0x0d, 0x0a})); err == nil {
+		t.Error("short header accepted")
+	}
+}
+
+func TestTraceRelay(t *testing.T) {
+	ln, err := srt.Listen("srt", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	target, err := udpAddr(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	r, err := newRelay(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, target, newTracer(&buf, false, false, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	ctx := srt.WithOptions(context.Background(), srt.Options("streamid", "trace-me"))
+	var d srt.Dialer
+	c, err := d.DialContext(ctx, "srt", r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	// Close waits for the relay goroutines, after which buf is
+	// no longer written.
+	r.Close()
+
+	out := buf.String()
+	for _, want := range []string{"induction", "conclusion", `sid="trace-me"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRelayIdleSession(t *testing.T) {
+	echo, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		b := make([]byte, maxDatagram)
+		for {
+			n, from, err := echo.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+			echo.WriteToUDP(b[:n], from)
+		}
+	}()
+
+	r, err := newRelay(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, echo.LocalAddr().(*net.UDPAddr), newTracer(ioutil.Discard, false, false, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.mu.Lock()
+	r.idle = 50 * time.Millisecond
+	r.mu.Unlock()
+
+	c, err := net.DialUDP("udp", nil, r.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 16)
+	if n, err := c.Read(b); err != nil || string(b[:n]) != "ping" {
+		t.Fatalf("got %q, %v", b[:n], err)
+	}
+
+	for deadline := time.Now().Add(time.Second); ; {
+		r.mu.Lock()
+		n := len(r.sessions)
+		r.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d sessions left after the idle timeout", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRelaySessionRevived(t *testing.T) {
+	c, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	from := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+	s := &relaySession{conn: c}
+	s.touch(time.Now().Add(-time.Hour))
+	r := &relay{idle: time.Minute, sessions: map[string]*relaySession{from.String(): s}}
+
+	// A datagram from the caller of an idle session revives it before
+	// the reply side can expire it.
+	got, err := r.session(from, time.Now())
+	if err != nil || got != s {
+		t.Fatalf("got %p, %v; want the existing session", got, err)
+	}
+	if r.expire(s, from.String()) {
+		t.Error("session expired after a datagram revived it")
+	}
+}
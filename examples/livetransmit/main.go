@@ -12,11 +12,10 @@ import (
 	"os"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/openfresh/gosrt/srt"
-	"github.com/openfresh/gosrt/srtapi"
+	"github.com/openfresh/gosrt/srt/auth"
 )
 
 func main() {
@@ -44,40 +43,11 @@ func main() {
 
 	defer srt.Shutdown()
 	ctx := srt.WithOptions(context.Background(), srt.Options("payloadsize", strconv.Itoa(chunksize)))
-	ctx = srt.WithListenCallback(ctx, func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamID string) int {
-		passwd := map[string]string{
-			"admin": "thelocalmanager",
-			"user":  "verylongpassword",
-		}
-		username := ""
-		if err != nil {
-			log.Fatal(err)
-		}
-		if strings.HasPrefix(streamID, "#!::") {
-			items := strings.Split(streamID[4:], ",")
-			for i := range items {
-				kv := strings.Split(items[i], "=")
-				if len(kv) == 2 && kv[0] == "u" {
-					username = kv[1]
-				}
-			}
-			if username == "" {
-				fmt.Println("USER NOT FOUND")
-				return -1
-			}
-		} else {
-			// By default the whole streamid is username
-			username = streamID
-		}
-		fmt.Printf("username is %s\n", username)
-
-		expPw, ok := passwd[username]
-		if ok {
-			fmt.Printf("setting password %s\n", expPw)
-			srtapi.SetsockflagString(ns, int(srtapi.OptionPassphrase), expPw)
-		}
-		return 0
-	})
+	users, err := loadUsers(os.Getenv("USERS_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx = srt.WithListenCallback(ctx, auth.ListenCallback(users, nil))
 	fmt.Println("listen")
 	l, err := srt.ListenContext(ctx, "srt", ":"+sport)
 	if err != nil {
@@ -98,13 +68,12 @@ func main() {
 		}
 		go func(sc net.Conn, taddr string) {
 			defer sc.Close()
-			var d srt.Dialer
 			fmt.Printf("connecting: %s\n", taddr)
-			tc, err := d.DialContext(ctx, "srt", taddr)
-			if err != nil {
-				log.Fatal(err)
-			}
-			fmt.Printf("connected: %s\n", taddr)
+			tc := srt.NewReconnectingConn(ctx, nil, "srt", taddr)
+			defer tc.Close()
+			tc.Policy = srt.OutageDrop
+			tc.OnConnect = func(net.Conn) { fmt.Printf("connected: %s\n", taddr) }
+			tc.OnDisconnect = func(err error) { fmt.Printf("disconnected: %s: %v\n", taddr, err) }
 			counter := 0
 			for {
 				b := make([]byte, chunksize)
@@ -116,7 +85,9 @@ func main() {
 
 				if statsReport > 0 && (counter%statsReport) == statsReport-1 {
 					printSrtStats(sc)
-					printSrtStats(tc)
+					if c := tc.Conn(); c != nil {
+						printSrtStats(c)
+					}
 				}
 				counter++
 			}
@@ -125,6 +96,18 @@ func main() {
 	}
 }
 
+// loadUsers reads the users file name, or returns the default users if
+// name is empty.
+func loadUsers(name string) (*auth.Users, error) {
+	if name != "" {
+		return auth.LoadUsers(name)
+	}
+	return auth.NewUsers(
+		auth.User{Name: "admin", Passphrase: "thelocalmanager"},
+		auth.User{Name: "user", Passphrase: "verylongpassword"},
+	)
+}
+
 func printSrtStats(conn net.Conn) {
 	mon := conn.(*srt.SRTConn).Stats()
 	s, _ := json.MarshalIndent(mon, "", "\t")
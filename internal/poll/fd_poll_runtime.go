@@ -125,6 +125,17 @@ func setDeadlineImpl(fd *FD, t time.Time, mode int) error {
 	return nil
 }
 
+// SetNotify registers f to be called by the poller for every event
+// reported on fd, including errors while no I/O is pending. f runs on the
+// poller goroutine and must not block. A nil f removes the notification.
+func (fd *FD) SetNotify(f func()) error {
+	if fd.pd.runtimeCtx == nil {
+		return errors.New("notification for unsupported file type")
+	}
+	fd.pd.runtimeCtx.SetNotify(f)
+	return nil
+}
+
 // Descriptor returns the descriptor being used by the poller,
 // or ^uintptr(0) if there isn't one. This is only used for testing.
 func Descriptor() int {
@@ -60,6 +60,16 @@ func (fd *FD) Close() error {
 	return fd.destroy()
 }
 
+// RawControl invokes the user-defined function f for a non-IO
+// operation.
+func (fd *FD) RawControl(f func(int)) error {
+	if fd.Sysfd < 0 {
+		return errClosing()
+	}
+	f(fd.Sysfd)
+	return nil
+}
+
 // Darwin and FreeBSD can't read or write 2GB+ files at a time,
 // even on 64-bit systems.
 // The same is true of socket implementations on many systems.
@@ -19,6 +19,7 @@ type PollDesc interface {
 	Wait(mode int) int
 	Reset(mode int) int
 	SetDeadline(d time.Duration, mode int)
+	SetNotify(f func())
 	Unblock()
 }
 
@@ -37,6 +38,7 @@ type pollDesc struct {
 	wc      *sync.Cond
 	wt      *time.Timer   // write deadline timer
 	wd      time.Duration // write deadline
+	notify  func()        // called for every event reported for fd
 }
 
 // PollServerInit initialize the poller
@@ -142,6 +144,12 @@ func (pd *pollDesc) SetDeadline(d time.Duration, mode int) {
 	}
 }
 
+func (pd *pollDesc) SetNotify(f func()) {
+	pd.lock.Lock()
+	defer pd.lock.Unlock()
+	pd.notify = f
+}
+
 func (pd *pollDesc) Unblock() {
 	pd.lock.Lock()
 	defer pd.lock.Unlock()
@@ -150,6 +158,7 @@ func (pd *pollDesc) Unblock() {
 	}
 	pd.closing = true
 	pd.seq++
+	pd.notify = nil
 	netpollunblock(pd, 'r', false)
 	netpollunblock(pd, 'w', false)
 	if pd.rt != nil {
@@ -171,6 +180,15 @@ func netpollready(pd *pollDesc, mode int) {
 	}
 }
 
+func netpollnotify(pd *pollDesc) {
+	pd.lock.Lock()
+	f := pd.notify
+	pd.lock.Unlock()
+	if f != nil {
+		f()
+	}
+}
+
 func netpollcheckerr(pd *pollDesc, mode int) int {
 	if pd.closing {
 		return 1 // errClosing
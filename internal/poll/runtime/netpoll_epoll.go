@@ -100,12 +100,14 @@ func run() {
 				fd := int(rfds[i])
 				if pd := pds[fd]; pd != nil {
 					netpollready(pd, 'r')
+					netpollnotify(pd)
 				}
 			}
 			for i := 0; i < wfdslen; i++ {
 				fd := int(wfds[i])
 				if pd := pds[fd]; pd != nil {
 					netpollready(pd, 'w')
+					netpollnotify(pd)
 				}
 			}
 			pdsLock.RUnlock()
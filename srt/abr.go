@@ -0,0 +1,266 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// Defaults of BitrateMonitor.
+const (
+	defaultBitrateInterval      = time.Second
+	defaultBitrateMaxDelay      = 200 * time.Millisecond
+	defaultBitrateHeadroom      = 0.8
+	defaultBitrateIncreaseAfter = 5
+	defaultBitrateLossRatio     = 0.05
+
+	bitrateDecreaseFactor = 0.7
+	bitrateIncreaseFactor = 1.1
+	bitrateRTTFactor      = 2
+)
+
+// BitrateAdvice is a bitrate recommended by a BitrateMonitor, with the
+// measurements it is based on.
+type BitrateAdvice struct {
+	// Kbps is the recommended bitrate and Previous the one it
+	// replaces.
+	Kbps     int
+	Previous int
+
+	// Reason tells what triggered the advice: "delay" when the send
+	// buffer grew beyond MaxDelay, "drop" when the sender dropped
+	// packets, "loss" when the loss ratio exceeded LossRatio, "rtt"
+	// when the round trip time doubled, "bandwidth" when the estimated
+	// bandwidth fell below the bitrate and "headroom" for an increase.
+	Reason string
+
+	// Bandwidth is the link capacity estimated by libsrt, in Mbps.
+	Bandwidth float64
+
+	// RTT is the round trip time.
+	RTT time.Duration
+
+	// Delay is the span of time covered by the send buffer.
+	Delay time.Duration
+}
+
+// Increase reports whether a is an increase of the bitrate.
+func (a BitrateAdvice) Increase() bool { return a.Kbps > a.Previous }
+
+// A BitrateMonitor watches the statistics of a connection and recommends
+// bitrate changes to the producer writing to it. It decreases the
+// bitrate as soon as the link shows congestion, so that the delay stays
+// bounded, and increases it only after the link has been clear for
+// IncreaseAfter samples, so that the bitrate does not oscillate. While
+// the send buffer drains after a decrease, a delay or round trip time
+// that is still high but falling does not count as congestion.
+//
+// The exported fields must not be changed after the first call to Run.
+type BitrateMonitor struct {
+	// Min and Max bound the recommended bitrate, in kbps. Zero Max
+	// means no upper bound.
+	Min int
+	Max int
+
+	// Interval is the time between two samples. Zero means 1s.
+	Interval time.Duration
+
+	// MaxDelay is the span of time the send buffer may cover before
+	// the bitrate is decreased. Zero means 200ms.
+	MaxDelay time.Duration
+
+	// Headroom is the fraction of the estimated bandwidth the bitrate
+	// may use. Zero means 0.8.
+	Headroom float64
+
+	// LossRatio is the ratio of lost to sent packets over a sample
+	// above which the bitrate is decreased. Zero means 0.05.
+	LossRatio float64
+
+	// IncreaseAfter is the number of consecutive clear samples before
+	// an increase. Zero means 5.
+	IncreaseAfter int
+
+	conn     *SRTConn
+	onAdvice func(BitrateAdvice)
+
+	mu      sync.Mutex
+	kbps    int
+	last    linkSample
+	minRTT  time.Duration
+	clear   int
+	sampled bool
+}
+
+// linkSample is a sample of the statistics of a connection.
+type linkSample struct {
+	rtt       time.Duration
+	bandwidth float64
+	sent      int64
+	lost      int64
+	dropped   int64
+	delay     time.Duration
+}
+
+// NewBitrateMonitor returns a monitor of c for a producer currently
+// sending kbps. onAdvice is called from Run with each recommendation.
+func NewBitrateMonitor(c *SRTConn, kbps int, onAdvice func(BitrateAdvice)) *BitrateMonitor {
+	return &BitrateMonitor{conn: c, kbps: kbps, onAdvice: onAdvice}
+}
+
+// SetBitrate tells the monitor the bitrate the producer actually uses,
+// when it did not follow the last recommendation.
+func (m *BitrateMonitor) SetBitrate(kbps int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kbps = kbps
+	m.clear = 0
+}
+
+// Bitrate returns the bitrate last recommended or set, in kbps.
+func (m *BitrateMonitor) Bitrate() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.kbps
+}
+
+// Run samples the connection every Interval until ctx is done, in which
+// case it returns nil, or the statistics cannot be read, which happens
+// when the connection is closed.
+func (m *BitrateMonitor) Run(ctx context.Context) error {
+	if !m.conn.ok() {
+		return srtapi.EINVPARAM
+	}
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultBitrateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		s, err := m.sample()
+		if err != nil {
+			return err
+		}
+		if a := m.update(s); a != nil && m.onAdvice != nil {
+			m.onAdvice(*a)
+		}
+	}
+}
+
+// sample reads the statistics of the connection.
+func (m *BitrateMonitor) sample() (linkSample, error) {
+	fd := m.conn.fd
+	st, err := getbstatsFunc(fd.pfd.Sysfd)
+	if err != nil {
+		return linkSample{}, &OpError{Op: "get", Net: fd.net, Source: fd.laddr, Addr: fd.raddr, Err: os.NewSyscallError("srt_bstats", err)}
+	}
+	return linkSample{
+		rtt:       time.Duration(st.MsRTT * float64(time.Millisecond)),
+		bandwidth: st.MbpsBandwidth,
+		sent:      st.PktSentTotal,
+		lost:      st.PktSndLossTotal,
+		dropped:   st.PktSndDropTotal,
+		delay:     time.Duration(st.MsSndBuf) * time.Millisecond,
+	}, nil
+}
+
+// update takes a new sample into account and returns the resulting
+// advice, if any.
+func (m *BitrateMonitor) update(s linkSample) *BitrateAdvice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev, first := m.last, !m.sampled
+	m.last, m.sampled = s, true
+	if s.rtt > 0 && (m.minRTT == 0 || s.rtt < m.minRTT) {
+		m.minRTT = s.rtt
+	}
+	if first {
+		return nil
+	}
+
+	limit := int(s.bandwidth * 1000 * m.headroom())
+	reason := ""
+	switch {
+	case s.delay > m.maxDelay() && s.delay >= prev.delay:
+		reason = "delay"
+	case s.dropped > prev.dropped:
+		reason = "drop"
+	case s.sent > prev.sent && float64(s.lost-prev.lost)/float64(s.sent-prev.sent) > m.lossRatio():
+		reason = "loss"
+	case m.minRTT > 0 && s.rtt > bitrateRTTFactor*m.minRTT && s.rtt >= prev.rtt:
+		reason = "rtt"
+	case limit > 0 && m.kbps > limit:
+		reason = "bandwidth"
+	}
+	kbps := m.kbps
+	if reason != "" {
+		m.clear = 0
+		kbps = int(float64(m.kbps) * bitrateDecreaseFactor)
+		if limit > 0 && limit < kbps {
+			kbps = limit
+		}
+	} else {
+		m.clear++
+		if m.clear < m.increaseAfter() || limit == 0 {
+			return nil
+		}
+		m.clear = 0
+		reason = "headroom"
+		kbps = int(float64(m.kbps) * bitrateIncreaseFactor)
+		if kbps > limit {
+			kbps = limit
+		}
+	}
+	if kbps < m.Min {
+		kbps = m.Min
+	}
+	if m.Max > 0 && kbps > m.Max {
+		kbps = m.Max
+	}
+	if kbps == m.kbps {
+		return nil
+	}
+	a := &BitrateAdvice{Kbps: kbps, Previous: m.kbps, Reason: reason, Bandwidth: s.bandwidth, RTT: s.rtt, Delay: s.delay}
+	m.kbps = kbps
+	return a
+}
+
+func (m *BitrateMonitor) maxDelay() time.Duration {
+	if m.MaxDelay > 0 {
+		return m.MaxDelay
+	}
+	return defaultBitrateMaxDelay
+}
+
+func (m *BitrateMonitor) headroom() float64 {
+	if m.Headroom > 0 {
+		return m.Headroom
+	}
+	return defaultBitrateHeadroom
+}
+
+func (m *BitrateMonitor) lossRatio() float64 {
+	if m.LossRatio > 0 {
+		return m.LossRatio
+	}
+	return defaultBitrateLossRatio
+}
+
+func (m *BitrateMonitor) increaseAfter() int {
+	if m.IncreaseAfter > 0 {
+		return m.IncreaseAfter
+	}
+	return defaultBitrateIncreaseAfter
+}
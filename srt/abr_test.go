@@ -0,0 +1,93 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+var bitrateMonitorTests = []struct {
+	s      linkSample
+	kbps   int // 0 for no advice
+	reason string
+}{
+	// Link of 10 Mbps, clear; bitrate 4000 kbps.
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 0}, 0, ""},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 1000}, 0, ""},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 2000}, 4400, "headroom"},
+	// Send buffer grows.
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 3000, delay: 300 * time.Millisecond}, 3080, "delay"},
+	// Still above MaxDelay but draining.
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 4000, delay: 250 * time.Millisecond}, 0, ""},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 5000, dropped: 3}, 2156, "drop"},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 10, sent: 6000, lost: 100, dropped: 3}, 1509, "loss"},
+	{linkSample{rtt: 50 * time.Millisecond, bandwidth: 10, sent: 7000, lost: 100, dropped: 3}, 1056, "rtt"},
+	{linkSample{rtt: 45 * time.Millisecond, bandwidth: 10, sent: 8000, lost: 100, dropped: 3}, 0, ""},
+	// Bandwidth collapses to 1 Mbps: capped at 800 kbps, then at Min.
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 1, sent: 9000, lost: 100, dropped: 3}, 739, "bandwidth"},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 0.5, sent: 10000, lost: 100, dropped: 3}, 500, "bandwidth"},
+	{linkSample{rtt: 20 * time.Millisecond, bandwidth: 0.5, sent: 11000, lost: 100, dropped: 3}, 0, ""},
+}
+
+func TestBitrateMonitorUpdate(t *testing.T) {
+	m := NewBitrateMonitor(nil, 4000, nil)
+	m.Min, m.Max = 500, 6000
+	m.IncreaseAfter = 2
+	for i, tt := range bitrateMonitorTests {
+		a := m.update(tt.s)
+		if tt.kbps == 0 {
+			if a != nil {
+				t.Errorf("#%d: got %+v; want no advice", i, a)
+			}
+			continue
+		}
+		if a == nil || a.Kbps != tt.kbps || a.Reason != tt.reason {
+			t.Errorf("#%d: got %+v; want %d kbps for %s", i, a, tt.kbps, tt.reason)
+		}
+	}
+	m.SetBitrate(5900)
+	m.update(linkSample{rtt: 20 * time.Millisecond, bandwidth: 100, sent: 12000, lost: 100, dropped: 3})
+	if a := m.update(linkSample{rtt: 20 * time.Millisecond, bandwidth: 100, sent: 13000, lost: 100, dropped: 3}); a == nil || a.Kbps != 6000 || !a.Increase() {
+		t.Errorf("got %+v; want increase to Max", a)
+	}
+}
+
+func TestBitrateMonitorRun(t *testing.T) {
+	orig := getbstatsFunc
+	defer func() { getbstatsFunc = orig }()
+	var sent int64
+	getbstatsFunc = func(fd int) (srtapi.Bstats, error) {
+		sent += 1000
+		if sent > 5000 {
+			return srtapi.Bstats{}, errors.New("socket closed")
+		}
+		return srtapi.Bstats{MsRTT: 20, MbpsBandwidth: 10, PktSentTotal: sent, PktSndBuf: 10, ByteSndBuf: 13160, MsSndBuf: 500}, nil
+	}
+
+	var advice []BitrateAdvice
+	m := NewBitrateMonitor(newSRTConn(&netFD{net: "srt"}), 4000, func(a BitrateAdvice) {
+		advice = append(advice, a)
+	})
+	m.Interval = time.Millisecond
+	if err := m.Run(context.Background()); err == nil {
+		t.Error("stats error not returned")
+	}
+	if len(advice) == 0 || advice[0].Reason != "delay" || advice[0].Increase() {
+		t.Errorf("got %+v; want decrease for delay", advice)
+	}
+	if m.Bitrate() != advice[len(advice)-1].Kbps {
+		t.Errorf("Bitrate() = %d; want %d", m.Bitrate(), advice[len(advice)-1].Kbps)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Errorf("got %v; want nil after cancel", err)
+	}
+}
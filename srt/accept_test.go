@@ -0,0 +1,132 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAcceptContextCancel(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l := ln.(*SRTListener)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	c, err := l.AcceptContext(ctx)
+	if err == nil {
+		c.Close()
+		t.Fatal("accepted a connection")
+	}
+	if perr := parseAcceptError(err); perr != nil {
+		t.Error(perr)
+	}
+	if oe, ok := err.(*OpError); !ok || oe.Err != errCanceled {
+		t.Errorf("got %v; want %v", err, errCanceled)
+	}
+
+	// The listener is still usable.
+	go func() {
+		c, err := Dial(ln.Addr().Network(), ln.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		c.Close()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), someTimeout)
+	defer cancel()
+	sc, err := l.AcceptSRTContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc.Close()
+}
+
+func TestAcceptContextTimeout(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ln.(*SRTListener).AcceptContext(ctx)
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("got %v; want timeout", err)
+	}
+}
+
+func TestAcceptContextKeepsDeadline(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l := ln.(*SRTListener)
+
+	start := time.Now()
+	deadline := start.Add(300 * time.Millisecond)
+	if err := l.SetDeadline(deadline); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := l.AcceptContext(ctx); err == nil {
+		t.Fatal("accepted a connection")
+	}
+
+	_, err = l.Accept()
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("got %v; want timeout", err)
+	}
+	if now := time.Now(); now.Before(deadline) {
+		t.Errorf("Accept timed out %v before the deadline", deadline.Sub(now))
+	}
+}
+
+func TestAcceptContextOtherAccept(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l := ln.(*SRTListener)
+
+	accepted := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+		accepted <- err
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := l.AcceptContext(ctx); err == nil {
+		t.Fatal("accepted a connection")
+	}
+
+	// The interruption of AcceptContext does not make Accept fail.
+	select {
+	case err := <-accepted:
+		t.Fatalf("Accept returned early: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	c, err := Dial(ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+}
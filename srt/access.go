@@ -0,0 +1,291 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// Reject reasons given by the access control of a ListenConfig. They are
+// predefined reasons, leaving the user-defined range to applications; the
+// counters of ListenerStats tell them from the same reasons given by
+// other callbacks.
+const (
+	// RejectDenied is given to callers matching Deny or missing Allow.
+	RejectDenied = RejectForbidden
+
+	// RejectTooManyFromIP is given to callers whose address already
+	// has MaxConnsPerIP connections.
+	RejectTooManyFromIP = RejectConflict
+
+	// RejectRateLimited is given to callers beyond HandshakeRate.
+	RejectRateLimited RejectReason = 1429
+
+	// RejectTooManyConns is given to callers while the listener has
+	// MaxConns connections.
+	RejectTooManyConns = RejectNoRoom
+)
+
+// ListenerStats are the counters of the access control of a listener.
+type ListenerStats struct {
+	// Conns is the number of connections accepted and not yet closed.
+	Conns int
+
+	// Denied, TooManyFromIP, TooManyConns and RateLimited are the
+	// numbers of callers rejected with RejectDenied,
+	// RejectTooManyFromIP, RejectTooManyConns and RejectRateLimited.
+	Denied        int64
+	TooManyFromIP int64
+	TooManyConns  int64
+	RateLimited   int64
+}
+
+// accessControl enforces the access options of a ListenConfig.
+type accessControl struct {
+	allow, deny   []*net.IPNet
+	maxConnsPerIP int
+	maxConns      int
+	rate          float64 // handshakes per second
+	burst         float64
+	errorLog      *log.Logger
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	perIP   map[string]int // admitted and accepted connections by address
+	pending map[int]string // addresses of the sockets admitted, by id
+	stats   ListenerStats
+}
+
+// accessControl returns the access control of lc, or nil if lc sets no
+// access option.
+func (lc *ListenConfig) accessControl() (*accessControl, error) {
+	if len(lc.Allow) == 0 && len(lc.Deny) == 0 && lc.MaxConnsPerIP <= 0 && lc.MaxConns <= 0 && lc.HandshakeRate <= 0 {
+		return nil, nil
+	}
+	ac := &accessControl{
+		maxConnsPerIP: lc.MaxConnsPerIP,
+		maxConns:      lc.MaxConns,
+		rate:          lc.HandshakeRate,
+		burst:         float64(lc.HandshakeBurst),
+		errorLog:      lc.ErrorLog,
+		perIP:         make(map[string]int),
+		pending:       make(map[int]string),
+	}
+	var err error
+	if ac.allow, err = parseNets(lc.Allow); err != nil {
+		return nil, err
+	}
+	if ac.deny, err = parseNets(lc.Deny); err != nil {
+		return nil, err
+	}
+	if ac.burst < 1 {
+		ac.burst = ac.rate
+		if ac.burst < 1 {
+			ac.burst = 1
+		}
+	}
+	ac.tokens = ac.burst
+	return ac, nil
+}
+
+// parseNets parses a list of CIDR networks or single IP addresses.
+func parseNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: s}
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// listenCallback returns a listen callback rejecting the callers ac does
+// not admit and deferring to next for the others.
+func (ac *accessControl) listenCallback(next srtapi.SrtListenCallbackFunc) srtapi.SrtListenCallbackFunc {
+	return func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		peer, _ := sockaddrToSRT(peeraddr).(*SRTAddr)
+		if reason := ac.admit(ns, peer, time.Now()); reason != 0 {
+			ac.logf("srt: rejected %v: %v", peer, reason)
+			return Reject(ns, reason)
+		}
+		if next == nil {
+			return 0
+		}
+		ret := next(ns, hsversion, peeraddr, streamid)
+		if ret < 0 {
+			ac.release(ns)
+		}
+		return ret
+	}
+}
+
+// admit returns 0 if a caller from peer is admitted at time now, or the
+// reason to reject it. The admitted socket ns counts against the limits
+// until it is released, if it is not accepted, or its connection closed.
+func (ac *accessControl) admit(ns int, peer *SRTAddr, now time.Time) RejectReason {
+	var ip net.IP
+	if peer != nil {
+		ip = peer.IP
+	}
+	key := ip.String()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.dropPendingLocked()
+	switch {
+	case containsIP(ac.deny, ip) || len(ac.allow) > 0 && !containsIP(ac.allow, ip):
+		ac.stats.Denied++
+		return RejectDenied
+	case !ac.takeTokenLocked(now):
+		ac.stats.RateLimited++
+		return RejectRateLimited
+	case ac.maxConnsPerIP > 0 && ac.perIP[key] >= ac.maxConnsPerIP:
+		ac.stats.TooManyFromIP++
+		return RejectTooManyFromIP
+	case ac.maxConns > 0 && ac.stats.Conns+len(ac.pending) >= ac.maxConns:
+		ac.stats.TooManyConns++
+		return RejectTooManyConns
+	}
+	ac.pending[ns] = key
+	ac.perIP[key]++
+	return 0
+}
+
+// release gives back the limits taken by the socket ns, which was
+// admitted but will not be accepted.
+func (ac *accessControl) release(ns int) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if key, ok := ac.pending[ns]; ok {
+		delete(ac.pending, ns)
+		ac.decPerIPLocked(key)
+	}
+}
+
+// dropPendingLocked releases the admitted sockets libsrt closed before
+// they were accepted, as when the handshake failed or the listener was
+// closed.
+func (ac *accessControl) dropPendingLocked() {
+	for ns, key := range ac.pending {
+		switch State(getsockstateFunc(ns)) {
+		case StateBroken, StateClosing, StateClosed, StateNonexist:
+			delete(ac.pending, ns)
+			ac.decPerIPLocked(key)
+		}
+	}
+}
+
+func (ac *accessControl) decPerIPLocked(key string) {
+	if ac.perIP[key]--; ac.perIP[key] <= 0 {
+		delete(ac.perIP, key)
+	}
+}
+
+// takeTokenLocked takes a token from the handshake bucket, which is
+// refilled at the handshake rate up to the burst size.
+func (ac *accessControl) takeTokenLocked(now time.Time) bool {
+	if ac.rate <= 0 {
+		return true
+	}
+	if !ac.last.IsZero() {
+		ac.tokens += now.Sub(ac.last).Seconds() * ac.rate
+		if ac.tokens > ac.burst {
+			ac.tokens = ac.burst
+		}
+	}
+	ac.last = now
+	if ac.tokens < 1 {
+		return false
+	}
+	ac.tokens--
+	return true
+}
+
+// track counts the accepted connection fd until it is closed, taking
+// over the limits its socket took when it was admitted.
+func (ac *accessControl) track(fd *netFD) {
+	ac.mu.Lock()
+	key, ok := ac.pending[fd.pfd.Sysfd]
+	if ok {
+		delete(ac.pending, fd.pfd.Sysfd)
+	} else {
+		var ip net.IP
+		if a, isSRT := fd.raddr.(*SRTAddr); isSRT {
+			ip = a.IP
+		}
+		key = ip.String()
+		ac.perIP[key]++
+	}
+	ac.stats.Conns++
+	ac.mu.Unlock()
+	fd.onClose = func() {
+		ac.mu.Lock()
+		ac.stats.Conns--
+		ac.decPerIPLocked(key)
+		ac.mu.Unlock()
+	}
+}
+
+func (ac *accessControl) logf(format string, args ...interface{}) {
+	if ac.errorLog != nil {
+		ac.errorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// accessControlContextKey is the type of contextKeys used for the access
+// control of a ListenConfig.
+type accessControlContextKey struct{}
+
+func accessControlValue(ctx context.Context) *accessControl {
+	ac, _ := ctx.Value(accessControlContextKey{}).(*accessControl)
+	return ac
+}
+
+// Stats returns the counters of the access control of the listener. They
+// are zero unless it was created by a ListenConfig with access options.
+func (l *SRTListener) Stats() ListenerStats {
+	if !l.ok() {
+		return ListenerStats{}
+	}
+	ac := accessControlValue(l.ctx)
+	if ac == nil {
+		return ListenerStats{}
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	return ac.stats
+}
@@ -0,0 +1,292 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
+)
+
+func TestParseNets(t *testing.T) {
+	nets, err := parseNets([]string{"192.0.2.0/24", "198.51.100.7", "2001:db8::/32", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		ip   string
+		want bool
+	}{
+		{"192.0.2.200", true},
+		{"198.51.100.7", true},
+		{"198.51.100.8", false},
+		{"2001:db8:1::1", true},
+		{"2001:db9::1", false},
+	} {
+		if got := containsIP(nets, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("%s: got %v; want %v", tt.ip, got, tt.want)
+		}
+	}
+	for _, s := range []string{"192.0.2.0/33", "example.com"} {
+		if _, err := parseNets([]string{s}); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestListenConfigInvalidAccess(t *testing.T) {
+	lc := &ListenConfig{Deny: []string{"not an address"}}
+	if _, err := lc.Listen(context.Background(), "srt", "127.0.0.1:0"); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func peerAddr(ip string) *SRTAddr {
+	return &SRTAddr{IP: net.ParseIP(ip), Port: 5000}
+}
+
+// stubSockStates makes getsockstateFunc report the sockets in closed as
+// closed and the others as connected.
+func stubSockStates(closed map[int]bool) func() {
+	orig := getsockstateFunc
+	getsockstateFunc = func(fd int) int {
+		if closed[fd] {
+			return int(StateClosed)
+		}
+		return int(StateConnected)
+	}
+	return func() { getsockstateFunc = orig }
+}
+
+func TestAccessControlAdmit(t *testing.T) {
+	defer stubSockStates(nil)()
+	lc := &ListenConfig{
+		Allow:         []string{"192.0.2.0/24"},
+		Deny:          []string{"192.0.2.66"},
+		MaxConnsPerIP: 2,
+		MaxConns:      3,
+	}
+	ac, err := lc.accessControl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if r := ac.admit(1, peerAddr("198.51.100.1"), now); r != RejectDenied {
+		t.Errorf("outside Allow: got %v", r)
+	}
+	if r := ac.admit(2, peerAddr("192.0.2.66"), now); r != RejectDenied {
+		t.Errorf("in Deny: got %v", r)
+	}
+
+	var fds []*netFD
+	ns := 10
+	accept := func(ip string) {
+		ns++
+		if r := ac.admit(ns, peerAddr(ip), now); r != 0 {
+			t.Fatalf("%s: got %v", ip, r)
+		}
+		fd := &netFD{pfd: poll.FD{Sysfd: ns}, raddr: peerAddr(ip)}
+		ac.track(fd)
+		fds = append(fds, fd)
+	}
+	accept("192.0.2.1")
+	accept("192.0.2.1")
+	if r := ac.admit(20, peerAddr("192.0.2.1"), now); r != RejectTooManyFromIP {
+		t.Errorf("third from one address: got %v", r)
+	}
+	accept("192.0.2.2")
+	if r := ac.admit(21, peerAddr("192.0.2.3"), now); r != RejectTooManyConns {
+		t.Errorf("fourth connection: got %v", r)
+	}
+	fds[0].onClose()
+	if r := ac.admit(22, peerAddr("192.0.2.1"), now); r != 0 {
+		t.Errorf("after close: got %v", r)
+	}
+
+	want := ListenerStats{Conns: 2, Denied: 2, TooManyFromIP: 1, TooManyConns: 1}
+	if ac.stats != want {
+		t.Errorf("got %+v; want %+v", ac.stats, want)
+	}
+}
+
+func TestAccessControlPending(t *testing.T) {
+	closed := make(map[int]bool)
+	defer stubSockStates(closed)()
+	ac, err := (&ListenConfig{MaxConnsPerIP: 1, MaxConns: 2}).accessControl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	// Callers admitted but not accepted yet hold their slots.
+	if r := ac.admit(1, peerAddr("192.0.2.1"), now); r != 0 {
+		t.Fatalf("first caller: got %v", r)
+	}
+	if r := ac.admit(2, peerAddr("192.0.2.1"), now); r != RejectTooManyFromIP {
+		t.Errorf("second from one address: got %v", r)
+	}
+	if r := ac.admit(3, peerAddr("192.0.2.2"), now); r != 0 {
+		t.Fatalf("second caller: got %v", r)
+	}
+	if r := ac.admit(4, peerAddr("192.0.2.3"), now); r != RejectTooManyConns {
+		t.Errorf("third caller: got %v", r)
+	}
+
+	// A socket rejected by a later callback gives its slot back, and
+	// so does a socket libsrt closed before it was accepted.
+	ac.release(3)
+	if r := ac.admit(5, peerAddr("192.0.2.3"), now); r != 0 {
+		t.Errorf("after release: got %v", r)
+	}
+	closed[1] = true
+	if r := ac.admit(6, peerAddr("192.0.2.1"), now); r != 0 {
+		t.Errorf("after drop: got %v", r)
+	}
+
+	// Accepting a socket keeps its slot.
+	fd := &netFD{pfd: poll.FD{Sysfd: 6}, raddr: peerAddr("192.0.2.1")}
+	ac.track(fd)
+	if r := ac.admit(7, peerAddr("192.0.2.1"), now); r != RejectTooManyFromIP {
+		t.Errorf("after accept: got %v", r)
+	}
+	if ac.stats.Conns != 1 || len(ac.pending) != 1 {
+		t.Errorf("got %d connections, %d pending", ac.stats.Conns, len(ac.pending))
+	}
+	fd.onClose()
+	if len(ac.perIP) != 1 {
+		t.Errorf("got %v", ac.perIP)
+	}
+}
+
+func TestAccessRejectReasons(t *testing.T) {
+	seen := make(map[RejectReason]bool)
+	for _, r := range []RejectReason{RejectDenied, RejectTooManyFromIP, RejectRateLimited, RejectTooManyConns} {
+		if r < RejectPredefined || r >= RejectUserDefined || seen[r] {
+			t.Errorf("%d: not a distinct predefined reason", int(r))
+		}
+		if s := r.String(); strings.HasPrefix(s, "reject reason") {
+			t.Errorf("%d: got name %q", int(r), s)
+		}
+		seen[r] = true
+	}
+}
+
+func TestAccessControlRate(t *testing.T) {
+	defer stubSockStates(nil)()
+	ac, err := (&ListenConfig{HandshakeRate: 2, HandshakeBurst: 3}).accessControl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	peer := peerAddr("192.0.2.1")
+	for i := 0; i < 3; i++ {
+		if r := ac.admit(i, peer, now); r != 0 {
+			t.Fatalf("burst handshake %d: got %v", i, r)
+		}
+	}
+	if r := ac.admit(3, peer, now); r != RejectRateLimited {
+		t.Errorf("beyond burst: got %v", r)
+	}
+	if r := ac.admit(4, peer, now.Add(500*time.Millisecond)); r != 0 {
+		t.Errorf("after refill: got %v", r)
+	}
+	if r := ac.admit(5, peer, now.Add(600*time.Millisecond)); r != RejectRateLimited {
+		t.Errorf("before refill: got %v", r)
+	}
+	if ac.stats.RateLimited != 2 {
+		t.Errorf("got %d rate limited", ac.stats.RateLimited)
+	}
+}
+
+func TestAccessControlListenCallback(t *testing.T) {
+	defer stubSockStates(nil)()
+	defer func(f func(int, int) error) { setrejectreasonFunc = f }(setrejectreasonFunc)
+	var reason int
+	setrejectreasonFunc = func(ns int, r int) error { reason = r; return nil }
+
+	var buf bytes.Buffer
+	ac, err := (&ListenConfig{Deny: []string{"192.0.2.0/24"}, ErrorLog: log.New(&buf, "", 0)}).accessControl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := false
+	cb := ac.listenCallback(func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		next = true
+		return 0
+	})
+	if ret := cb(1, 5, &syscall.SockaddrInet4{Addr: [4]byte{192, 0, 2, 1}, Port: 5000}, ""); ret != -1 || next {
+		t.Errorf("denied caller: got %d, next called %v", ret, next)
+	}
+	if RejectReason(reason) != RejectDenied {
+		t.Errorf("got reason %d", reason)
+	}
+	if !strings.Contains(buf.String(), "192.0.2.1:5000") {
+		t.Errorf("got log %q", buf.String())
+	}
+	if ret := cb(1, 5, &syscall.SockaddrInet4{Addr: [4]byte{198, 51, 100, 1}, Port: 5000}, ""); ret != 0 || !next {
+		t.Errorf("allowed caller: got %d, next called %v", ret, next)
+	}
+
+	// A caller the next callback rejects does not keep a slot.
+	cb = ac.listenCallback(func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		return Reject(ns, RejectForbidden)
+	})
+	if ret := cb(2, 5, &syscall.SockaddrInet4{Addr: [4]byte{198, 51, 100, 1}, Port: 5000}, ""); ret != -1 {
+		t.Errorf("rejected by next: got %d", ret)
+	}
+	if _, ok := ac.pending[2]; ok {
+		t.Error("slot of a rejected caller kept")
+	}
+}
+
+func TestListenConfigDenyBeforeServer(t *testing.T) {
+	lc := &ListenConfig{Deny: []string{"127.0.0.1"}, ErrorLog: log.New(ioutil.Discard, "", 0)}
+	ln, err := lc.Listen(context.Background(), "srt4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewServeMux()
+	mux.Handle("publish live/{name}", HandlerFunc(func(*SRTConn, StreamID) {}))
+	srv := &Server{Handler: mux}
+	defer srv.Close()
+	go srv.Serve(ln)
+	for ln.(*SRTListener).fd.listenServer.Load() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The mux would reject the stream with RejectNotFound, but the
+	// access control comes first.
+	rejected := make(chan RejectReason, 1)
+	ctx := srttrace.WithClientTrace(context.Background(), &srttrace.ClientTrace{
+		Rejected: func(addr string, reason int) { rejected <- RejectReason(reason) },
+	})
+	ctx = WithOptions(ctx, Options("streamid", "#!::r=vod/a"))
+	ctx, cancel := context.WithTimeout(ctx, someTimeout)
+	defer cancel()
+	var d Dialer
+	if c, err := d.DialContext(ctx, ln.Addr().Network(), ln.Addr().String()); err == nil {
+		c.Close()
+		t.Fatal("denied caller connected")
+	}
+	select {
+	case r := <-rejected:
+		if r != RejectDenied {
+			t.Errorf("got %v; want %v", r, RejectDenied)
+		}
+	default:
+		t.Error("rejection not traced")
+	}
+	if st := ln.(*SRTListener).Stats(); st.Denied != 1 {
+		t.Errorf("got %+v", st)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// Package auth authenticates SRT callers during the handshake.
+//
+// An Authenticator looks at the stream ID and address of a caller and
+// returns the passphrase the connection must be encrypted with and the
+// modes and resources the caller may use. ListenCallback turns it into a
+// listen callback that rejects the callers it refuses with a proper reject
+// reason:
+//
+//	users, err := auth.LoadUsers("users.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	ctx := srt.WithListenCallback(ctx, auth.ListenCallback(users, nil))
+//	l, err := srt.ListenContext(ctx, "srt", ":6000")
+package auth
+
+import (
+	"errors"
+	"net"
+	"path"
+	"syscall"
+
+	"github.com/openfresh/gosrt/srt"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+var (
+	// Placeholders for srt calls on the socket being accepted.
+	setpassphraseFunc = func(ns int, passphrase string) error {
+		return srtapi.SetsockflagString(ns, int(srtapi.OptionPassphrase), passphrase)
+	}
+	rejectFunc = srt.Reject
+)
+
+// An Authenticator decides whether a caller is accepted.
+type Authenticator interface {
+	// Authenticate returns what the caller with the given stream ID
+	// and address is allowed to do, or an error to reject it. It is
+	// called from the listen callback and should return quickly.
+	Authenticate(sid srt.StreamID, peer net.Addr) (*Result, error)
+}
+
+// Result is the outcome of a successful authentication.
+type Result struct {
+	// User is the authenticated user name.
+	User string
+
+	// Passphrase, if not empty, is the passphrase the connection must
+	// be encrypted with. A caller using another passphrase fails the
+	// handshake.
+	Passphrase string
+
+	// Modes are the stream ID modes the caller may request. Empty
+	// means any mode.
+	Modes []string
+
+	// Resources are patterns, in the syntax of path.Match, of the
+	// resources the caller may use. Empty means any resource.
+	Resources []string
+}
+
+// Allow returns 0 if r allows the mode and resource requested by sid, or
+// the reason to reject the caller.
+func (r *Result) Allow(sid srt.StreamID) srt.RejectReason {
+	if len(r.Modes) > 0 && !contains(r.Modes, sid.RequestMode()) {
+		return srt.RejectBadMode
+	}
+	if len(r.Resources) > 0 && !matchAny(r.Resources, sid.Resource) {
+		return srt.RejectForbidden
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok || p == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Error is an authentication failure carrying the reason given to the
+// rejected caller.
+type Error struct {
+	Reason srt.RejectReason
+	Msg    string
+}
+
+func (e *Error) Error() string { return "auth: " + e.Msg }
+
+// Authentication failures.
+var (
+	ErrMissingUser  = &Error{Reason: srt.RejectUnauthorized, Msg: "missing user"}
+	ErrUnknownUser  = &Error{Reason: srt.RejectUnauthorized, Msg: "unknown user"}
+	ErrMissingToken = &Error{Reason: srt.RejectUnauthorized, Msg: "missing token"}
+	ErrBadToken     = &Error{Reason: srt.RejectUnauthorized, Msg: "invalid token"}
+	ErrTokenExpired = &Error{Reason: srt.RejectUnauthorized, Msg: "token expired"}
+)
+
+// Reason returns the reason to reject a caller whose authentication failed
+// with err: the Reason of an *Error, or RejectUnauthorized for other
+// errors.
+func Reason(err error) srt.RejectReason {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return srt.RejectUnauthorized
+}
+
+// The Func type is an adapter to allow the use of ordinary functions as
+// Authenticators.
+type Func func(sid srt.StreamID, peer net.Addr) (*Result, error)
+
+// Authenticate calls f(sid, peer).
+func (f Func) Authenticate(sid srt.StreamID, peer net.Addr) (*Result, error) {
+	return f(sid, peer)
+}
+
+// ListenCallback returns a listen callback, for srt.WithListenCallback,
+// that authenticates each caller with a and sets the passphrase of the
+// connection. Callers are rejected with RejectBadRequest when their
+// stream ID is malformed, with the Reason of the error returned by a, and
+// when the result does not allow their mode or resource. Accepted callers
+// are passed on to next, if not nil.
+func ListenCallback(a Authenticator, next srtapi.SrtListenCallbackFunc) srtapi.SrtListenCallbackFunc {
+	return func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		sid, err := srt.ParseStreamID(streamid)
+		if err != nil {
+			return rejectFunc(ns, srt.RejectBadRequest)
+		}
+		res, err := a.Authenticate(sid, srt.PeerAddr(peeraddr))
+		if err != nil {
+			return rejectFunc(ns, Reason(err))
+		}
+		if reason := res.Allow(sid); reason != 0 {
+			return rejectFunc(ns, reason)
+		}
+		if res.Passphrase != "" {
+			if err := setpassphraseFunc(ns, res.Passphrase); err != nil {
+				return rejectFunc(ns, srt.RejectInternalServerError)
+			}
+		}
+		if next != nil {
+			return next(ns, hsversion, peeraddr, streamid)
+		}
+		return 0
+	}
+}
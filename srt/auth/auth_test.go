@@ -0,0 +1,102 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+var allowTests = []struct {
+	res    Result
+	sid    string
+	reason srt.RejectReason
+}{
+	{Result{}, "#!::r=live/a,m=publish", 0},
+	{Result{Modes: []string{srt.ModePublish}}, "#!::r=live/a,m=publish", 0},
+	{Result{Modes: []string{srt.ModePublish}}, "#!::r=live/a", srt.RejectBadMode},
+	{Result{Resources: []string{"live/*"}}, "#!::r=live/a", 0},
+	{Result{Resources: []string{"live/*"}}, "#!::r=vod/a", srt.RejectForbidden},
+	{Result{Resources: []string{"live/[a"}}, "#!::r=live/[a", 0},
+}
+
+func TestResultAllow(t *testing.T) {
+	for i, tt := range allowTests {
+		sid, err := srt.ParseStreamID(tt.sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reason := tt.res.Allow(sid); reason != tt.reason {
+			t.Errorf("#%d: got %v; want %v", i, reason, tt.reason)
+		}
+	}
+}
+
+func TestReason(t *testing.T) {
+	if r := Reason(ErrTokenExpired); r != srt.RejectUnauthorized {
+		t.Errorf("got %v", r)
+	}
+	if r := Reason(&Error{Reason: srt.RejectLocked, Msg: "locked"}); r != srt.RejectLocked {
+		t.Errorf("got %v", r)
+	}
+	if r := Reason(errors.New("other")); r != srt.RejectUnauthorized {
+		t.Errorf("got %v", r)
+	}
+}
+
+func TestListenCallback(t *testing.T) {
+	defer func(set func(int, string) error, rej func(int, srt.RejectReason) int) {
+		setpassphraseFunc, rejectFunc = set, rej
+	}(setpassphraseFunc, rejectFunc)
+	var passphrase string
+	var reason srt.RejectReason
+	setpassphraseFunc = func(ns int, p string) error { passphrase = p; return nil }
+	rejectFunc = func(ns int, r srt.RejectReason) int { reason = r; return -1 }
+
+	var peer net.Addr
+	a := Func(func(sid srt.StreamID, p net.Addr) (*Result, error) {
+		peer = p
+		if sid.User != "cam1" {
+			return nil, ErrUnknownUser
+		}
+		return &Result{User: sid.User, Passphrase: "verylongpassword", Modes: []string{srt.ModePublish}}, nil
+	})
+	nextCalled := false
+	cb := ListenCallback(a, func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		nextCalled = true
+		return 0
+	})
+	sa := &syscall.SockaddrInet4{Addr: [4]byte{192, 0, 2, 1}, Port: 5000}
+
+	tests := []struct {
+		sid        string
+		ret        int
+		reason     srt.RejectReason
+		passphrase string
+	}{
+		{"#!::u=cam1,m=publish", 0, 0, "verylongpassword"},
+		{"#!::u=cam2,m=publish", -1, srt.RejectUnauthorized, ""},
+		{"#!::u=cam1", -1, srt.RejectBadMode, ""},
+		{"#!::u", -1, srt.RejectBadRequest, ""},
+	}
+	for _, tt := range tests {
+		passphrase, reason, nextCalled = "", 0, false
+		if ret := cb(100, 5, sa, tt.sid); ret != tt.ret {
+			t.Errorf("%q: got %d; want %d", tt.sid, ret, tt.ret)
+		}
+		if reason != tt.reason || passphrase != tt.passphrase {
+			t.Errorf("%q: got reason %d, passphrase %q; want %v, %q", tt.sid, reason, passphrase, tt.reason, tt.passphrase)
+		}
+		if nextCalled != (tt.ret == 0) {
+			t.Errorf("%q: next called: %v", tt.sid, nextCalled)
+		}
+	}
+	if peer == nil || peer.String() != "192.0.2.1:5000" {
+		t.Errorf("got peer %v", peer)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// +build gofuzz
+
+package auth
+
+import "bytes"
+
+// Fuzz is the entry point of go-fuzz for users files.
+func Fuzz(data []byte) int {
+	if _, err := ReadUsers(bytes.NewReader(data)); err != nil {
+		return 0
+	}
+	return 1
+}
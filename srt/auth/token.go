@@ -0,0 +1,97 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+// defaultTokenKey is the stream ID key holding the token.
+const defaultTokenKey = "token"
+
+var timeNow = time.Now
+
+// Tokens authenticates callers with time-limited tokens embedded in the
+// stream ID, as in "#!::u=cam1,r=live/cam1,m=publish,token=...". A token
+// is issued with Sign by whoever holds the secret, usually a web service
+// handing out stream IDs. It binds the user, resource and mode of the
+// stream ID until it expires, so the caller may use nothing else.
+type Tokens struct {
+	// Secret is the HMAC key the tokens are signed with.
+	Secret []byte
+
+	// Key is the stream ID key holding the token. Empty means
+	// "token".
+	Key string
+
+	// Passphrase, if not empty, is the passphrase the connections
+	// must be encrypted with.
+	Passphrase string
+}
+
+// Sign returns sid with a token valid until expires. The caller connects
+// with the String of the result as its stream ID.
+func (t *Tokens) Sign(sid srt.StreamID, expires time.Time) srt.StreamID {
+	fields := make(map[string]string, len(sid.Fields)+1)
+	for k, v := range sid.Fields {
+		fields[k] = v
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	fields[t.key()] = exp + "." + t.mac(sid, exp)
+	sid.Fields = fields
+	sid.Raw = ""
+	return sid
+}
+
+// Authenticate checks the token of sid.
+func (t *Tokens) Authenticate(sid srt.StreamID, peer net.Addr) (*Result, error) {
+	token, ok := sid.Fields[t.key()]
+	if !ok {
+		return nil, ErrMissingToken
+	}
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, ErrBadToken
+	}
+	exp, mac := token[:i], token[i+1:]
+	sec, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return nil, ErrBadToken
+	}
+	if !hmac.Equal([]byte(mac), []byte(t.mac(sid, exp))) {
+		return nil, ErrBadToken
+	}
+	if !timeNow().Before(time.Unix(sec, 0)) {
+		return nil, ErrTokenExpired
+	}
+	return &Result{
+		User:       sid.User,
+		Passphrase: t.Passphrase,
+		Modes:      []string{sid.RequestMode()},
+		Resources:  []string{sid.Resource},
+	}, nil
+}
+
+// mac returns the signature of the user, resource and mode of sid with
+// the expiry time exp.
+func (t *Tokens) mac(sid srt.StreamID, exp string) string {
+	h := hmac.New(sha256.New, t.Secret)
+	h.Write([]byte(strings.Join([]string{sid.User, sid.Resource, sid.RequestMode(), exp}, "\n")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *Tokens) key() string {
+	if t.Key != "" {
+		return t.Key
+	}
+	return defaultTokenKey
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+func TestTokens(t *testing.T) {
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	now := time.Unix(1600000000, 0)
+	timeNow = func() time.Time { return now }
+
+	tokens := &Tokens{Secret: []byte("secret"), Passphrase: "verylongpassword"}
+	signed := tokens.Sign(srt.StreamID{User: "cam1", Resource: "live/cam1", Mode: srt.ModePublish}, now.Add(time.Minute))
+	sid, err := srt.ParseStreamID(signed.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tokens.Authenticate(sid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.User != "cam1" || res.Passphrase != "verylongpassword" {
+		t.Errorf("got %+v", res)
+	}
+	if reason := res.Allow(sid); reason != 0 {
+		t.Errorf("signed stream id not allowed: %v", reason)
+	}
+
+	tampered := sid
+	tampered.Resource = "live/cam2"
+	if _, err := tokens.Authenticate(tampered, nil); err != ErrBadToken {
+		t.Errorf("tampered resource: got %v; want %v", err, ErrBadToken)
+	}
+	other := &Tokens{Secret: []byte("other")}
+	if _, err := other.Authenticate(sid, nil); err != ErrBadToken {
+		t.Errorf("other secret: got %v; want %v", err, ErrBadToken)
+	}
+	if _, err := tokens.Authenticate(srt.StreamID{User: "cam1"}, nil); err != ErrMissingToken {
+		t.Errorf("no token: got %v; want %v", err, ErrMissingToken)
+	}
+	now = now.Add(time.Minute)
+	if _, err := tokens.Authenticate(sid, nil); err != ErrTokenExpired {
+		t.Errorf("expired: got %v; want %v", err, ErrTokenExpired)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+// User is an entry of a Users file.
+type User struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Passphrase, if not empty, is the passphrase the connections of
+	// the user must be encrypted with.
+	Passphrase string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"`
+
+	// Modes and Resources restrict what the user may do, as in Result.
+	Modes     []string `json:"modes,omitempty" yaml:"modes,omitempty"`
+	Resources []string `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+// Users authenticates callers against a list of users, taking the user
+// from the u key of the stream ID or, for a stream ID that does not
+// follow the access control convention, the whole stream ID.
+//
+// A users file is a JSON document such as
+//
+//	{"users": [
+//		{"name": "admin", "passphrase": "thelocalmanager"},
+//		{"name": "cam1", "passphrase": "verylongpassword",
+//		 "modes": ["publish"], "resources": ["live/cam1"]}
+//	]}
+//
+// or the same in YAML:
+//
+//	users:
+//	  - name: admin
+//	    passphrase: thelocalmanager
+//	  - name: cam1
+//	    passphrase: verylongpassword
+//	    modes: [publish]
+//	    resources: [live/cam1]
+type Users struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+type usersFile struct {
+	Users []User `json:"users"`
+}
+
+// NewUsers returns an Authenticator for the given users.
+func NewUsers(users ...User) (*Users, error) {
+	u := new(Users)
+	if err := u.Set(users...); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// LoadUsers reads the users file name.
+func LoadUsers(name string) (*Users, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadUsers(f)
+}
+
+// ReadUsers reads a users file from r. A file starting with "{" is read
+// as JSON, any other as YAML.
+func ReadUsers(r io.Reader) (*Users, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		var file usersFile
+		if err := json.Unmarshal(b, &file); err != nil {
+			return nil, err
+		}
+		return NewUsers(file.Users...)
+	}
+	users, err := parseUsersYAML(b)
+	if err != nil {
+		return nil, err
+	}
+	return NewUsers(users...)
+}
+
+// Set replaces the users, for instance after the users file changed.
+func (u *Users) Set(users ...User) error {
+	m := make(map[string]User, len(users))
+	for _, user := range users {
+		if user.Name == "" {
+			return errors.New("auth: user without a name")
+		}
+		if _, dup := m[user.Name]; dup {
+			return fmt.Errorf("auth: duplicate user %q", user.Name)
+		}
+		if p := user.Passphrase; p != "" && (len(p) < 10 || len(p) > 79) {
+			return fmt.Errorf("auth: passphrase of user %q not 10 to 79 characters long", user.Name)
+		}
+		m[user.Name] = user
+	}
+	u.mu.Lock()
+	u.users = m
+	u.mu.Unlock()
+	return nil
+}
+
+// Authenticate looks up the user of sid.
+func (u *Users) Authenticate(sid srt.StreamID, peer net.Addr) (*Result, error) {
+	name := sid.User
+	if sid.Fields == nil {
+		name = sid.Raw
+	}
+	if name == "" {
+		return nil, ErrMissingUser
+	}
+	u.mu.RLock()
+	user, ok := u.users[name]
+	u.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+	return &Result{User: user.Name, Passphrase: user.Passphrase, Modes: user.Modes, Resources: user.Resources}, nil
+}
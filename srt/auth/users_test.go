@@ -0,0 +1,147 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srt"
+)
+
+const usersJSON = `{"users": [
+	{"name": "admin", "passphrase": "thelocalmanager"},
+	{"name": "cam1", "passphrase": "verylongpassword", "modes": ["publish"], "resources": ["live/cam1"]}
+]}`
+
+func TestUsers(t *testing.T) {
+	u, err := ReadUsers(strings.NewReader(usersJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sid  string
+		want *Result
+		err  error
+	}{
+		{"#!::u=cam1,r=live/cam1,m=publish", &Result{User: "cam1", Passphrase: "verylongpassword", Modes: []string{"publish"}, Resources: []string{"live/cam1"}}, nil},
+		{"admin", &Result{User: "admin", Passphrase: "thelocalmanager"}, nil},
+		{"#!::u=nobody", nil, ErrUnknownUser},
+		{"#!::r=live/cam1", nil, ErrMissingUser},
+	}
+	for _, tt := range tests {
+		sid, _ := srt.ParseStreamID(tt.sid)
+		res, err := u.Authenticate(sid, nil)
+		if err != tt.err || !reflect.DeepEqual(res, tt.want) {
+			t.Errorf("%q: got %+v, %v; want %+v, %v", tt.sid, res, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestUsersInvalid(t *testing.T) {
+	for _, users := range [][]User{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Passphrase: "short"}},
+	} {
+		if _, err := NewUsers(users...); err == nil {
+			t.Errorf("%+v: no error", users)
+		}
+	}
+	if _, err := ReadUsers(strings.NewReader("{")); err == nil {
+		t.Error("malformed file: no error")
+	}
+}
+
+const usersYAML = `# users of the example app
+users:
+  - name: admin
+    passphrase: thelocalmanager
+  - name: cam1
+    passphrase: "verylongpassword"   # quoted
+    modes: [publish]
+    resources:
+    - 'live/cam1'
+`
+
+func TestUsersYAML(t *testing.T) {
+	fromJSON, err := ReadUsers(strings.NewReader(usersJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := ReadUsers(strings.NewReader(usersYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML.users, fromJSON.users) {
+		t.Errorf("got %+v; want %+v", fromYAML.users, fromJSON.users)
+	}
+}
+
+var badUsersYAML = []string{
+	"users:\n  - name: a\n   passphrase: verylongpassword\n",
+	"users:\n  - name: [a]\n",
+	"users:\n  - name: a\n    modes: {publish: true}\n",
+	"users:\n  - name: a\n    modes: [publish\n",
+	"users:\n  - name: 'a\n",
+	"users: a\n",
+	"- a\n",
+	"users:\n  - name: a\n  - name: a\n",
+}
+
+func TestUsersYAMLInvalid(t *testing.T) {
+	for _, s := range badUsersYAML {
+		if _, err := ReadUsers(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestUsersYAMLNestedSequence(t *testing.T) {
+	users, err := ReadUsers(strings.NewReader("users:\n  - - name: cam1\n"))
+	if err == nil {
+		t.Errorf("got %+v; want error", users.users)
+	}
+	users, err = ReadUsers(strings.NewReader("users:\n- - :\n"))
+	if err == nil {
+		t.Errorf("got %+v; want error", users.users)
+	}
+	if _, err := ReadUsers(strings.NewReader("1:\n- - :")); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestUsersYAMLTerminates parses every short document made of the
+// characters the parser handles specially. None may keep it looping.
+func TestUsersYAMLTerminates(t *testing.T) {
+	const alphabet = "- :\na'["
+	n := 6
+	if testing.Short() {
+		n = 4
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b := make([]byte, n)
+		var gen func(i int)
+		gen = func(i int) {
+			parseUsersYAML(b[:i])
+			if i == n {
+				return
+			}
+			for j := 0; j < len(alphabet); j++ {
+				b[i] = alphabet[j]
+				gen(i + 1)
+			}
+		}
+		gen(0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		t.Fatal("parser does not terminate")
+	}
+}
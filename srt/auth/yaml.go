@@ -0,0 +1,374 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of a YAML document without its indentation and
+// comment.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// yamlError is a syntax error at a line of a YAML document.
+type yamlError struct {
+	line int
+	msg  string
+}
+
+func (e *yamlError) Error() string {
+	return "auth: yaml: line " + strconv.Itoa(e.line) + ": " + e.msg
+}
+
+// parseUsersYAML parses a users file written in YAML. It understands the
+// subset of YAML such files are written in: block mappings and sequences,
+// flow sequences of scalars, plain and quoted scalars and comments.
+func parseUsersYAML(b []byte) ([]User, error) {
+	lines, err := yamlLines(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	doc, next, err := parseYAMLNode(lines, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, &yamlError{lines[next].num, "unexpected indentation"}
+	}
+	top, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &yamlError{lines[0].num, "document is not a mapping"}
+	}
+	list, ok := top["users"].([]interface{})
+	if !ok && top["users"] != nil {
+		return nil, &yamlError{lines[0].num, "users is not a sequence"}
+	}
+	users := make([]User, 0, len(list))
+	for i, v := range list {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("auth: yaml: user %d is not a mapping", i)
+		}
+		var user User
+		var err error
+		if user.Name, err = yamlString(m, "name"); err != nil {
+			return nil, err
+		}
+		if user.Passphrase, err = yamlString(m, "passphrase"); err != nil {
+			return nil, err
+		}
+		if user.Modes, err = yamlStrings(m, "modes"); err != nil {
+			return nil, err
+		}
+		if user.Resources, err = yamlStrings(m, "resources"); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func yamlString(m map[string]interface{}, key string) (string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", fmt.Errorf("auth: yaml: %s is not a string", key)
+}
+
+func yamlStrings(m map[string]interface{}, key string) ([]string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("auth: yaml: %s is not a sequence of strings", key)
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("auth: yaml: %s is not a sequence", key)
+}
+
+// yamlLines splits b into lines, dropping blank lines, comments and
+// document markers.
+func yamlLines(b []byte) ([]yamlLine, error) {
+	var lines []yamlLine
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for num := 1; s.Scan(); num++ {
+		text := stripYAMLComment(strings.TrimRight(s.Text(), " \t\r"))
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &yamlError{num, "tab in indentation"}
+		}
+		lines = append(lines, yamlLine{num: num, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	return lines, s.Err()
+}
+
+// stripYAMLComment removes the comment, if any, from the end of line.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case opensQuote(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
+
+// parseYAMLNode parses the block node starting at lines[i], which is
+// indented by at least indent, and returns it with the index of the line
+// following it.
+func parseYAMLNode(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if i >= len(lines) || lines[i].indent < indent {
+		return nil, i, nil
+	}
+	if isYAMLSequenceItem(lines[i].text) {
+		return parseYAMLSequence(lines, i)
+	}
+	return parseYAMLMapping(lines, i)
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLSequence(lines []yamlLine, i int) (interface{}, int, error) {
+	indent := lines[i].indent
+	var list []interface{}
+	for i < len(lines) && lines[i].indent == indent && isYAMLSequenceItem(lines[i].text) {
+		rest := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+		if rest == "" {
+			v, next, err := parseYAMLNode(lines, i+1, indent+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			list, i = append(list, v), next
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); !ok && !isYAMLSequenceItem(rest) {
+			v, err := parseYAMLScalar(lines[i].num, rest)
+			if err != nil {
+				return nil, 0, err
+			}
+			list, i = append(list, v), i+1
+			continue
+		}
+		// A mapping or a sequence starting on the line of the item:
+		// parse it as if it started on a line of its own. Either one
+		// consumes at least that line.
+		item := make([]yamlLine, len(lines))
+		copy(item, lines)
+		item[i] = yamlLine{num: lines[i].num, indent: indent + len(lines[i].text) - len(rest), text: rest}
+		v, next, err := parseYAMLNode(item, i, 0)
+		if err != nil {
+			return nil, 0, err
+		}
+		list, i = append(list, v), next
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, &yamlError{lines[i].num, "unexpected indentation"}
+	}
+	return list, i, nil
+}
+
+func parseYAMLMapping(lines []yamlLine, i int) (interface{}, int, error) {
+	indent := lines[i].indent
+	m := make(map[string]interface{})
+	for i < len(lines) && lines[i].indent == indent && !isYAMLSequenceItem(lines[i].text) {
+		l := lines[i]
+		key, value, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, 0, &yamlError{l.num, "expected a key"}
+		}
+		if _, dup := m[key]; dup {
+			return nil, 0, &yamlError{l.num, "duplicate key " + strconv.Quote(key)}
+		}
+		i++
+		if value != "" {
+			v, err := parseYAMLScalar(l.num, value)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			continue
+		}
+		// The value is the block below the key. A sequence may be
+		// indented as much as its key.
+		var v interface{}
+		var err error
+		if i < len(lines) && lines[i].indent == indent && isYAMLSequenceItem(lines[i].text) {
+			v, i, err = parseYAMLSequence(lines, i)
+		} else {
+			v, i, err = parseYAMLNode(lines, i, indent+1)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		m[key] = v
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, 0, &yamlError{lines[i].num, "unexpected indentation"}
+	}
+	return m, i, nil
+}
+
+// splitYAMLKey splits a "key: value" or "key:" line.
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		k, err := unquoteYAML(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		key, text = k, text[end+1:]
+	} else {
+		i := strings.Index(text, ": ")
+		if i < 0 {
+			if !strings.HasSuffix(text, ":") {
+				return "", "", false
+			}
+			i = len(text) - 1
+		}
+		key, text = text[:i], text[i:]
+	}
+	if text != ":" && !strings.HasPrefix(text, ": ") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(text[1:]), true
+}
+
+// parseYAMLScalar parses a scalar or a flow sequence of scalars.
+func parseYAMLScalar(num int, s string) (interface{}, error) {
+	switch {
+	case s[0] == '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, &yamlError{num, "unterminated flow sequence"}
+		}
+		list := []interface{}{}
+		for _, item := range splitYAMLFlow(s[1 : len(s)-1]) {
+			if item == "" {
+				continue
+			}
+			v, err := parseYAMLScalar(num, item)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := v.(string); !ok {
+				return nil, &yamlError{num, "nested flow collection"}
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case s[0] == '{':
+		return nil, &yamlError{num, "flow mappings are not supported"}
+	case s[0] == '"' || s[0] == '\'':
+		if closingQuote(s) != len(s)-1 {
+			return nil, &yamlError{num, "malformed quoted scalar"}
+		}
+		v, err := unquoteYAML(s)
+		if err != nil {
+			return nil, &yamlError{num, "malformed quoted scalar"}
+		}
+		return v, nil
+	case s[0] == '|' || s[0] == '>' || s[0] == '&' || s[0] == '*' || s[0] == '!':
+		return nil, &yamlError{num, "unsupported value " + strconv.Quote(s)}
+	}
+	return s, nil
+}
+
+// splitYAMLFlow splits the items of a flow sequence at the commas outside
+// quotes.
+func splitYAMLFlow(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case opensQuote(s, i):
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, strings.TrimSpace(s[start:]))
+}
+
+// opensQuote reports whether s[i] starts a quoted scalar, rather than
+// being a quote inside a plain one.
+func opensQuote(s string, i int) bool {
+	if s[i] != '"' && s[i] != '\'' {
+		return false
+	}
+	return i == 0 || strings.IndexByte(" \t[,", s[i-1]) >= 0
+}
+
+// closingQuote returns the index of the quote closing the quoted scalar
+// at the start of s, or -1.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case q == '"' && s[i] == '\\':
+			i++
+		case s[i] == q:
+			if q == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+	return strconv.Unquote(s)
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// BufferLevel is the amount of data held in the send or receive buffer
+// of a connection.
+type BufferLevel struct {
+	Packets int
+	Bytes   int
+
+	// Time is the span of time covered by the buffered packets. For
+	// the send buffer it tells how far sending lags behind writing.
+	Time time.Duration
+}
+
+// SendBuffered returns the amount of data written to the connection but
+// not yet acknowledged by the peer. It is cheap enough to be called
+// before every Write.
+func (c *SRTConn) SendBuffered() (BufferLevel, error) {
+	if !c.ok() {
+		return BufferLevel{}, srtapi.EINVPARAM
+	}
+	packets, bytes, ms, err := getsndbufferFunc(c.fd.pfd.Sysfd)
+	if err != nil {
+		return BufferLevel{}, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_getsndbuffer", err)}
+	}
+	return BufferLevel{Packets: packets, Bytes: bytes, Time: time.Duration(ms) * time.Millisecond}, nil
+}
+
+// ReceiveBuffered returns the amount of data received but not yet read.
+func (c *SRTConn) ReceiveBuffered() (BufferLevel, error) {
+	if !c.ok() {
+		return BufferLevel{}, srtapi.EINVPARAM
+	}
+	st, err := getbstatsFunc(c.fd.pfd.Sysfd)
+	if err != nil {
+		return BufferLevel{}, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_bstats", err)}
+	}
+	return BufferLevel{Packets: st.PktRcvBuf, Bytes: st.ByteRcvBuf, Time: time.Duration(st.MsRcvBuf) * time.Millisecond}, nil
+}
+
+// BackpressurePolicy tells a BackpressureWriter what to do with a write
+// while the send buffer holds more than its threshold.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock makes Write wait until the send buffer drains
+	// below the threshold.
+	BackpressureBlock BackpressurePolicy = iota
+
+	// BackpressureDropOldest makes Write queue the data without
+	// blocking. Queued data is sent by later writes once the buffer
+	// drains, and the oldest queued data is dropped when the queue is
+	// full.
+	BackpressureDropOldest
+
+	// BackpressureSignal writes the data anyway. Only OnThreshold is
+	// called.
+	BackpressureSignal
+)
+
+// Defaults of BackpressureWriter.
+const (
+	defaultBackpressureQueueLen     = 64
+	defaultBackpressurePollInterval = 5 * time.Millisecond
+)
+
+// sendBufferedWriter is the part of SRTConn a BackpressureWriter uses.
+type sendBufferedWriter interface {
+	io.Writer
+	SendBuffered() (BufferLevel, error)
+}
+
+// A BackpressureWriter writes to an SRTConn and applies a policy when the
+// data waiting in the send buffer covers more than a threshold of time,
+// which happens when the link does not keep up with the writer. Each
+// Write is expected to be a single message, as with live mode.
+//
+// The exported fields must not be changed after the first Write.
+type BackpressureWriter struct {
+	// OnThreshold, if non-nil, is called when the send buffer level
+	// rises above the threshold (over is true) and when it falls back
+	// below it. It is called from Write and should return quickly.
+	OnThreshold func(over bool, level BufferLevel)
+
+	// QueueLen is the number of writes BackpressureDropOldest holds
+	// while the buffer is over the threshold. Zero means 64.
+	QueueLen int
+
+	// PollInterval is how often BackpressureBlock checks the buffer
+	// level. Zero means 5ms.
+	PollInterval time.Duration
+
+	conn      sendBufferedWriter
+	threshold time.Duration
+	policy    BackpressurePolicy
+
+	mu       sync.Mutex
+	over     bool
+	queue    [][]byte
+	dropped  int64
+	deadline time.Time
+}
+
+// NewBackpressureWriter returns a writer to c that applies policy while
+// the send buffer of c covers more than threshold.
+func NewBackpressureWriter(c *SRTConn, threshold time.Duration, policy BackpressurePolicy) *BackpressureWriter {
+	return &BackpressureWriter{conn: c, threshold: threshold, policy: policy}
+}
+
+// Write writes b to the connection according to the policy. With
+// BackpressureDropOldest it returns len(b) as soon as b is queued. With
+// BackpressureBlock it fails with a timeout error if the write deadline
+// passes while it waits for the buffer to drain.
+func (w *BackpressureWriter) Write(b []byte) (int, error) {
+	if w.policy == BackpressureBlock {
+		if err := w.waitBelow(); err != nil {
+			return 0, err
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.conn.Write(b)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	over, err := w.check()
+	if err != nil {
+		return 0, err
+	}
+	if w.policy == BackpressureDropOldest {
+		if over, err = w.flushQueue(over); err != nil {
+			return 0, err
+		}
+		if over || len(w.queue) > 0 {
+			w.enqueue(b)
+			return len(b), nil
+		}
+	}
+	return w.conn.Write(b)
+}
+
+// Flush waits until the writes queued by BackpressureDropOldest have been
+// handed to the connection, or the write deadline passes.
+func (w *BackpressureWriter) Flush() error {
+	for {
+		w.mu.Lock()
+		over, err := false, error(nil)
+		if len(w.queue) > 0 {
+			if over, err = w.check(); err == nil {
+				over, err = w.flushQueue(over)
+			}
+		}
+		deadline := w.deadline
+		w.mu.Unlock()
+		if err != nil || !over {
+			return err
+		}
+		if err := w.sleep(deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// SetWriteDeadline sets the deadline of Write and Flush, including the
+// time they wait for the send buffer to drain, and the write deadline of
+// the connection. A zero value for t means they will not time out.
+func (w *BackpressureWriter) SetWriteDeadline(t time.Time) error {
+	w.mu.Lock()
+	w.deadline = t
+	w.mu.Unlock()
+	if d, ok := w.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+// waitBelow waits until the send buffer is below the threshold. The lock
+// is not held while waiting.
+func (w *BackpressureWriter) waitBelow() error {
+	for {
+		w.mu.Lock()
+		over, err := w.check()
+		deadline := w.deadline
+		w.mu.Unlock()
+		if err != nil || !over {
+			return err
+		}
+		if err := w.sleep(deadline); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for the next poll of the buffer level, or fails if deadline
+// passes first.
+func (w *BackpressureWriter) sleep(deadline time.Time) error {
+	d := w.pollInterval()
+	if !deadline.IsZero() {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return &OpError{Op: "write", Net: "srt", Source: nil, Addr: nil, Err: poll.ErrTimeout}
+		}
+		if left < d {
+			d = left
+		}
+	}
+	time.Sleep(d)
+	return nil
+}
+
+// Queued returns the number of writes waiting in the queue.
+func (w *BackpressureWriter) Queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue)
+}
+
+// Dropped returns the number of writes dropped by BackpressureDropOldest.
+func (w *BackpressureWriter) Dropped() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// check reads the buffer level and reports threshold crossings.
+func (w *BackpressureWriter) check() (bool, error) {
+	level, err := w.conn.SendBuffered()
+	if err != nil {
+		return false, err
+	}
+	over := level.Time > w.threshold
+	if over != w.over {
+		w.over = over
+		if w.OnThreshold != nil {
+			w.OnThreshold(over, level)
+		}
+	}
+	return over, nil
+}
+
+// flushQueue writes queued data in order while the buffer is below the
+// threshold.
+func (w *BackpressureWriter) flushQueue(over bool) (bool, error) {
+	for !over && len(w.queue) > 0 {
+		if _, err := w.conn.Write(w.queue[0]); err != nil {
+			return false, err
+		}
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		var err error
+		if over, err = w.check(); err != nil {
+			return false, err
+		}
+	}
+	return over, nil
+}
+
+func (w *BackpressureWriter) enqueue(b []byte) {
+	max := w.QueueLen
+	if max <= 0 {
+		max = defaultBackpressureQueueLen
+	}
+	for len(w.queue) >= max {
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+		w.dropped++
+	}
+	w.queue = append(w.queue, append([]byte(nil), b...))
+}
+
+func (w *BackpressureWriter) pollInterval() time.Duration {
+	if w.PollInterval > 0 {
+		return w.PollInterval
+	}
+	return defaultBackpressurePollInterval
+}
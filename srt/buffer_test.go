@@ -0,0 +1,213 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBufferedConn is a sendBufferedWriter whose buffer level is set by
+// the test.
+type fakeBufferedConn struct {
+	mu      sync.Mutex
+	level   time.Duration
+	written []string
+	err     error
+}
+
+func (c *fakeBufferedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, string(b))
+	return len(b), nil
+}
+
+func (c *fakeBufferedConn) SendBuffered() (BufferLevel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return BufferLevel{Time: c.level}, c.err
+}
+
+func (c *fakeBufferedConn) setLevel(d time.Duration) {
+	c.mu.Lock()
+	c.level = d
+	c.mu.Unlock()
+}
+
+func (c *fakeBufferedConn) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.written...)
+}
+
+func TestBackpressureBlock(t *testing.T) {
+	c := &fakeBufferedConn{level: time.Second}
+	w := &BackpressureWriter{conn: c, threshold: 100 * time.Millisecond, policy: BackpressureBlock, PollInterval: time.Millisecond}
+	done := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("a"))
+		done <- err
+	}()
+	select {
+	case <-done:
+		t.Fatal("Write did not block over the threshold")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c.setLevel(0)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := c.messages(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBackpressureDropOldest(t *testing.T) {
+	c := &fakeBufferedConn{}
+	w := &BackpressureWriter{conn: c, threshold: 100 * time.Millisecond, policy: BackpressureDropOldest, QueueLen: 2}
+	w.Write([]byte("1"))
+	c.setLevel(time.Second)
+	for _, m := range []string{"2", "3", "4"} {
+		if n, err := w.Write([]byte(m)); n != 1 || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	if q, d := w.Queued(), w.Dropped(); q != 2 || d != 1 {
+		t.Errorf("queued %d, dropped %d; want 2, 1", q, d)
+	}
+	c.setLevel(0)
+	w.Write([]byte("5"))
+	if got, want := c.messages(), []string{"1", "3", "4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestBackpressureFlush(t *testing.T) {
+	c := &fakeBufferedConn{level: time.Second}
+	w := &BackpressureWriter{conn: c, threshold: 100 * time.Millisecond, policy: BackpressureDropOldest, PollInterval: time.Millisecond}
+	w.Write([]byte("1"))
+	w.Write([]byte("2"))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c.setLevel(0)
+	}()
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.messages(), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+func TestBackpressureSignal(t *testing.T) {
+	c := &fakeBufferedConn{}
+	var crossings []bool
+	w := &BackpressureWriter{
+		conn:        c,
+		threshold:   100 * time.Millisecond,
+		policy:      BackpressureSignal,
+		OnThreshold: func(over bool, level BufferLevel) { crossings = append(crossings, over) },
+	}
+	for _, level := range []time.Duration{0, 200, 300, 50, 0, 150} {
+		c.setLevel(level * time.Millisecond)
+		w.Write([]byte("x"))
+	}
+	if len(c.messages()) != 6 {
+		t.Errorf("wrote %d messages; want 6", len(c.messages()))
+	}
+	if want := []bool{true, false, true}; !reflect.DeepEqual(crossings, want) {
+		t.Errorf("got crossings %v; want %v", crossings, want)
+	}
+}
+
+func TestBackpressureError(t *testing.T) {
+	errBroken := errors.New("broken")
+	c := &fakeBufferedConn{err: errBroken}
+	w := &BackpressureWriter{conn: c, policy: BackpressureBlock}
+	if _, err := w.Write([]byte("x")); err != errBroken {
+		t.Errorf("got %v; want %v", err, errBroken)
+	}
+}
+
+func TestSendBuffered(t *testing.T) {
+	withSRTConnPair(t, func(c *SRTConn) error {
+		// Do not read, so that data piles up on the peer.
+		time.Sleep(200 * time.Millisecond)
+		level, err := c.ReceiveBuffered()
+		if err != nil {
+			return err
+		}
+		if level.Packets == 0 || level.Bytes == 0 {
+			t.Errorf("receive buffer is empty: %+v", level)
+		}
+		return nil
+	}, func(c *SRTConn) error {
+		b := make([]byte, 1316)
+		for i := 0; i < 10; i++ {
+			if _, err := c.Write(b); err != nil {
+				return err
+			}
+		}
+		level, err := c.SendBuffered()
+		if err != nil {
+			return err
+		}
+		if level.Packets < 0 || level.Packets > 10 || level.Bytes > 10*len(b) {
+			t.Errorf("unexpected send buffer level %+v", level)
+		}
+		return nil
+	})
+}
+
+func TestBackpressureBlockDeadline(t *testing.T) {
+	c := &fakeBufferedConn{level: time.Second}
+	w := &BackpressureWriter{conn: c, threshold: 100 * time.Millisecond, policy: BackpressureBlock, PollInterval: time.Millisecond}
+	if err := w.SetWriteDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("a"))
+		done <- err
+	}()
+
+	// The other methods are not blocked by the waiting Write.
+	time.Sleep(10 * time.Millisecond)
+	w.Queued()
+	w.Dropped()
+	select {
+	case err := <-done:
+		t.Fatalf("Write returned early: %v", err)
+	default:
+	}
+
+	err := <-done
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("got %v; want timeout", err)
+	}
+	if got := c.messages(); len(got) != 0 {
+		t.Errorf("got %q written", got)
+	}
+}
+
+func TestBackpressureFlushDeadline(t *testing.T) {
+	c := &fakeBufferedConn{level: time.Second}
+	w := &BackpressureWriter{conn: c, threshold: 100 * time.Millisecond, policy: BackpressureDropOldest, PollInterval: time.Millisecond}
+	if _, err := w.Write([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	w.SetWriteDeadline(time.Now().Add(20 * time.Millisecond))
+	err := w.Flush()
+	if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Fatalf("got %v; want timeout", err)
+	}
+	if w.Queued() != 1 {
+		t.Errorf("got %d queued", w.Queued())
+	}
+}
@@ -10,11 +10,14 @@ package srt
 
 import (
 	"context"
+	"log"
 	"net"
 	"time"
 
 	"github.com/openfresh/gosrt/internal/nettrace"
 	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
+	"github.com/openfresh/gosrt/srtapi"
 )
 
 // A Dialer contains options for connecting to an address.
@@ -63,6 +66,36 @@ type Dialer struct {
 
 	// Resolver optionally specifies an alternate resolver to use.
 	Resolver *Resolver
+
+	// Interface, if not empty, is the name of the network interface
+	// to dial from, as in "eth1". The local address is the address of
+	// the interface in the family of the remote address. Interface is
+	// ignored if LocalAddr is set.
+	Interface string
+
+	// BindToDevice additionally pins the socket to Interface, as with
+	// SO_BINDTODEVICE, so that traffic cannot leave through another
+	// interface. It needs Linux, libsrt 1.4.2 or later and usually
+	// the CAP_NET_RAW capability. Dial fails if it is set without
+	// Interface.
+	BindToDevice bool
+
+	// If Control is not nil, it is called after creating the SRT
+	// socket and setting the options given with WithOptions, but
+	// before binding or connecting it.
+	//
+	// Network and address parameters passed to Control method are not
+	// necessarily the ones passed to Dial. For example, passing "srt"
+	// to Dial will cause the Control function to be called with
+	// "srt4" or "srt6".
+	Control func(network, address string, c RawConn) error
+
+	// Probe, if not nil, makes a dial first probe the link as
+	// described by Probe, then connect with the recommended latency,
+	// oheadbw, fc, sndbuf and rcvbuf options. Options given with
+	// WithOptions take precedence. The probe is not bounded by
+	// Timeout.
+	Probe *LatencyProbe
 }
 
 func minNonzeroTime(a, b time.Time) time.Time {
@@ -266,6 +299,15 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 	if ctx == nil {
 		panic("nil context")
 	}
+	if d.BindToDevice && d.Interface == "" {
+		return nil, &OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: errBindToDeviceWithoutInterface}
+	}
+	if d.Probe != nil {
+		var err error
+		if ctx, err = d.adviseLatency(ctx, network, address); err != nil {
+			return nil, err
+		}
+	}
 	deadline := d.deadline(ctx, time.Now())
 	if !deadline.IsZero() {
 		if d, ok := ctx.Deadline(); !ok || deadline.Before(d) {
@@ -284,11 +326,24 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.
 		resolveCtx = context.WithValue(resolveCtx, nettrace.TraceKey{}, &shadow)
 	}
 
+	srtTrace := srttrace.ContextClientTrace(ctx)
+	if srtTrace != nil && srtTrace.ResolveStart != nil {
+		srtTrace.ResolveStart(network, address)
+	}
 	addrs, err := d.resolver().resolveAddrList(resolveCtx, "dial", network, address, d.LocalAddr)
+	if srtTrace != nil && srtTrace.ResolveDone != nil {
+		srtTrace.ResolveDone(addrs, err)
+	}
 	if err != nil {
 		return nil, &OpError{Op: "dial", Net: network, Source: nil, Addr: nil, Err: err}
 	}
 
+	if d.BindToDevice {
+		ctx = withControl(ctx, bindToDeviceControl(d.Interface, d.Control))
+	} else if d.Control != nil {
+		ctx = withControl(ctx, d.Control)
+	}
+
 	dp := &dialParam{
 		Dialer:  *d,
 		network: network,
@@ -451,6 +506,11 @@ func dialSingle(ctx context.Context, dp *dialParam, ra net.Addr) (c net.Conn, er
 	switch ra := ra.(type) {
 	case *SRTAddr:
 		la, _ := la.(*SRTAddr)
+		if la == nil && dp.Interface != "" {
+			if la, err = interfaceAddr(dp.Interface, dp.network, ra.IP, 0); err != nil {
+				return nil, &OpError{Op: "dial", Net: dp.network, Source: nil, Addr: ra, Err: err}
+			}
+		}
 		c, err = dialSRT(ctx, dp.network, la, ra)
 	default:
 		return nil, &OpError{Op: "dial", Net: dp.network, Source: la, Addr: ra, Err: &net.AddrError{Err: "unexpected address type", Addr: dp.address}}
@@ -501,3 +561,155 @@ func ListenContext(ctx context.Context, network, address string) (net.Listener,
 	}
 	return l, nil
 }
+
+// ListenConfig contains options for listening to an address.
+//
+// It is the typed counterpart of ListenContext with WithOptions and
+// WithListenCallback: the fields are applied on top of the
+// configuration carried by the context passed to Listen.
+type ListenConfig struct {
+	// Backlog is the maximum number of pending connections. If zero,
+	// the system maximum is used.
+	Backlog int
+
+	// IPv6Only restricts a listener on an IPv6 wildcard address to
+	// IPv6 callers. If false, the system default applies.
+	IPv6Only bool
+
+	// ReuseAddr allows other SRT sockets of the process to bind the
+	// same address, which is how several sockets share a UDP port.
+	// If false, the "reuseaddr" option of the context applies, and
+	// libsrt enables it by default. An explicit "reuseaddr" entry in
+	// Options takes precedence.
+	ReuseAddr bool
+
+	// Options are socket options applied to the listener.
+	Options OptionSet
+
+	// Interface, if not empty, is the name of the network interface
+	// to listen on, as in "eth1". The listener binds to the address of
+	// the interface, so the host of the address passed to Listen must
+	// be empty.
+	Interface string
+
+	// BindToDevice additionally pins the socket to Interface, as with
+	// SO_BINDTODEVICE. See Dialer.BindToDevice. Listen fails if it is
+	// set without Interface.
+	BindToDevice bool
+
+	// Callback, if not nil, is called for each caller during the
+	// handshake, as with WithListenCallback.
+	Callback srtapi.SrtListenCallbackFunc
+
+	// Allow, if not empty, restricts callers to these networks, given
+	// in CIDR notation as in "192.0.2.0/24" or as single addresses.
+	// Deny rejects callers from its networks, even if Allow has them.
+	// Such callers are rejected with RejectDenied.
+	Allow []string
+	Deny  []string
+
+	// MaxConnsPerIP and MaxConns limit the connections not yet closed,
+	// from one caller address and overall. They count the callers
+	// admitted from the handshake on, including those not accepted
+	// yet. Callers beyond the limits are rejected with
+	// RejectTooManyFromIP and RejectTooManyConns. Zero means no limit.
+	MaxConnsPerIP int
+	MaxConns      int
+
+	// HandshakeRate limits the handshakes accepted per second, with
+	// bursts of up to HandshakeBurst handshakes. Callers beyond the
+	// rate are rejected with RejectRateLimited. Zero means no limit;
+	// a zero HandshakeBurst means HandshakeRate, but at least one.
+	HandshakeRate  float64
+	HandshakeBurst int
+
+	// ErrorLog specifies an optional logger for callers rejected by
+	// the options above. If nil, logging goes to the log package's
+	// standard logger. The rejections are also counted in the Stats
+	// of the listener.
+	ErrorLog *log.Logger
+
+	// If Control is not nil, it is called after creating the SRT
+	// socket and setting the options, but before binding it.
+	//
+	// Network and address parameters passed to Control method are not
+	// necessarily the ones passed to Listen. For example, passing "srt"
+	// to Listen will cause the Control function to be called with
+	// "srt4" or "srt6".
+	Control func(network, address string, c RawConn) error
+}
+
+// Listen announces on the local network address.
+//
+// See func ListenContext for a description of the network and address
+// parameters.
+func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (net.Listener, error) {
+	if lc.BindToDevice && lc.Interface == "" {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: errBindToDeviceWithoutInterface}
+	}
+	options := OptionSet{}
+	if lc.IPv6Only {
+		options.list = append(options.list, option{key: "ipv6only", value: "1"})
+	}
+	if lc.ReuseAddr {
+		options.list = append(options.list, option{key: "reuseaddr", value: "true"})
+	}
+	options.list = append(options.list, lc.Options.list...)
+	ctx = WithOptions(ctx, options)
+	if lc.Backlog > 0 {
+		ctx = context.WithValue(ctx, listenBacklogContextKey{}, lc.Backlog)
+	}
+	if lc.Callback != nil {
+		ctx = WithListenCallback(ctx, lc.Callback)
+	}
+	ac, err := lc.accessControl()
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+	if ac != nil {
+		ctx = context.WithValue(ctx, accessControlContextKey{}, ac)
+	}
+	if lc.BindToDevice {
+		ctx = withControl(ctx, bindToDeviceControl(lc.Interface, lc.Control))
+	} else if lc.Control != nil {
+		ctx = withControl(ctx, lc.Control)
+	}
+	if lc.Interface != "" {
+		if address, err = lc.interfaceAddress(network, address); err != nil {
+			return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: err}
+		}
+	}
+	return ListenContext(ctx, network, address)
+}
+
+// interfaceAddress replaces the empty host of address with the address of
+// lc.Interface.
+func (lc *ListenConfig) interfaceAddress(network, address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if host != "" {
+		return "", &net.AddrError{Err: "host given with Interface", Addr: address}
+	}
+	la, err := interfaceAddr(lc.Interface, network, nil, 0)
+	if err != nil {
+		return "", err
+	}
+	host = la.IP.String()
+	if la.Zone != "" {
+		host += "%" + la.Zone
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// listenBacklogContextKey is the type of contextKeys used for the backlog
+// of a ListenConfig.
+type listenBacklogContextKey struct{}
+
+func listenBacklogValue(ctx context.Context) int {
+	if backlog, ok := ctx.Value(listenBacklogContextKey{}).(int); ok {
+		return backlog
+	}
+	return listenerBacklog
+}
@@ -9,6 +9,7 @@ package srt
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net"
 	"runtime"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/openfresh/gosrt/internal/poll"
 	"github.com/openfresh/gosrt/internal/testenv"
+	"github.com/openfresh/gosrt/srtapi"
 )
 
 var prohibitionaryDialArgTests = []struct {
@@ -563,3 +565,67 @@ func TestDialListenerAddr(t *testing.T) {
 	}
 	c.Close()
 }
+
+func TestDialerControl(t *testing.T) {
+	t.Run("StreamDial", func(t *testing.T) {
+		for _, network := range []string{"srt", "srt4", "srt6"} {
+			if !testableNetwork(network) {
+				continue
+			}
+			ln, err := newLocalListener(network)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			var called string
+			d := Dialer{Control: func(network, address string, c RawConn) error {
+				called = network
+				var err error
+				if cerr := c.Control(func(s int) {
+					err = srtapi.SetsockflagInt(s, srtapi.OptionLatency, 321)
+				}); cerr != nil {
+					return cerr
+				}
+				return err
+			}}
+			c, err := d.Dial(network, ln.Addr().String())
+			if err != nil {
+				t.Error(err)
+				ln.Close()
+				continue
+			}
+			if called != "srt4" && called != "srt6" {
+				t.Errorf("Control called with network %q", called)
+			}
+			rc, err := c.(*SRTConn).SyscallConn()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var latency int
+			rc.Control(func(s int) {
+				latency, err = srtapi.GetsockflagInt(s, srtapi.OptionLatency)
+			})
+			if err != nil || latency != 321 {
+				t.Errorf("got latency %d, %v; want 321", latency, err)
+			}
+			c.Close()
+			ln.Close()
+			if err := rc.Control(func(int) {}); err == nil {
+				t.Error("Control on a closed connection succeeded")
+			}
+		}
+	})
+	t.Run("Error", func(t *testing.T) {
+		ln, err := newLocalListener("srt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		errRefused := errors.New("refused by control")
+		d := Dialer{Control: func(network, address string, c RawConn) error { return errRefused }}
+		_, err = d.Dial("srt", ln.Addr().String())
+		if perr, ok := err.(*OpError); !ok || perr.Err != errRefused {
+			t.Errorf("got %v; want %v", err, errRefused)
+		}
+	})
+}
@@ -0,0 +1,329 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+)
+
+// Defaults of FailoverReader.
+const (
+	defaultFailoverTimeout      = 500 * time.Millisecond
+	defaultFailoverStablePeriod = 5 * time.Second
+	failoverMaxMessage          = 1500
+)
+
+// MPEG-TS framing.
+const (
+	tsPacketSize = 188
+	tsSyncByte   = 0x47
+)
+
+// FailoverEvent describes a switch of a FailoverReader from one input to
+// another.
+type FailoverEvent struct {
+	// From and To are the indexes of the inputs, in the order given to
+	// NewFailoverReader.
+	From int
+	To   int
+
+	// Reason is "silence" when From stopped delivering data for
+	// Timeout or left the connected state, "broken" when reading From
+	// failed and "recovered" when the higher priority input To has been
+	// stable for StablePeriod.
+	Reason string
+
+	// Err is the error that broke From, if any.
+	Err error
+}
+
+// A FailoverReader reads from one of several inputs carrying the same
+// stream, such as the feeds of a main and a backup encoder. It reads the
+// input with the highest priority that delivers data, switches to the
+// next one when it goes silent or breaks, and switches back once it has
+// been stable again for a while.
+//
+// Each message read from an input is expected to hold whole MPEG-TS
+// packets, as with live mode. The first message after a switch is cut to
+// the first TS packet boundary, so that the output never holds a partial
+// packet.
+//
+// The exported fields must not be changed after the first call to Read.
+type FailoverReader struct {
+	// Timeout is how long the active input may stay silent before the
+	// reader switches away from it. Zero means 500ms.
+	Timeout time.Duration
+
+	// StablePeriod is how long a higher priority input must deliver
+	// data without interruption before the reader switches back to it.
+	// Zero means 5s.
+	StablePeriod time.Duration
+
+	// OnSwitch, if non-nil, is called on each switch. It is called from
+	// a goroutine of the reader and should return quickly.
+	OnSwitch func(e FailoverEvent)
+
+	inputs []*failoverInput
+	msgs   chan failoverMessage
+	done   chan struct{}
+	once   sync.Once
+	start  sync.Once
+
+	readMu sync.Mutex // serializes Read
+
+	mu      sync.Mutex
+	active  int
+	aligned bool // whether the active input delivered since the switch
+	down    int
+}
+
+type failoverInput struct {
+	conn         net.Conn
+	lastRead     time.Time
+	healthySince time.Time
+	err          error
+}
+
+type failoverMessage struct {
+	input int
+	b     []byte
+}
+
+// NewFailoverReader returns a reader of conns, given in decreasing order
+// of priority. Reading starts with the first call to Read.
+func NewFailoverReader(conns ...net.Conn) *FailoverReader {
+	r := &FailoverReader{
+		msgs: make(chan failoverMessage),
+		done: make(chan struct{}),
+	}
+	for _, c := range conns {
+		r.inputs = append(r.inputs, &failoverInput{conn: c})
+	}
+	return r
+}
+
+// Active returns the index of the input being read.
+func (r *FailoverReader) Active() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// Read reads the next message of the active input into b. A message
+// longer than b is truncated. Read fails once all inputs are broken.
+func (r *FailoverReader) Read(b []byte) (int, error) {
+	r.start.Do(r.run)
+	r.readMu.Lock()
+	defer r.readMu.Unlock()
+	for {
+		select {
+		case m := <-r.msgs:
+			r.mu.Lock()
+			if m.input != r.active {
+				r.mu.Unlock()
+				continue
+			}
+			msg := m.b
+			if !r.aligned {
+				msg = alignTS(msg)
+				if len(msg) == 0 {
+					r.mu.Unlock()
+					continue
+				}
+				r.aligned = true
+			}
+			r.mu.Unlock()
+			return copy(b, msg), nil
+		case <-r.done:
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.down == len(r.inputs) {
+				return 0, r.lastErrorLocked()
+			}
+			return 0, &OpError{Op: "read", Net: "srt", Source: nil, Addr: nil, Err: poll.ErrNetClosing}
+		}
+	}
+}
+
+// Close closes all the inputs.
+func (r *FailoverReader) Close() error {
+	r.once.Do(func() { close(r.done) })
+	var err error
+	for _, in := range r.inputs {
+		if cerr := in.conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// run starts reading the inputs and watching their health.
+func (r *FailoverReader) run() {
+	now := time.Now()
+	for i, in := range r.inputs {
+		in.lastRead, in.healthySince = now, now
+		go r.readInput(i)
+	}
+	go r.watch()
+}
+
+// readInput reads the messages of input i until it fails, passing on
+// those of the active input.
+func (r *FailoverReader) readInput(i int) {
+	in := r.inputs[i]
+	for {
+		b := make([]byte, failoverMaxMessage)
+		n, err := in.conn.Read(b)
+		now := time.Now()
+		r.mu.Lock()
+		if err != nil {
+			in.err = err
+			r.down++
+			all := r.down == len(r.inputs)
+			var e *FailoverEvent
+			if i == r.active {
+				e = r.failoverLocked("broken", err)
+			}
+			r.mu.Unlock()
+			r.emit(e)
+			if all {
+				r.once.Do(func() { close(r.done) })
+			}
+			return
+		}
+		if now.Sub(in.lastRead) > r.timeout() {
+			in.healthySince = now
+		}
+		in.lastRead = now
+		active := i == r.active
+		r.mu.Unlock()
+		if !active {
+			continue
+		}
+		select {
+		case r.msgs <- failoverMessage{input: i, b: b[:n]}:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// watch checks the health of the inputs until the reader is closed.
+func (r *FailoverReader) watch() {
+	ticker := time.NewTicker(r.timeout() / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			e := r.checkLocked(now)
+			r.mu.Unlock()
+			r.emit(e)
+		}
+	}
+}
+
+// checkLocked switches away from a silent active input, or back to a
+// higher priority input that has been stable for StablePeriod.
+func (r *FailoverReader) checkLocked(now time.Time) *FailoverEvent {
+	if !r.healthyLocked(r.active, now) {
+		return r.failoverLocked("silence", nil)
+	}
+	for i := 0; i < r.active; i++ {
+		if r.healthyLocked(i, now) && now.Sub(r.inputs[i].healthySince) >= r.stablePeriod() {
+			return r.switchLocked(i, "recovered", nil)
+		}
+	}
+	return nil
+}
+
+// failoverLocked switches to the healthy input with the highest priority
+// other than the active one.
+func (r *FailoverReader) failoverLocked(reason string, err error) *FailoverEvent {
+	now := time.Now()
+	for i := range r.inputs {
+		if i != r.active && r.healthyLocked(i, now) {
+			return r.switchLocked(i, reason, err)
+		}
+	}
+	return nil
+}
+
+func (r *FailoverReader) switchLocked(to int, reason string, err error) *FailoverEvent {
+	e := &FailoverEvent{From: r.active, To: to, Reason: reason, Err: err}
+	r.active, r.aligned = to, false
+	return e
+}
+
+// healthyLocked reports whether input i delivered data within Timeout and,
+// for an *SRTConn, is still connected.
+func (r *FailoverReader) healthyLocked(i int, now time.Time) bool {
+	in := r.inputs[i]
+	if in.err != nil || now.Sub(in.lastRead) > r.timeout() {
+		return false
+	}
+	if c, ok := in.conn.(*SRTConn); ok && c.ok() {
+		return State(getsockstateFunc(c.fd.pfd.Sysfd)) == StateConnected
+	}
+	return true
+}
+
+func (r *FailoverReader) emit(e *FailoverEvent) {
+	if e != nil && r.OnSwitch != nil {
+		r.OnSwitch(*e)
+	}
+}
+
+func (r *FailoverReader) lastErrorLocked() error {
+	var err error = io.EOF
+	for _, in := range r.inputs {
+		if in.err != nil && in.err != io.EOF {
+			err = &OpError{Op: "read", Net: "srt", Source: nil, Addr: in.conn.RemoteAddr(), Err: in.err}
+		}
+	}
+	return err
+}
+
+func (r *FailoverReader) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	return defaultFailoverTimeout
+}
+
+func (r *FailoverReader) stablePeriod() time.Duration {
+	if r.StablePeriod > 0 {
+		return r.StablePeriod
+	}
+	return defaultFailoverStablePeriod
+}
+
+// alignTS returns the part of b that starts at the first TS packet
+// boundary and holds whole TS packets only.
+func alignTS(b []byte) []byte {
+	for off := 0; off < tsPacketSize && off < len(b); off++ {
+		n := (len(b) - off) / tsPacketSize
+		if n == 0 {
+			break
+		}
+		ok := true
+		for k := 0; k < n; k++ {
+			if b[off+k*tsPacketSize] != tsSyncByte {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return b[off : off+n*tsPacketSize]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tsMessage(packets int, tag byte) []byte {
+	b := make([]byte, packets*tsPacketSize)
+	for i := 0; i < len(b); i += tsPacketSize {
+		b[i], b[i+1] = tsSyncByte, tag
+	}
+	return b
+}
+
+var alignTSTests = []struct {
+	in   []byte
+	want []byte
+}{
+	{tsMessage(7, 1), tsMessage(7, 1)},
+	{append([]byte{1, 2, 3}, tsMessage(2, 1)...), tsMessage(2, 1)},
+	{append(tsMessage(2, 1), 4, 5), tsMessage(2, 1)},
+	{[]byte{tsSyncByte, 1, 2}, nil},
+	{make([]byte, 2*tsPacketSize), nil},
+	{nil, nil},
+}
+
+func TestAlignTS(t *testing.T) {
+	for i, tt := range alignTSTests {
+		if got := alignTS(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("#%d: got %d bytes; want %d", i, len(got), len(tt.want))
+		}
+	}
+}
+
+// feed writes a TS message tagged with tag to c every few milliseconds
+// while paused is zero, until the write fails.
+func feed(c net.Conn, tag byte, paused *int32) {
+	for {
+		time.Sleep(2 * time.Millisecond)
+		if atomic.LoadInt32(paused) != 0 {
+			continue
+		}
+		if _, err := c.Write(tsMessage(7, tag)); err != nil {
+			return
+		}
+	}
+}
+
+// readTag reads from r until a message tagged with tag.
+func readTag(t *testing.T, r *FailoverReader, tag byte) {
+	b := make([]byte, failoverMaxMessage)
+	for {
+		n, err := r.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 7*tsPacketSize || b[0] != tsSyncByte {
+			t.Fatalf("got misaligned message of %d bytes", n)
+		}
+		if b[1] == tag {
+			return
+		}
+	}
+}
+
+func TestFailoverReader(t *testing.T) {
+	var mainPaused, backupPaused int32
+	mainW, mainR := net.Pipe()
+	backupW, backupR := net.Pipe()
+	defer mainW.Close()
+	defer backupW.Close()
+	go feed(mainW, 1, &mainPaused)
+	go feed(backupW, 2, &backupPaused)
+
+	events := make(chan FailoverEvent, 10)
+	r := NewFailoverReader(mainR, backupR)
+	r.Timeout = 50 * time.Millisecond
+	r.StablePeriod = 200 * time.Millisecond
+	r.OnSwitch = func(e FailoverEvent) { events <- e }
+	defer r.Close()
+
+	readTag(t, r, 1)
+	atomic.StoreInt32(&mainPaused, 1)
+	readTag(t, r, 2)
+	if e := <-events; e.From != 0 || e.To != 1 || e.Reason != "silence" {
+		t.Errorf("got %+v; want switch from 0 to 1 on silence", e)
+	}
+
+	atomic.StoreInt32(&mainPaused, 0)
+	start := time.Now()
+	readTag(t, r, 1)
+	if d := time.Since(start); d < r.StablePeriod {
+		t.Errorf("switched back after %v; want at least %v", d, r.StablePeriod)
+	}
+	if e := <-events; e.From != 1 || e.To != 0 || e.Reason != "recovered" {
+		t.Errorf("got %+v; want switch from 1 to 0 on recovery", e)
+	}
+	if r.Active() != 0 {
+		t.Errorf("active input %d; want 0", r.Active())
+	}
+}
+
+func TestFailoverReaderBroken(t *testing.T) {
+	var paused int32
+	mainW, mainR := net.Pipe()
+	backupW, backupR := net.Pipe()
+	go feed(mainW, 1, &paused)
+	go feed(backupW, 2, &paused)
+
+	events := make(chan FailoverEvent, 10)
+	r := NewFailoverReader(mainR, backupR)
+	r.OnSwitch = func(e FailoverEvent) { events <- e }
+	defer r.Close()
+
+	readTag(t, r, 1)
+	mainW.Close()
+	readTag(t, r, 2)
+	if e := <-events; e.To != 1 || e.Reason != "broken" || e.Err != io.EOF {
+		t.Errorf("got %+v; want switch to 1 on EOF", e)
+	}
+
+	backupW.Close()
+	b := make([]byte, failoverMaxMessage)
+	for {
+		if _, err := r.Read(b); err != nil {
+			if err != io.EOF {
+				t.Errorf("got %v; want EOF", err)
+			}
+			break
+		}
+	}
+}
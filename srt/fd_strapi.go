@@ -14,14 +14,22 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
 	"github.com/openfresh/gosrt/srtapi"
 )
 
 // Network file descriptor.
 type netFD struct {
+	// written is the number of bytes written, accessed atomically. It
+	// comes first to be 64-bit aligned.
+	written int64
+
 	pfd poll.FD
 
 	// immutable until Close
@@ -31,6 +39,23 @@ type netFD struct {
 	net         string
 	laddr       net.Addr
 	raddr       net.Addr
+
+	// state change notification
+	states stateNotifier
+
+	// closeMu guards closeTrace and onClose, which the first Close
+	// takes so that concurrent Closes call them once
+	closeMu sync.Mutex
+
+	// closeTrace, if not nil, is called when fd is closed
+	closeTrace func(srttrace.CloseInfo)
+
+	// onClose, if not nil, is called when fd is closed
+	onClose func()
+
+	// listenServer holds the *Server serving a listener, which its
+	// listen callback consults
+	listenServer atomic.Value
 }
 
 func newFD(sysfd, family, sotype int, net string) (*netFD, error) {
@@ -70,8 +95,16 @@ func (fd *netFD) connect(ctx context.Context, la, ra syscall.Sockaddr) (rsa sysc
 	default:
 		return nil, fmt.Errorf("unexpected socket state %d", state)
 	}
+	return nil, fd.waitConnect(ctx, func() (int, error) {
+		return getsockoptIntFunc(fd.pfd.Sysfd, 0, srtapi.OptionState)
+	})
+}
+
+// waitConnect waits until the state returned by state is no longer
+// connecting.
+func (fd *netFD) waitConnect(ctx context.Context, state func() (int, error)) (ret error) {
 	if err := fd.pfd.Init(fd.net, true); err != nil {
-		return nil, err
+		return err
 	}
 	if deadline, _ := ctx.Deadline(); !deadline.IsZero() {
 		fd.pfd.SetWriteDeadline(deadline)
@@ -121,28 +154,71 @@ func (fd *netFD) connect(ctx context.Context, la, ra syscall.Sockaddr) (rsa sysc
 		if err := fd.pfd.WaitWrite(); err != nil {
 			select {
 			case <-ctx.Done():
-				return nil, mapErr(ctx.Err())
+				return mapErr(ctx.Err())
 			default:
 			}
-			return nil, err
+			return err
 		}
-		state, err := getsockoptIntFunc(fd.pfd.Sysfd, 0, srtapi.OptionState)
+		st, err := state()
 		if err != nil {
-			return nil, os.NewSyscallError("getsockopt", err)
+			return os.NewSyscallError("getsockopt", err)
 		}
-		switch state {
+		switch st {
 		case srtapi.StatusConnecting:
 		case srtapi.StatusConnected:
-			return nil, nil
+			return nil
 		default:
-			return nil, fmt.Errorf("unexpected socket state %d", state)
+			return fmt.Errorf("unexpected socket state %d", st)
 		}
 	}
 }
 
 func (fd *netFD) Close() error {
 	runtime.SetFinalizer(fd, nil)
-	return fd.pfd.Close()
+	fd.closeMu.Lock()
+	trace, onClose := fd.closeTrace, fd.onClose
+	fd.closeTrace, fd.onClose = nil, nil
+	fd.closeMu.Unlock()
+	var broken bool
+	if trace != nil {
+		broken = State(getsockstateFunc(fd.pfd.Sysfd)) == StateBroken
+	}
+	err := fd.pfd.Close()
+	fd.states.update(StateClosed)
+	if onClose != nil {
+		onClose()
+	}
+	if trace != nil {
+		trace(srttrace.CloseInfo{LocalAddr: fd.laddr, RemoteAddr: fd.raddr, Broken: broken, Err: err})
+	}
+	return err
+}
+
+// drainInterval is how often drain checks the send buffer.
+var drainInterval = 10 * time.Millisecond
+
+// drain waits until the peer has acknowledged all the data in the send
+// buffer of fd or ctx is done.
+func (fd *netFD) drain(ctx context.Context) error {
+	t := time.NewTicker(drainInterval)
+	defer t.Stop()
+	for {
+		n, err := getsockoptIntFunc(fd.pfd.Sysfd, 0, srtapi.OptionSnddata)
+		if err != nil {
+			return os.NewSyscallError("getsockopt", err)
+		}
+		if n == 0 {
+			return nil
+		}
+		if State(getsockstateFunc(fd.pfd.Sysfd)) != StateConnected {
+			return srtapi.ECONNLOST
+		}
+		select {
+		case <-ctx.Done():
+			return mapErr(ctx.Err())
+		case <-t.C:
+		}
+	}
 }
 
 func (fd *netFD) Read(p []byte) (n int, err error) {
@@ -152,6 +228,9 @@ func (fd *netFD) Read(p []byte) (n int, err error) {
 
 func (fd *netFD) Write(p []byte) (nn int, err error) {
 	nn, err = fd.pfd.Write(p)
+	if nn > 0 {
+		atomic.AddInt64(&fd.written, int64(nn))
+	}
 	return nn, wrapSyscallError("write", err)
 }
 
@@ -0,0 +1,196 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// FECLayout is the arrangement of the column groups of the FEC filter.
+type FECLayout string
+
+// FEC layouts.
+const (
+	// FECEven makes the column groups start on the same row.
+	FECEven FECLayout = "even"
+
+	// FECStaggered makes the column groups start on consecutive rows,
+	// which spreads the column FEC packets over time.
+	FECStaggered FECLayout = "staggered"
+)
+
+// ARQMode tells when the FEC filter lets the receiver request the
+// retransmission of lost packets.
+type ARQMode string
+
+// ARQ modes.
+const (
+	// ARQAlways requests retransmission as soon as a loss is detected,
+	// as without FEC.
+	ARQAlways ARQMode = "always"
+
+	// ARQOnRequest requests retransmission of the packets FEC could not
+	// recover.
+	ARQOnRequest ARQMode = "onreq"
+
+	// ARQNever never requests retransmission.
+	ARQNever ARQMode = "never"
+)
+
+// FECConfig is the configuration of the builtin FEC packet filter, which
+// is set with the packetfilter option. Zero fields, except Cols, leave
+// the libsrt default: one row, FECEven and ARQOnRequest.
+type FECConfig struct {
+	// Cols is the number of packets of a row group.
+	Cols int
+
+	// Rows is the number of packets of a column group. One turns off
+	// the column groups.
+	Rows int
+
+	Layout FECLayout
+	ARQ    ARQMode
+}
+
+// Validate reports whether libsrt accepts c.
+func (c FECConfig) Validate() error {
+	if c.Cols < 1 {
+		return &OptionError{Key: "packetfilter", Value: c.String(), Err: "cols less than 1"}
+	}
+	if c.Rows < 0 {
+		return &OptionError{Key: "packetfilter", Value: c.String(), Err: "negative rows"}
+	}
+	switch c.Layout {
+	case "", FECEven, FECStaggered:
+	default:
+		return &OptionError{Key: "packetfilter", Value: c.String(), Err: "unknown layout " + string(c.Layout)}
+	}
+	switch c.ARQ {
+	case "", ARQAlways, ARQOnRequest, ARQNever:
+	default:
+		return &OptionError{Key: "packetfilter", Value: c.String(), Err: "unknown arq mode " + string(c.ARQ)}
+	}
+	return nil
+}
+
+// String returns the packetfilter option value of c, such as
+// "fec,cols:10,rows:5,layout:staggered,arq:onreq".
+func (c FECConfig) String() string {
+	s := "fec,cols:" + strconv.Itoa(c.Cols)
+	if c.Rows != 0 {
+		s += ",rows:" + strconv.Itoa(c.Rows)
+	}
+	if c.Layout != "" {
+		s += ",layout:" + string(c.Layout)
+	}
+	if c.ARQ != "" {
+		s += ",arq:" + string(c.ARQ)
+	}
+	return s
+}
+
+// Options returns the option set holding the packetfilter option for c.
+func (c FECConfig) Options() (OptionSet, error) {
+	if err := c.Validate(); err != nil {
+		return OptionSet{}, err
+	}
+	return Options("packetfilter", c.String()), nil
+}
+
+// ParseFECConfig parses a packetfilter option value of the FEC filter.
+func ParseFECConfig(s string) (FECConfig, error) {
+	var c FECConfig
+	fields := strings.Split(s, ",")
+	if fields[0] != "fec" {
+		return c, &OptionError{Key: "packetfilter", Value: s, Err: "not an fec filter"}
+	}
+	for _, f := range fields[1:] {
+		i := strings.IndexByte(f, ':')
+		if i < 0 {
+			return c, &OptionError{Key: "packetfilter", Value: s, Err: "malformed parameter " + f}
+		}
+		k, v := f[:i], f[i+1:]
+		var err error
+		switch k {
+		case "cols":
+			c.Cols, err = strconv.Atoi(v)
+		case "rows":
+			c.Rows, err = strconv.Atoi(v)
+		case "layout":
+			c.Layout = FECLayout(v)
+		case "arq":
+			c.ARQ = ARQMode(v)
+		default:
+			return c, &OptionError{Key: "packetfilter", Value: s, Err: "unknown parameter " + k}
+		}
+		if err != nil {
+			return c, &OptionError{Key: "packetfilter", Value: s, Err: "invalid " + k}
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return c, &OptionError{Key: "packetfilter", Value: s, Err: err.(*OptionError).Err}
+	}
+	return c, nil
+}
+
+// FEC returns the packet filter configuration negotiated with the peer,
+// or nil if the connection does not use the FEC filter.
+func (c *SRTConn) FEC() (*FECConfig, error) {
+	if !c.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	s, err := srtapi.GetsockflagString(c.fd.pfd.Sysfd, srtapi.OptionPacketfilter)
+	if err != nil {
+		return nil, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_getsockflag", err)}
+	}
+	if !strings.HasPrefix(s, "fec,") {
+		return nil, nil
+	}
+	fc, err := ParseFECConfig(s)
+	if err != nil {
+		return nil, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return &fc, nil
+}
+
+// FECStats are the counters of the packet filter of a connection since
+// it was established.
+type FECStats struct {
+	// SentExtra is the number of FEC packets sent.
+	SentExtra int
+
+	// ReceivedExtra is the number of FEC packets received.
+	ReceivedExtra int
+
+	// Recovered is the number of lost packets rebuilt from FEC packets.
+	Recovered int
+
+	// Unrecovered is the number of lost packets FEC could not rebuild,
+	// which are left to retransmission according to the ARQ mode.
+	Unrecovered int
+}
+
+// FECStats returns the packet filter counters of the connection. The
+// counters are also in Stats as packetsFilterExtra, packetsFilterSupply
+// and packetsFilterLoss, but there they are reset by each call when full
+// stats are off.
+func (c *SRTConn) FECStats() (FECStats, error) {
+	if !c.ok() {
+		return FECStats{}, srtapi.EINVPARAM
+	}
+	st, err := getbstatsFunc(c.fd.pfd.Sysfd)
+	if err != nil {
+		return FECStats{}, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_bstats", err)}
+	}
+	return FECStats{
+		SentExtra:     st.PktSndFilterExtraTotal,
+		ReceivedExtra: st.PktRcvFilterExtraTotal,
+		Recovered:     st.PktRcvFilterSupplyTotal,
+		Unrecovered:   st.PktRcvFilterLossTotal,
+	}, nil
+}
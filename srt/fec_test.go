@@ -0,0 +1,130 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+var fecConfigTests = []struct {
+	s   string
+	c   FECConfig
+	err bool
+}{
+	{"fec,cols:10", FECConfig{Cols: 10}, false},
+	{"fec,cols:10,rows:5,layout:staggered,arq:onreq", FECConfig{Cols: 10, Rows: 5, Layout: FECStaggered, ARQ: ARQOnRequest}, false},
+	{"fec,cols:4,rows:1,arq:never", FECConfig{Cols: 4, Rows: 1, ARQ: ARQNever}, false},
+	{"fec", FECConfig{}, true},
+	{"fec,cols:0", FECConfig{}, true},
+	{"fec,cols:10,rows:-5", FECConfig{}, true},
+	{"fec,cols:ten", FECConfig{}, true},
+	{"fec,cols:10,layout:diagonal", FECConfig{}, true},
+	{"fec,cols:10,arq:sometimes", FECConfig{}, true},
+	{"fec,cols:10,colums:5", FECConfig{}, true},
+	{"fec,cols", FECConfig{}, true},
+	{"rs,cols:10", FECConfig{}, true},
+}
+
+func TestFECConfig(t *testing.T) {
+	for _, tt := range fecConfigTests {
+		c, err := ParseFECConfig(tt.s)
+		if tt.err {
+			if _, ok := err.(*OptionError); !ok {
+				t.Errorf("%q: got %v; want OptionError", tt.s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+			continue
+		}
+		if c != tt.c {
+			t.Errorf("%q: got %+v; want %+v", tt.s, c, tt.c)
+		}
+		if s := c.String(); s != tt.s {
+			t.Errorf("got %q; want %q", s, tt.s)
+		}
+	}
+}
+
+func TestFECConfigOptions(t *testing.T) {
+	if _, err := (FECConfig{Cols: 10, Layout: "diagonal"}).Options(); err == nil {
+		t.Error("invalid config accepted")
+	}
+	fec, err := FECConfig{Cols: 10, Rows: 5}.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := Option(WithOptions(context.Background(), fec), "packetfilter"); v != "fec,cols:10,rows:5" {
+		t.Errorf("packetfilter = %q", v)
+	}
+	if _, err := PresetLive.Options(fec); err != nil {
+		t.Error(err)
+	}
+	if _, err := PresetFile.Options(fec); err == nil {
+		t.Error("fec accepted in file mode")
+	}
+	if _, err := PresetLive.Options(Options("packetfilter", "fec,cols:10,rows:x")); err == nil {
+		t.Error("malformed fec filter accepted")
+	}
+}
+
+func TestFECStats(t *testing.T) {
+	orig := getbstatsFunc
+	defer func() { getbstatsFunc = orig }()
+	getbstatsFunc = func(fd int) (srtapi.Bstats, error) {
+		return srtapi.Bstats{PktSndFilterExtraTotal: 1, PktRcvFilterExtraTotal: 2, PktRcvFilterSupplyTotal: 3, PktRcvFilterLossTotal: 4}, nil
+	}
+	c := newSRTConn(&netFD{net: "srt"})
+	st, err := c.FECStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (FECStats{SentExtra: 1, ReceivedExtra: 2, Recovered: 3, Unrecovered: 4}); st != want {
+		t.Errorf("got %+v; want %+v", st, want)
+	}
+	getbstatsFunc = func(fd int) (srtapi.Bstats, error) {
+		return srtapi.Bstats{}, errors.New("bstats failed")
+	}
+	if _, err := c.FECStats(); err == nil {
+		t.Error("error not reported")
+	}
+}
+
+func TestFECNegotiated(t *testing.T) {
+	fec, err := FECConfig{Cols: 4, Rows: 2}.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithOptions(context.Background(), fec)
+	ln, err := newLocalListenerContext(ctx, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			defer c.Close()
+			c.Read(make([]byte, 1))
+		}
+	}()
+	var d Dialer
+	c, err := d.DialContext(ctx, ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	fc, err := c.(*SRTConn).FEC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fc == nil || fc.Cols != 4 || fc.Rows != 2 {
+		t.Errorf("negotiated %+v", fc)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// +build srtgroups
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+var (
+	// Placeholders for group srt calls.
+	creategroupFunc  = srtapi.CreateGroup
+	connectgroupFunc = srtapi.ConnectGroup
+	groupdataFunc    = srtapi.GroupData
+)
+
+var errNotGroup = errors.New("not a socket group")
+
+func init() {
+	srtOptions = append(srtOptions,
+		socketOption{"groupconnect", 0, srtapi.OptionGroupconnect, bindPre, typeInt},
+		socketOption{"groupstabtimeo", 0, srtapi.OptionGroupstabtimeo, bindPre, typeInt},
+	)
+}
+
+// GroupMode is the way a socket group uses its member connections.
+type GroupMode int
+
+// Group modes.
+const (
+	// GroupBroadcast sends every packet over all the members and
+	// delivers the first copy received.
+	GroupBroadcast GroupMode = srtapi.GroupBroadcast
+
+	// GroupBackup sends over the member with the highest weight and
+	// switches to another member when it becomes unstable.
+	GroupBackup GroupMode = srtapi.GroupBackup
+)
+
+func (m GroupMode) String() string {
+	switch m {
+	case GroupBroadcast:
+		return "broadcast"
+	case GroupBackup:
+		return "backup"
+	}
+	return fmt.Sprintf("GroupMode(%d)", int(m))
+}
+
+// MemberState is the state of a member connection within its group.
+type MemberState int
+
+// Member states.
+const (
+	MemberPending MemberState = srtapi.MemberPending
+	MemberIdle    MemberState = srtapi.MemberIdle
+	MemberRunning MemberState = srtapi.MemberRunning
+	MemberBroken  MemberState = srtapi.MemberBroken
+)
+
+func (s MemberState) String() string {
+	switch s {
+	case MemberPending:
+		return "pending"
+	case MemberIdle:
+		return "idle"
+	case MemberRunning:
+		return "running"
+	case MemberBroken:
+		return "broken"
+	}
+	return fmt.Sprintf("MemberState(%d)", int(s))
+}
+
+// GroupMember is a member connection for DialGroup.
+type GroupMember struct {
+	// Address is the remote address, as in "host:port".
+	Address string
+
+	// LocalAddr, if not nil, is the local address to connect from,
+	// which selects the network the member goes through.
+	LocalAddr net.Addr
+
+	// Weight is the priority of the member in GroupBackup mode; the
+	// member with the highest weight is used first.
+	Weight int
+}
+
+// GroupMemberStatus is the state and statistics of a member connection
+// of a group.
+type GroupMemberStatus struct {
+	RemoteAddr net.Addr
+	State      State
+	Member     MemberState
+	Weight     int
+
+	// RTT, Bandwidth, Lost and Dropped are the round trip time, the
+	// estimated link bandwidth in Mbps and the number of packets lost
+	// and dropped by the sender on the member connection.
+	RTT       time.Duration
+	Bandwidth float64
+	Lost      int64
+	Dropped   int64
+}
+
+// DialGroup connects a socket group of the given mode to the members and
+// returns it as a connection whose reads and writes go over the members.
+// It returns once a member is connected; the others keep connecting in
+// the background. The options carried by ctx apply to every member.
+//
+// The peer must be listening with the groupconnect option set to 1.
+// Socket groups need libsrt built with bonding support and gosrt built
+// with the srtgroups tag.
+func DialGroup(ctx context.Context, mode GroupMode, members ...GroupMember) (*SRTConn, error) {
+	if len(members) == 0 {
+		return nil, &OpError{Op: "dial", Net: "srt", Source: nil, Addr: nil, Err: errMissingAddress}
+	}
+	endpoints := make([]srtapi.GroupEndpoint, len(members))
+	var family int
+	var raddr net.Addr
+	for i, m := range members {
+		ep, f, ra, err := groupEndpoint(ctx, m)
+		if err != nil {
+			return nil, &OpError{Op: "dial", Net: "srt", Source: m.LocalAddr, Addr: nil, Err: err}
+		}
+		if i == 0 {
+			family, raddr = f, ra
+		}
+		endpoints[i] = ep
+	}
+	fd, err := dialGroup(ctx, mode, family, endpoints)
+	if err != nil {
+		return nil, &OpError{Op: "dial", Net: "srt", Source: nil, Addr: raddr, Err: err}
+	}
+	fd.setAddr(nil, raddr)
+	return newSRTConn(fd), nil
+}
+
+// groupEndpoint resolves the addresses of m.
+func groupEndpoint(ctx context.Context, m GroupMember) (ep srtapi.GroupEndpoint, family int, raddr *SRTAddr, err error) {
+	addrs, err := DefaultResolver.resolveAddrList(ctx, "dial", "srt", m.Address, m.LocalAddr)
+	if err != nil {
+		return ep, 0, nil, err
+	}
+	raddr, ok := addrs.first(isIPv4).(*SRTAddr)
+	if !ok {
+		return ep, 0, nil, &net.AddrError{Err: "unexpected address type", Addr: m.Address}
+	}
+	family = syscall.AF_INET
+	if raddr.IP.To4() == nil {
+		family = syscall.AF_INET6
+	}
+	if ep.Peer, err = raddr.sockaddr(family); err != nil {
+		return ep, 0, nil, err
+	}
+	if la, ok := m.LocalAddr.(*SRTAddr); ok {
+		if ep.Source, err = la.sockaddr(family); err != nil {
+			return ep, 0, nil, err
+		}
+	}
+	ep.Weight = m.Weight
+	return ep, family, raddr, nil
+}
+
+func dialGroup(ctx context.Context, mode GroupMode, family int, endpoints []srtapi.GroupEndpoint) (*netFD, error) {
+	g, err := creategroupFunc(int(mode))
+	if err != nil {
+		return nil, os.NewSyscallError("srt_create_group", err)
+	}
+	if err := srtapi.SetNonblock(g, true); err != nil {
+		poll.CloseFunc(g)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	configure(ctx, g, bindPre)
+	fd, err := newFD(g, family, syscall.SOCK_DGRAM, "srt")
+	if err != nil {
+		poll.CloseFunc(g)
+		return nil, err
+	}
+	if _, err := connectgroupFunc(g, endpoints); err != nil {
+		fd.Close()
+		return nil, os.NewSyscallError("srt_connect_group", err)
+	}
+	if err := fd.waitConnect(ctx, func() (int, error) { return getsockstateFunc(g), nil }); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	configure(ctx, g, bindPost)
+	return fd, nil
+}
+
+// IsGroup reports whether c is a socket group, either dialed with
+// DialGroup or accepted from a listener with the groupconnect option.
+func (c *SRTConn) IsGroup() bool {
+	return c.ok() && c.fd.pfd.Sysfd&srtapi.GroupMask != 0
+}
+
+// Members returns the state and statistics of each member connection of
+// the group c.
+func (c *SRTConn) Members() ([]GroupMemberStatus, error) {
+	if !c.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	if !c.IsGroup() {
+		return nil, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: errNotGroup}
+	}
+	data, err := groupdataFunc(c.fd.pfd.Sysfd)
+	if err != nil {
+		return nil, &OpError{Op: "get", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_group_data", err)}
+	}
+	members := make([]GroupMemberStatus, len(data))
+	for i, d := range data {
+		m := GroupMemberStatus{
+			RemoteAddr: sockaddrToSRT(d.Peer),
+			State:      State(d.State),
+			Member:     MemberState(d.MemberState),
+			Weight:     d.Weight,
+		}
+		if st, err := getbstatsFunc(d.ID); err == nil {
+			m.RTT = time.Duration(st.MsRTT * float64(time.Millisecond))
+			m.Bandwidth, m.Lost, m.Dropped = st.MbpsBandwidth, st.PktSndLossTotal, st.PktSndDropTotal
+		}
+		members[i] = m
+	}
+	return members, nil
+}
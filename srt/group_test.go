@@ -0,0 +1,105 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// +build srtgroups
+
+package srt
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+func TestGroupMembers(t *testing.T) {
+	origData, origStats := groupdataFunc, getbstatsFunc
+	defer func() { groupdataFunc, getbstatsFunc = origData, origStats }()
+	groupdataFunc = func(group int) ([]srtapi.GroupMemberData, error) {
+		return []srtapi.GroupMemberData{
+			{ID: 1, Peer: &syscall.SockaddrInet4{Port: 5000, Addr: [4]byte{192, 0, 2, 1}}, State: srtapi.StatusConnected, MemberState: srtapi.MemberRunning, Weight: 10},
+			{ID: 2, Peer: &syscall.SockaddrInet4{Port: 5000, Addr: [4]byte{198, 51, 100, 1}}, State: srtapi.StatusBroken, MemberState: srtapi.MemberBroken, Weight: 5},
+		}, nil
+	}
+	getbstatsFunc = func(fd int) (srtapi.Bstats, error) {
+		if fd != 1 {
+			return srtapi.Bstats{}, srtapi.EINVPARAM
+		}
+		return srtapi.Bstats{MsRTT: 20, MbpsBandwidth: 100, PktSentTotal: 1000, PktSndLossTotal: 3, PktSndDropTotal: 1}, nil
+	}
+
+	c := newSRTConn(&netFD{pfd: poll.FD{Sysfd: srtapi.GroupMask | 1}, net: "srt"})
+	if !c.IsGroup() {
+		t.Fatal("not a group")
+	}
+	members, err := c.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Fatalf("got %d members", len(members))
+	}
+	m := members[0]
+	if m.RemoteAddr.String() != "192.0.2.1:5000" || m.State != StateConnected || m.Member != MemberRunning || m.Weight != 10 || m.RTT != 20*time.Millisecond || m.Lost != 3 || m.Dropped != 1 {
+		t.Errorf("got %+v", m)
+	}
+	if m := members[1]; m.Member != MemberBroken || m.RTT != 0 {
+		t.Errorf("got %+v", m)
+	}
+
+	if _, err := newSRTConn(&netFD{pfd: poll.FD{Sysfd: 1}, net: "srt"}).Members(); err == nil {
+		t.Error("members of a single socket")
+	}
+}
+
+func TestDialGroupNoMembers(t *testing.T) {
+	if _, err := DialGroup(context.Background(), GroupBroadcast); err == nil {
+		t.Error("dialed a group without members")
+	}
+}
+
+func TestDialGroup(t *testing.T) {
+	ctx := WithOptions(context.Background(), Options("groupconnect", "1"))
+	ln, err := newLocalListenerContext(ctx, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		b := make([]byte, 1316)
+		n, err := c.Read(b)
+		if err == nil {
+			c.Write(b[:n])
+		}
+	}()
+
+	addr := ln.Addr().String()
+	c, err := DialGroup(context.Background(), GroupBroadcast, GroupMember{Address: addr}, GroupMember{Address: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1316)
+	c.SetReadDeadline(time.Now().Add(someTimeout))
+	if n, err := c.Read(b); err != nil || string(b[:n]) != "hello" {
+		t.Fatalf("got %q, %v", b[:n], err)
+	}
+	members, err := c.Members()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 {
+		t.Errorf("got %d members", len(members))
+	}
+}
@@ -17,8 +17,14 @@ var (
 	testHookCanceledDial = func() {}
 
 	// Placeholders for socket srt calls.
-	socketFunc        = srtapi.Socket
-	connectFunc       = srtapi.Connect
-	listenFunc        = srtapi.Listen
-	getsockoptIntFunc = srtapi.GetsockoptInt
+	socketFunc          = srtapi.Socket
+	connectFunc         = srtapi.Connect
+	listenFunc          = srtapi.Listen
+	getsockoptIntFunc   = srtapi.GetsockoptInt
+	setsockoptInt64Func = srtapi.SetsockoptInt64
+	getsockstateFunc    = srtapi.GetSockState
+	getsndbufferFunc    = srtapi.GetSndBuffer
+	getbstatsFunc       = srtapi.GetBstats
+	getrejectreasonFunc = srtapi.GetRejectReason
+	setrejectreasonFunc = srtapi.SetRejectReason
 )
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srttest/impair"
+)
+
+// statInt returns a counter of a Stats map as int64.
+func statInt(stats map[string]interface{}, section, name string) int64 {
+	m, _ := stats[section].(map[string]interface{})
+	v := reflect.ValueOf(m[name])
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	}
+	return 0
+}
+
+func TestRetransmitUnderLoss(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode")
+	}
+	const count = 300
+	ctx := WithOptions(context.Background(), Options("latency", "1000"))
+	ln, err := newLocalListenerContext(ctx, "srt4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	p, err := impair.Listen("127.0.0.1:0", ln.Addr().String(), impair.Config{
+		Seed:     1,
+		Upstream: impair.Impairment{Loss: 0.05, Delay: 5 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	type result struct {
+		stats map[string]interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer c.Close()
+		c.SetReadDeadline(time.Now().Add(someTimeout))
+		b := make([]byte, 1316)
+		for i := 0; i < count; i++ {
+			n, err := c.Read(b)
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			if want := bytes.Repeat([]byte{byte(i)}, 1316); !bytes.Equal(b[:n], want) {
+				done <- result{err: fmt.Errorf("message %d corrupted", i)}
+				return
+			}
+		}
+		done <- result{stats: c.(*SRTConn).Stats()}
+	}()
+
+	var d Dialer
+	c, err := d.DialContext(ctx, "srt4", p.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < count; i++ {
+		if _, err := c.Write(bytes.Repeat([]byte{byte(i)}, 1316)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("message lost despite retransmission: %v", res.err)
+	}
+	if st := p.Stats(); st.Upstream.Lost == 0 {
+		t.Fatal("proxy dropped no packet")
+	}
+	if lost := statInt(res.stats, "recv", "packetsLost"); lost == 0 {
+		t.Error("receiver reported no loss")
+	}
+	if retrans := statInt(c.(*SRTConn).Stats(), "send", "packetsRetransmitted"); retrans == 0 {
+		t.Error("sender reported no retransmission")
+	}
+}
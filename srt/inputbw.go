@@ -0,0 +1,137 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// Parameters of the input bandwidth estimation.
+const (
+	inputbwSteps       = 10
+	minInputbwStep     = 10 * time.Millisecond
+	inputbwChangeRatio = 0.05
+)
+
+// inputEstimator is the state of AutoInputBandwidth.
+type inputEstimator struct {
+	mu     sync.Mutex
+	stop   chan struct{}
+	done   chan struct{} // closed when the estimation returns
+	closed bool
+	last   int64 // inputbw set last, accessed atomically
+}
+
+type writeSample struct {
+	t       time.Time
+	written int64
+}
+
+// AutoInputBandwidth makes c measure the rate at which the application
+// writes to it over a sliding window and keep the inputbw option set to
+// that rate, so that with maxbw set to 0 the bandwidth allowed for
+// retransmissions, oheadbw percent of inputbw, follows a variable bitrate
+// stream. The option is updated ten times per window when the rate
+// changes by more than 5%; a window without writes leaves it unchanged.
+// A window of zero or less stops the estimation, which also stops when
+// the connection is closed.
+func (c *SRTConn) AutoInputBandwidth(window time.Duration) error {
+	if !c.ok() {
+		return srtapi.EINVPARAM
+	}
+	e := &c.inputbw
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return &OpError{Op: "set", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: poll.ErrNetClosing}
+	}
+	e.stopLocked()
+	if window <= 0 {
+		return nil
+	}
+	e.stop, e.done = make(chan struct{}), make(chan struct{})
+	go c.estimateInputBandwidth(window, e.stop, e.done)
+	return nil
+}
+
+// stopLocked stops the estimation, if running, and waits for it to
+// return.
+func (e *inputEstimator) stopLocked() {
+	if e.stop == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+	e.stop, e.done = nil, nil
+}
+
+// close stops the estimation for good. It is called before the socket is
+// closed, which the estimation must no longer use.
+func (e *inputEstimator) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopLocked()
+	e.closed = true
+}
+
+// InputBandwidth returns the inputbw value, in bytes per second, last set
+// by AutoInputBandwidth, or 0 if none was.
+func (c *SRTConn) InputBandwidth() int64 {
+	if !c.ok() {
+		return 0
+	}
+	return atomic.LoadInt64(&c.inputbw.last)
+}
+
+func (c *SRTConn) estimateInputBandwidth(window time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	step := window / inputbwSteps
+	if step < minInputbwStep {
+		step = minInputbwStep
+	}
+	ticker := time.NewTicker(step)
+	defer ticker.Stop()
+	samples := []writeSample{{t: time.Now(), written: atomic.LoadInt64(&c.fd.written)}}
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if State(getsockstateFunc(c.fd.pfd.Sysfd)) != StateConnected {
+				return
+			}
+			samples = append(samples, writeSample{t: now, written: atomic.LoadInt64(&c.fd.written)})
+			for len(samples) > 2 && now.Sub(samples[1].t) >= window {
+				samples = samples[1:]
+			}
+			if err := c.updateInputBandwidth(samples[0], samples[len(samples)-1]); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// updateInputBandwidth sets inputbw to the write rate between from and to
+// if it differs enough from the value set last.
+func (c *SRTConn) updateInputBandwidth(from, to writeSample) error {
+	elapsed := to.t.Sub(from.t)
+	if elapsed <= 0 || to.written == from.written {
+		return nil
+	}
+	rate := int64(float64(to.written-from.written) / elapsed.Seconds())
+	last := atomic.LoadInt64(&c.inputbw.last)
+	if diff := rate - last; last != 0 && diff < int64(float64(last)*inputbwChangeRatio) && -diff < int64(float64(last)*inputbwChangeRatio) {
+		return nil
+	}
+	if err := setsockoptInt64Func(c.fd.pfd.Sysfd, 0, srtapi.OptionInputbw, rate); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&c.inputbw.last, rate)
+	return nil
+}
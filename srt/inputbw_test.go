@@ -0,0 +1,134 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+func TestUpdateInputBandwidth(t *testing.T) {
+	orig := setsockoptInt64Func
+	defer func() { setsockoptInt64Func = orig }()
+	var set []int64
+	setsockoptInt64Func = func(fd, level, opt int, value int64) error {
+		if opt != srtapi.OptionInputbw {
+			t.Errorf("option %d set", opt)
+		}
+		set = append(set, value)
+		return nil
+	}
+	c := newSRTConn(&netFD{net: "srt"})
+	t0 := time.Now()
+	for _, tt := range []struct {
+		written int64
+		elapsed time.Duration
+	}{
+		{500000, time.Second},             // 500000 B/s
+		{520000, time.Second},             // within 5%
+		{1000000, 2 * time.Second},        // unchanged
+		{1000000, 500 * time.Millisecond}, // 2000000 B/s
+		{0, time.Second},                  // no writes
+	} {
+		if err := c.updateInputBandwidth(writeSample{t: t0}, writeSample{t: t0.Add(tt.elapsed), written: tt.written}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(set) != 2 || set[0] != 500000 || set[1] != 2000000 {
+		t.Errorf("got %v; want [500000 2000000]", set)
+	}
+	if bw := c.InputBandwidth(); bw != 2000000 {
+		t.Errorf("InputBandwidth() = %d", bw)
+	}
+}
+
+func TestAutoInputBandwidth(t *testing.T) {
+	origSet, origState := setsockoptInt64Func, getsockstateFunc
+	defer func() { setsockoptInt64Func, getsockstateFunc = origSet, origState }()
+	var state int32 = int32(StateConnected)
+	stopped := make(chan struct{})
+	getsockstateFunc = func(fd int) int {
+		st := int(atomic.LoadInt32(&state))
+		if State(st) == StateClosed {
+			close(stopped)
+		}
+		return st
+	}
+	updated := make(chan int64, 100)
+	setsockoptInt64Func = func(fd, level, opt int, value int64) error {
+		updated <- value
+		return nil
+	}
+
+	c := newSRTConn(&netFD{net: "srt"})
+	if err := c.AutoInputBandwidth(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				atomic.AddInt64(&c.fd.written, 1316)
+			}
+		}
+	}()
+	defer close(stop)
+	select {
+	case bw := <-updated:
+		if bw <= 0 {
+			t.Errorf("inputbw set to %d", bw)
+		}
+	case <-time.After(someTimeout):
+		t.Fatal("inputbw not updated")
+	}
+
+	atomic.StoreInt32(&state, int32(StateClosed))
+	select {
+	case <-stopped:
+	case <-time.After(someTimeout):
+		t.Fatal("estimation not stopped on close")
+	}
+	if err := c.AutoInputBandwidth(0); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAutoInputBandwidthClose(t *testing.T) {
+	orig := getsockstateFunc
+	defer func() { getsockstateFunc = orig }()
+	var closed int32
+	polled := make(chan struct{}, 1)
+	getsockstateFunc = func(fd int) int {
+		if atomic.LoadInt32(&closed) != 0 {
+			t.Error("socket used after close")
+		}
+		select {
+		case polled <- struct{}{}:
+		default:
+		}
+		return int(StateConnected)
+	}
+
+	c := newSRTConn(&netFD{net: "srt"})
+	if err := c.AutoInputBandwidth(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-polled:
+	case <-time.After(someTimeout):
+		t.Fatal("estimation not started")
+	}
+	c.inputbw.close()
+	atomic.StoreInt32(&closed, 1)
+	time.Sleep(5 * minInputbwStep)
+	if err := c.AutoInputBandwidth(100 * time.Millisecond); err == nil {
+		t.Error("estimation restarted after close")
+	}
+}
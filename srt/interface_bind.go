@@ -0,0 +1,92 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"net"
+	"os"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// interfaceAddrsFunc returns the addresses of the named interface.
+var interfaceAddrsFunc = func(name string) ([]net.Addr, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return ifi.Addrs()
+}
+
+// interfaceAddr returns the address of the named interface to bind a
+// socket to. The address has the family of ip when ip is not nil, or
+// else the family asked for by network, preferring IPv4 for "srt".
+// Global IPv6 addresses are preferred over link-local ones, which get
+// the interface as zone.
+func interfaceAddr(name, network string, ip net.IP, port int) (*SRTAddr, error) {
+	addrs, err := interfaceAddrsFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	want4, want6 := true, true
+	switch {
+	case ip != nil:
+		want4 = ip.To4() != nil
+		want6 = !want4
+	case network == "srt4":
+		want6 = false
+	case network == "srt6":
+		want4 = false
+	}
+	var v4, v6, v6ll net.IP
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		switch {
+		case ipn.IP.To4() != nil:
+			if v4 == nil {
+				v4 = ipn.IP.To4()
+			}
+		case ipn.IP.IsLinkLocalUnicast():
+			if v6ll == nil {
+				v6ll = ipn.IP
+			}
+		case ipn.IP.IsGlobalUnicast():
+			if v6 == nil {
+				v6 = ipn.IP
+			}
+		}
+	}
+	switch {
+	case want4 && v4 != nil:
+		return &SRTAddr{IP: v4, Port: port}, nil
+	case want6 && v6 != nil:
+		return &SRTAddr{IP: v6, Port: port}, nil
+	case want6 && v6ll != nil:
+		return &SRTAddr{IP: v6ll, Port: port, Zone: name}, nil
+	}
+	return nil, &net.AddrError{Err: errNoSuitableAddress.Error(), Addr: name}
+}
+
+// bindToDeviceControl returns a Control function that pins the socket to
+// the named interface before calling next, if not nil.
+func bindToDeviceControl(name string, next func(string, string, RawConn) error) func(string, string, RawConn) error {
+	return func(network, address string, c RawConn) error {
+		var err error
+		if cerr := c.Control(func(s int) {
+			err = srtapi.SetsockflagString(s, srtapi.OptionBindtodevice, name)
+		}); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return os.NewSyscallError("setsockopt", err)
+		}
+		if next != nil {
+			return next(network, address, c)
+		}
+		return nil
+	}
+}
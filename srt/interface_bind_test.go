@@ -0,0 +1,123 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func withInterfaceAddrs(t *testing.T, addrs map[string][]string) func() {
+	orig := interfaceAddrsFunc
+	interfaceAddrsFunc = func(name string) ([]net.Addr, error) {
+		cidrs, ok := addrs[name]
+		if !ok {
+			return nil, &net.OpError{Op: "route", Net: "ip+net", Err: errors.New("no such network interface")}
+		}
+		var as []net.Addr
+		for _, cidr := range cidrs {
+			ip, ipn, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ipn.IP = ip
+			as = append(as, ipn)
+		}
+		return as, nil
+	}
+	return func() { interfaceAddrsFunc = orig }
+}
+
+var interfaceAddrTests = []struct {
+	name, network string
+	ip            net.IP
+	want          string
+}{
+	{"eth0", "srt", nil, "192.0.2.1:0"},
+	{"eth0", "srt6", nil, "[2001:db8::1]:0"},
+	{"eth0", "srt", net.ParseIP("2001:db8::2"), "[2001:db8::1]:0"},
+	{"eth0", "srt", net.ParseIP("198.51.100.1"), "192.0.2.1:0"},
+	{"eth1", "srt", nil, "[fe80::1%eth1]:0"},
+	{"eth1", "srt4", nil, ""},
+	{"eth2", "srt", nil, ""},
+}
+
+func TestInterfaceAddr(t *testing.T) {
+	defer withInterfaceAddrs(t, map[string][]string{
+		"eth0": {"fe80::2/64", "2001:db8::1/64", "192.0.2.1/24"},
+		"eth1": {"fe80::1/64"},
+	})()
+	for _, tt := range interfaceAddrTests {
+		la, err := interfaceAddr(tt.name, tt.network, tt.ip, 0)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s %s %v: got %v; want error", tt.name, tt.network, tt.ip, la)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %s %v: %v", tt.name, tt.network, tt.ip, err)
+			continue
+		}
+		if la.String() != tt.want {
+			t.Errorf("%s %s %v: got %v; want %s", tt.name, tt.network, tt.ip, la, tt.want)
+		}
+	}
+}
+
+func TestListenConfigInterfaceAddress(t *testing.T) {
+	defer withInterfaceAddrs(t, map[string][]string{
+		"eth0": {"192.0.2.1/24"},
+		"eth1": {"fe80::1/64"},
+	})()
+	lc := &ListenConfig{Interface: "eth0"}
+	if addr, err := lc.interfaceAddress("srt", ":5000"); err != nil || addr != "192.0.2.1:5000" {
+		t.Errorf("got %q, %v", addr, err)
+	}
+	if _, err := lc.interfaceAddress("srt", "127.0.0.1:5000"); err == nil {
+		t.Error("host and Interface accepted together")
+	}
+	lc.Interface = "eth1"
+	if addr, err := lc.interfaceAddress("srt", ":5000"); err != nil || addr != "[fe80::1%eth1]:5000" {
+		t.Errorf("got %q, %v", addr, err)
+	}
+}
+
+func TestBindToDeviceWithoutInterface(t *testing.T) {
+	d := &Dialer{BindToDevice: true}
+	_, err := d.Dial("srt", "127.0.0.1:5000")
+	if perr := parseDialError(err); perr != nil {
+		t.Error(perr)
+	}
+	if oe, ok := err.(*OpError); !ok || oe.Err != errBindToDeviceWithoutInterface {
+		t.Errorf("Dial: got %v; want %v", err, errBindToDeviceWithoutInterface)
+	}
+	lc := &ListenConfig{BindToDevice: true}
+	_, err = lc.Listen(context.Background(), "srt", "127.0.0.1:0")
+	if oe, ok := err.(*OpError); !ok || oe.Err != errBindToDeviceWithoutInterface {
+		t.Errorf("Listen: got %v; want %v", err, errBindToDeviceWithoutInterface)
+	}
+}
+
+func TestDialInterface(t *testing.T) {
+	if _, err := net.InterfaceByName("lo"); err != nil {
+		t.Skip("no loopback interface named lo")
+	}
+	ln, err := newLocalListener("srt4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	d := Dialer{Interface: "lo"}
+	c, err := d.Dial("srt4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if ip := c.LocalAddr().(*SRTAddr).IP; !ip.IsLoopback() {
+		t.Errorf("got local address %v; want a loopback address", c.LocalAddr())
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Parameters of ProbeLatency.
+const (
+	defaultProbeDuration = 5 * time.Second
+	probeSendInterval    = 10 * time.Millisecond
+	probeSampleInterval  = 100 * time.Millisecond
+	probePayloadSize     = 1316
+	minAdvisedLatency    = 20 * time.Millisecond
+)
+
+// LatencyAdvice is the outcome of ProbeLatency: what was measured on the
+// link and the options recommended for it.
+type LatencyAdvice struct {
+	// RTT is the highest round trip time sampled.
+	RTT time.Duration
+
+	// Loss is the fraction of the packets sent that were reported
+	// lost.
+	Loss float64
+
+	// Bandwidth is the link capacity estimated by libsrt, in Mbps.
+	Bandwidth float64
+
+	// Latency is a multiple of RTT that grows with Loss, from 4 times
+	// RTT on clean links to 10 times on very lossy ones.
+	Latency time.Duration
+
+	// OverheadBandwidth is the share of the input bandwidth, in
+	// percent, allowed for retransmissions.
+	OverheadBandwidth int
+
+	// FlowWindow is the flow control window, in packets, and
+	// SendBuffer and ReceiveBuffer the buffer sizes, in bytes, that hold
+	// Latency plus half of RTT of data at Bandwidth. They are never
+	// below the libsrt defaults.
+	FlowWindow    int
+	SendBuffer    int
+	ReceiveBuffer int
+}
+
+// Options returns the latency, oheadbw, fc, sndbuf and rcvbuf options of
+// the advice.
+func (a *LatencyAdvice) Options() OptionSet {
+	return Options(
+		"latency", strconv.FormatInt(int64(a.Latency/time.Millisecond), 10),
+		"oheadbw", strconv.Itoa(a.OverheadBandwidth),
+		"fc", strconv.Itoa(a.FlowWindow),
+		"sndbuf", strconv.Itoa(a.SendBuffer),
+		"rcvbuf", strconv.Itoa(a.ReceiveBuffer),
+	)
+}
+
+// errNoProbeTarget is returned by a Dialer whose Probe would send its
+// padding to the endpoint being dialed.
+var errNoProbeTarget = errors.New("latency probe needs an address or a stream id")
+
+// LatencyProbe configures the probe made by a Dialer before it connects.
+//
+// The probe sends padding, so it must not reach the resource the
+// connection is for: at least one of Address and StreamID must be set,
+// to an endpoint that discards what it reads.
+type LatencyProbe struct {
+	// Duration is how long the probe lasts. Zero means 5 seconds.
+	Duration time.Duration
+
+	// Address, if not empty, is the address probed instead of the
+	// one dialed, for instance a server dedicated to probes on the
+	// same site.
+	Address string
+
+	// StreamID, if not empty, is the stream ID of the probe session
+	// instead of the one carried by the context, so that the endpoint
+	// can tell probes from real sessions.
+	StreamID string
+}
+
+// ProbeLatency opens a short SRT session to address with the dialer d,
+// which may be nil, sends padding at about 1 Mbps for the given duration
+// and returns the options recommended for the link. A duration of zero
+// means 5 seconds. The options and stream ID carried by ctx are used, so
+// address and the stream ID must name an endpoint that accepts the
+// session and discards what it reads, not a real resource.
+func ProbeLatency(ctx context.Context, d *Dialer, network, address string, duration time.Duration) (*LatencyAdvice, error) {
+	if d == nil {
+		d = &Dialer{}
+	}
+	if duration <= 0 {
+		duration = defaultProbeDuration
+	}
+	c, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	sc, ok := c.(*SRTConn)
+	if !ok {
+		return nil, &OpError{Op: "probe", Net: network, Source: c.LocalAddr(), Addr: c.RemoteAddr(), Err: &net.AddrError{Err: "unexpected connection type", Addr: address}}
+	}
+	rtt, loss, bandwidth, err := sc.probe(ctx, duration)
+	if err != nil {
+		return nil, err
+	}
+	return adviseLatency(rtt, loss, bandwidth), nil
+}
+
+// probe writes padding to c until duration has elapsed and samples the
+// link stats meanwhile.
+func (c *SRTConn) probe(ctx context.Context, duration time.Duration) (rtt time.Duration, loss, bandwidth float64, err error) {
+	probeCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+	send := time.NewTicker(probeSendInterval)
+	defer send.Stop()
+	sample := time.NewTicker(probeSampleInterval)
+	defer sample.Stop()
+	b := make([]byte, probePayloadSize)
+	var sent, lost int64
+	for {
+		select {
+		case <-probeCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return 0, 0, 0, &OpError{Op: "probe", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: mapErr(err)}
+			}
+			if sent > 0 {
+				loss = float64(lost) / float64(sent)
+			}
+			return rtt, loss, bandwidth, nil
+		case <-send.C:
+			if _, err := c.Write(b); err != nil {
+				return 0, 0, 0, err
+			}
+		case <-sample.C:
+			st, err := getbstatsFunc(c.fd.pfd.Sysfd)
+			if err != nil {
+				return 0, 0, 0, &OpError{Op: "probe", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: os.NewSyscallError("srt_bstats", err)}
+			}
+			sent, lost = st.PktSentTotal, st.PktSndLossTotal
+			if d := time.Duration(st.MsRTT * float64(time.Millisecond)); d > rtt {
+				rtt = d
+			}
+			if st.MbpsBandwidth > 0 {
+				bandwidth = st.MbpsBandwidth
+			}
+		}
+	}
+}
+
+// adviseLatency returns the options recommended for a link with the given
+// round trip time, loss ratio and bandwidth in Mbps.
+func adviseLatency(rtt time.Duration, loss, bandwidth float64) *LatencyAdvice {
+	a := &LatencyAdvice{RTT: rtt, Loss: loss, Bandwidth: bandwidth}
+	var factor time.Duration
+	switch {
+	case loss <= 0.01:
+		factor, a.OverheadBandwidth = 4, 25
+	case loss <= 0.03:
+		factor, a.OverheadBandwidth = 6, 33
+	case loss <= 0.07:
+		factor, a.OverheadBandwidth = 8, 50
+	default:
+		factor, a.OverheadBandwidth = 10, 100
+	}
+	a.Latency = (factor*rtt + time.Millisecond - 1).Truncate(time.Millisecond)
+	if a.Latency < minAdvisedLatency {
+		a.Latency = minAdvisedLatency
+	}
+	window := a.Latency + rtt/2
+	packets := int(math.Ceil(bandwidth * 1e6 / 8 * window.Seconds() / maxLivePayloadSize))
+	a.FlowWindow = defaultFc
+	if packets > a.FlowWindow {
+		a.FlowWindow = packets
+	}
+	a.ReceiveBuffer = a.FlowWindow * (defaultMss - udpHeaderSize)
+	a.SendBuffer = a.ReceiveBuffer
+	return a
+}
+
+// adviseLatency probes the link to address as configured by d.Probe and
+// adds the recommended options to ctx, except those ctx already holds.
+func (d *Dialer) adviseLatency(ctx context.Context, network, address string) (context.Context, error) {
+	p := d.Probe
+	if p.Address == "" && p.StreamID == "" {
+		return nil, &OpError{Op: "probe", Net: network, Source: nil, Addr: nil, Err: errNoProbeTarget}
+	}
+	probeCtx, probeAddress := ctx, address
+	if p.Address != "" {
+		probeAddress = p.Address
+	}
+	if p.StreamID != "" {
+		probeCtx = WithOptions(ctx, Options("streamid", p.StreamID))
+	}
+	pd := *d
+	pd.Probe = nil
+	advice, err := ProbeLatency(probeCtx, &pd, network, probeAddress, p.Duration)
+	if err != nil {
+		return nil, err
+	}
+	var options OptionSet
+	for _, o := range advice.Options().list {
+		if _, ok := Option(ctx, o.key); !ok {
+			options.list = append(options.list, o)
+		}
+	}
+	return WithOptions(ctx, options), nil
+}
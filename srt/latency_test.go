@@ -0,0 +1,116 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+var adviseLatencyTests = []struct {
+	rtt       time.Duration
+	loss      float64
+	bandwidth float64
+
+	latency time.Duration
+	oheadbw int
+	fc      int
+}{
+	{50 * time.Millisecond, 0, 0, 200 * time.Millisecond, 25, defaultFc},
+	{50 * time.Millisecond, 0.02, 0, 300 * time.Millisecond, 33, defaultFc},
+	{50 * time.Millisecond, 0.05, 0, 400 * time.Millisecond, 50, defaultFc},
+	{50 * time.Millisecond, 0.2, 0, 500 * time.Millisecond, 100, defaultFc},
+	{time.Millisecond, 0, 0, minAdvisedLatency, 25, defaultFc},
+	{1500 * time.Microsecond, 0.02, 0, minAdvisedLatency, 33, defaultFc},
+	{12300 * time.Microsecond, 0, 0, 50 * time.Millisecond, 25, defaultFc},
+	// 1 Gbps for 4.5s is 386333 packets of 1456 bytes.
+	{time.Second, 0, 1000, 4 * time.Second, 25, 386333},
+}
+
+func TestAdviseLatency(t *testing.T) {
+	for i, tt := range adviseLatencyTests {
+		a := adviseLatency(tt.rtt, tt.loss, tt.bandwidth)
+		if a.Latency != tt.latency || a.OverheadBandwidth != tt.oheadbw || a.FlowWindow != tt.fc {
+			t.Errorf("#%d: got latency %v, oheadbw %d, fc %d; want %v, %d, %d", i, a.Latency, a.OverheadBandwidth, a.FlowWindow, tt.latency, tt.oheadbw, tt.fc)
+		}
+		if err := a.Options().Validate(); err != nil {
+			t.Errorf("#%d: %v", i, err)
+		}
+	}
+}
+
+func TestProbeLatency(t *testing.T) {
+	streamids := make(chan string, 4)
+	ctx := WithListenCallback(context.Background(), func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		streamids <- streamid
+		return 0
+	})
+	ln, err := newLocalListenerContext(ctx, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				b := make([]byte, 1500)
+				for {
+					if _, err := c.Read(b); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	a, err := ProbeLatency(context.Background(), nil, ln.Addr().Network(), ln.Addr().String(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Latency < minAdvisedLatency || a.FlowWindow < defaultFc {
+		t.Errorf("got %+v", a)
+	}
+
+	<-streamids
+
+	ctx = WithOptions(context.Background(), Options("latency", "300", "streamid", "live/cam1"))
+	d := Dialer{Probe: &LatencyProbe{Duration: 500 * time.Millisecond}}
+	if _, err := d.DialContext(ctx, ln.Addr().Network(), ln.Addr().String()); err == nil {
+		t.Error("probed the dialed stream")
+	}
+	d.Probe.StreamID = "probe"
+	c, err := d.DialContext(ctx, ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if ms, err := getsockoptIntFunc(c.(*SRTConn).fd.pfd.Sysfd, 0, srtapi.OptionLatency); err != nil || ms != 300 {
+		t.Errorf("latency = %d, %v; want 300", ms, err)
+	}
+	for _, want := range []string{"probe", "live/cam1"} {
+		if got := <-streamids; got != want {
+			t.Errorf("got stream id %q; want %q", got, want)
+		}
+	}
+}
+
+func TestProbeNeedsTarget(t *testing.T) {
+	d := Dialer{Probe: &LatencyProbe{}}
+	_, err := d.DialContext(context.Background(), "srt", "127.0.0.1:5000")
+	if perr := parseDialError(err); perr != nil {
+		t.Error(perr)
+	}
+	if oe, ok := err.(*OpError); !ok || oe.Err != errNoProbeTarget {
+		t.Errorf("got %v; want %v", err, errNoProbeTarget)
+	}
+}
@@ -10,6 +10,7 @@
 package srt
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"runtime"
@@ -18,6 +19,7 @@ import (
 	"time"
 
 	"github.com/openfresh/gosrt/internal/testenv"
+	"github.com/openfresh/gosrt/srtapi"
 )
 
 func (ln *SRTListener) port() string {
@@ -345,3 +347,88 @@ func TestClosingListener(t *testing.T) {
 	}
 	ln2.Close()
 }
+
+func TestListenConfigControl(t *testing.T) {
+	for _, network := range []string{"srt", "srt4", "srt6"} {
+		if !testableNetwork(network) {
+			continue
+		}
+		var address string
+		lc := ListenConfig{Control: func(network, addr string, c RawConn) error {
+			address = addr
+			return c.Control(func(s int) {})
+		}}
+		ln, err := lc.Listen(context.Background(), network, "localhost:0")
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if _, port, _ := net.SplitHostPort(address); port != "0" {
+			t.Errorf("Control called with address %q before bind", address)
+		}
+		if _, err := ln.(*SRTListener).SyscallConn(); err != nil {
+			t.Error(err)
+		}
+		ln.Close()
+	}
+}
+
+func TestListenConfig(t *testing.T) {
+	for _, tt := range []struct {
+		reuse bool
+		ctx   context.Context
+		want  bool
+	}{
+		// ReuseAddr false leaves the libsrt default or the context
+		// option alone.
+		{false, context.Background(), true},
+		{false, WithOptions(context.Background(), Options("reuseaddr", "false")), false},
+		{true, context.Background(), true},
+		{true, WithOptions(context.Background(), Options("reuseaddr", "false")), true},
+	} {
+		var rejected bool
+		lc := ListenConfig{
+			Backlog:   4,
+			ReuseAddr: tt.reuse,
+			Callback: func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+				rejected = true
+				return -1
+			},
+		}
+		ln, err := lc.Listen(tt.ctx, "srt4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc, err := ln.(*SRTListener).SyscallConn()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v int
+		rc.Control(func(s int) {
+			v, err = srtapi.GetsockflagInt(s, srtapi.OptionReuseaddr)
+		})
+		if err != nil {
+			t.Error(err)
+		} else if (v != 0) != tt.want {
+			t.Errorf("ReuseAddr %v: got reuseaddr %d; want %v", tt.reuse, v, tt.want)
+		}
+		if c, err := DialTimeout("srt4", ln.Addr().String(), someTimeout); err == nil {
+			c.Close()
+			t.Error("caller was not rejected by the callback")
+		}
+		if !rejected {
+			t.Error("callback was not called")
+		}
+		ln.Close()
+	}
+}
+
+func TestListenBacklogValue(t *testing.T) {
+	ctx := context.Background()
+	if n := listenBacklogValue(ctx); n != listenerBacklog {
+		t.Errorf("got %d; want %d", n, listenerBacklog)
+	}
+	if n := listenBacklogValue(context.WithValue(ctx, listenBacklogContextKey{}, 8)); n != 8 {
+		t.Errorf("got %d; want 8", n)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"net"
+	"syscall"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// A Multiplexer holds a local UDP port that several SRT sockets share:
+// any number of callers and at most one listener. libsrt dispatches the
+// packets arriving on the port to the right socket, so a firewall only
+// has to let a single port through.
+//
+// Sockets sharing the port must agree on the UDP level options, such as
+// mss, ipttl, iptos and the buffer sizes; libsrt refuses to bind a
+// socket whose options differ from the ones the port was opened with.
+type Multiplexer struct {
+	fd *netFD
+}
+
+// NewMultiplexer opens the local address on the named network, which
+// must be "srt", "srt4" or "srt6", for sharing. A port of 0 picks a free
+// port; Addr tells which. The options carried by ctx apply to the port.
+func NewMultiplexer(ctx context.Context, network, address string) (*Multiplexer, error) {
+	addrs, err := DefaultResolver.resolveAddrList(ctx, "listen", network, address, nil)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: err}
+	}
+	la, ok := addrs.first(isIPv4).(*SRTAddr)
+	if !ok {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: nil, Err: &net.AddrError{Err: "unexpected address type", Addr: address}}
+	}
+	fd, err := bindSRT(sharedPort(ctx), network, la)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Source: nil, Addr: la, Err: err}
+	}
+	return &Multiplexer{fd: fd}, nil
+}
+
+func (m *Multiplexer) ok() bool { return m != nil && m.fd != nil }
+
+// Addr returns the shared local address, a *SRTAddr.
+func (m *Multiplexer) Addr() net.Addr {
+	if !m.ok() {
+		return nil
+	}
+	return m.fd.laddr
+}
+
+// Listen announces on the shared address.
+func (m *Multiplexer) Listen(ctx context.Context) (net.Listener, error) {
+	if !m.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	la, _ := m.fd.laddr.(*SRTAddr)
+	ln, err := listenSRT(sharedPort(ctx), m.network(), la)
+	if err != nil {
+		return nil, &OpError{Op: "listen", Net: m.network(), Source: nil, Addr: la, Err: err}
+	}
+	return ln, nil
+}
+
+// Dial connects to the address from the shared address.
+func (m *Multiplexer) Dial(address string) (net.Conn, error) {
+	return m.DialContext(context.Background(), nil, address)
+}
+
+// DialContext connects to the address from the shared address using the
+// dialer d, which may be nil. The LocalAddr, DualStack and Interface
+// fields of d are ignored.
+func (m *Multiplexer) DialContext(ctx context.Context, d *Dialer, address string) (net.Conn, error) {
+	if !m.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	var md Dialer
+	if d != nil {
+		md = *d
+	}
+	md.LocalAddr = m.fd.laddr
+	md.DualStack = false
+	md.Interface = ""
+	return md.DialContext(sharedPort(ctx), m.network(), address)
+}
+
+// Close releases the multiplexer's hold on the port. Sockets created by
+// the multiplexer are not closed; the port stays open until the last of
+// them is closed.
+func (m *Multiplexer) Close() error {
+	if !m.ok() {
+		return srtapi.EINVPARAM
+	}
+	if err := m.fd.Close(); err != nil {
+		return &OpError{Op: "close", Net: m.fd.net, Source: nil, Addr: m.fd.laddr, Err: err}
+	}
+	return nil
+}
+
+// network returns the network of the sockets sharing the port, which
+// must have the family of the port.
+func (m *Multiplexer) network() string {
+	if m.fd.family == syscall.AF_INET {
+		return "srt4"
+	}
+	return "srt6"
+}
+
+// sharedPort returns ctx with the reuseaddr option that lets sockets bind
+// the shared port.
+func sharedPort(ctx context.Context) context.Context {
+	return WithOptions(ctx, Options("reuseaddr", "true"))
+}
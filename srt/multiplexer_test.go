@@ -0,0 +1,112 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestMultiplexer(t *testing.T) {
+	m, err := NewMultiplexer(context.Background(), "srt4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	port := m.Addr().(*SRTAddr).Port
+	if port == 0 {
+		t.Fatal("no port chosen")
+	}
+
+	// A listener on the shared port, called from an independent socket.
+	ln, err := m.Listen(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if p := ln.Addr().(*SRTAddr).Port; p != port {
+		t.Errorf("listener on port %d; want %d", p, port)
+	}
+
+	// Two remote listeners called from the shared port.
+	var remotes []net.Listener
+	for i := 0; i < 2; i++ {
+		rln, err := newLocalListener("srt4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rln.Close()
+		remotes = append(remotes, rln)
+	}
+
+	ch := make(chan error, 3)
+	echo := func(ln net.Listener) {
+		c, err := ln.Accept()
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer c.Close()
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(c, b); err != nil {
+			ch <- err
+			return
+		}
+		_, err = c.Write(b)
+		ch <- err
+	}
+	go echo(ln)
+	for _, rln := range remotes {
+		go echo(rln)
+	}
+
+	var conns []net.Conn
+	for _, rln := range remotes {
+		c, err := m.Dial(rln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if p := c.LocalAddr().(*SRTAddr).Port; p != port {
+			t.Errorf("caller on port %d; want %d", p, port)
+		}
+		conns = append(conns, c)
+	}
+	c, err := Dial("srt4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conns = append(conns, c)
+
+	for _, c := range conns {
+		if _, err := c.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(c, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Errorf("got %q", b)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-ch; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMultiplexerClosed(t *testing.T) {
+	var m *Multiplexer
+	if _, err := m.Dial("127.0.0.1:5000"); err == nil {
+		t.Error("Dial on a nil Multiplexer succeeded")
+	}
+	if err := m.Close(); err == nil {
+		t.Error("Close on a nil Multiplexer succeeded")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// Route selects the stream IDs a ServeMux hands to a handler. Empty
+// fields match anything.
+type Route struct {
+	// Resource is a slash-separated pattern matched against the r key
+	// of the stream ID. A segment "{name}" matches any single
+	// non-empty segment and a final segment "{name...}" matches the
+	// rest of the resource. The matched values are stored in
+	// StreamID.Params.
+	Resource string
+
+	// Mode is matched against the requested mode; a caller that does
+	// not give a mode requests ModeRequest.
+	Mode string
+
+	// User is matched against the u key of the stream ID.
+	User string
+
+	// Fields are further keys the stream ID must hold with the given
+	// values.
+	Fields map[string]string
+}
+
+// ServeMux is a stream ID multiplexer. It matches the stream ID of each
+// caller against a list of routes and serves the connection with the
+// handler of the first matching one, in registration order.
+//
+// ServeMux is a HandshakeHandler: when used as the Handler of a Server,
+// callers that match no route are rejected during the handshake, with
+// RejectNotFound when no route has their resource, RejectBadMode when
+// the resource exists for another mode and RejectForbidden otherwise.
+type ServeMux struct {
+	mu     sync.RWMutex
+	routes []muxEntry
+}
+
+type muxEntry struct {
+	route    Route
+	segments []string
+	h        Handler
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux { return new(ServeMux) }
+
+// Handle registers the handler for the given pattern, which is a
+// resource pattern optionally preceded by a mode and a space, as in
+// "publish live/{name}".
+func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	var r Route
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		r.Mode, r.Resource = pattern[:i], strings.TrimSpace(pattern[i+1:])
+	} else {
+		r.Resource = pattern
+	}
+	mux.HandleRoute(r, handler)
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (mux *ServeMux) HandleFunc(pattern string, handler func(*SRTConn, StreamID)) {
+	if handler == nil {
+		panic("srt: nil handler")
+	}
+	mux.Handle(pattern, HandlerFunc(handler))
+}
+
+// HandleRoute registers the handler for the given route.
+func (mux *ServeMux) HandleRoute(r Route, handler Handler) {
+	if handler == nil {
+		panic("srt: nil handler")
+	}
+	e := muxEntry{route: r, h: handler}
+	if r.Resource != "" {
+		e.segments = strings.Split(r.Resource, "/")
+		for i, seg := range e.segments {
+			if strings.HasSuffix(seg, "...}") && i != len(e.segments)-1 {
+				panic("srt: wildcard " + seg + " is not the last segment of " + r.Resource)
+			}
+		}
+	}
+	mux.mu.Lock()
+	mux.routes = append(mux.routes, e)
+	mux.mu.Unlock()
+}
+
+// Handler returns the handler to use for the given stream ID, with the
+// wildcard values of its route, or the reason to reject the caller.
+func (mux *ServeMux) Handler(sid StreamID) (h Handler, params map[string]string, reason RejectReason) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	reason = RejectNotFound
+	for _, e := range mux.routes {
+		params, ok := matchResource(e.segments, sid.Resource)
+		if !ok {
+			continue
+		}
+		switch {
+		case e.route.Mode != "" && e.route.Mode != sid.RequestMode():
+			reason = RejectBadMode
+		case e.route.User != "" && e.route.User != sid.User || !matchFields(e.route.Fields, sid.Fields):
+			if reason == RejectNotFound {
+				reason = RejectForbidden
+			}
+		default:
+			return e.h, params, 0
+		}
+	}
+	return nil, nil, reason
+}
+
+// AcceptHandshake rejects callers that match no route.
+func (mux *ServeMux) AcceptHandshake(sid StreamID, peer net.Addr) RejectReason {
+	_, _, reason := mux.Handler(sid)
+	return reason
+}
+
+// ServeSRT dispatches the connection to the handler of the route that
+// matches sid. The connection is closed without being served if none
+// does, which only happens when mux is not used by a Server.
+func (mux *ServeMux) ServeSRT(c *SRTConn, sid StreamID) {
+	h, params, _ := mux.Handler(sid)
+	if h == nil {
+		return
+	}
+	sid.Params = params
+	h.ServeSRT(c, sid)
+}
+
+func matchResource(segments []string, resource string) (map[string]string, bool) {
+	if segments == nil {
+		return nil, true
+	}
+	var params map[string]string
+	parts := strings.Split(resource, "/")
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			if i >= len(parts) || parts[i] != seg {
+				return nil, false
+			}
+			continue
+		}
+		name := seg[1 : len(seg)-1]
+		if params == nil {
+			params = make(map[string]string)
+		}
+		if strings.HasSuffix(name, "...") {
+			if i >= len(parts) {
+				return nil, false
+			}
+			params[strings.TrimSuffix(name, "...")] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) || parts[i] == "" {
+			return nil, false
+		}
+		params[name] = parts[i]
+	}
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+func matchFields(want, have map[string]string) bool {
+	for k, v := range want {
+		if hv, ok := have[k]; !ok || hv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestServeMux(t *testing.T) {
+	mux := NewServeMux()
+	var got string
+	handler := func(name string) HandlerFunc {
+		return func(c *SRTConn, sid StreamID) { got = name }
+	}
+	mux.Handle("publish live/{name}", handler("publish"))
+	mux.Handle("request live/{name}", handler("play"))
+	mux.HandleRoute(Route{Resource: "admin/{path...}", User: "root"}, handler("admin"))
+	mux.HandleRoute(Route{Resource: "secret", Fields: map[string]string{"x-token": "k"}}, handler("secret"))
+	mux.Handle("", handler("default-vod"))
+
+	tests := []struct {
+		sid    string
+		name   string
+		params map[string]string
+		reason RejectReason
+	}{
+		{"#!::r=live/a,m=publish", "publish", map[string]string{"name": "a"}, 0},
+		{"#!::r=live/a", "play", map[string]string{"name": "a"}, 0},
+		{"#!::u=root,r=admin/x/y", "admin", map[string]string{"path": "x/y"}, 0},
+		{"#!::r=secret,x-token=k", "secret", nil, 0},
+		{"vod/file.ts", "default-vod", nil, 0},
+	}
+	for _, tt := range tests {
+		sid, err := ParseStreamID(tt.sid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, params, reason := mux.Handler(sid)
+		if reason != tt.reason || h == nil {
+			t.Errorf("%q: got %v, %v", tt.sid, h, reason)
+			continue
+		}
+		if !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%q: got params %v; want %v", tt.sid, params, tt.params)
+		}
+		got = ""
+		h.ServeSRT(nil, sid)
+		if got != tt.name {
+			t.Errorf("%q: served by %q; want %q", tt.sid, got, tt.name)
+		}
+	}
+}
+
+func TestServeMuxReject(t *testing.T) {
+	mux := NewServeMux()
+	nop := HandlerFunc(func(*SRTConn, StreamID) {})
+	mux.Handle("publish live/{name}", nop)
+	mux.HandleRoute(Route{Resource: "admin/{path...}", User: "root"}, nop)
+
+	tests := []struct {
+		sid    string
+		reason RejectReason
+	}{
+		{"#!::r=live/a,m=bidirectional", RejectBadMode},
+		{"#!::r=live/a/b,m=publish", RejectNotFound},
+		{"#!::r=live/,m=publish", RejectNotFound},
+		{"#!::r=vod/a", RejectNotFound},
+		{"#!::u=alice,r=admin/x", RejectForbidden},
+		{"admin", RejectNotFound},
+	}
+	for _, tt := range tests {
+		sid, _ := ParseStreamID(tt.sid)
+		if reason := mux.AcceptHandshake(sid, nil); reason != tt.reason {
+			t.Errorf("%q: got %v; want %v", tt.sid, reason, tt.reason)
+		}
+	}
+}
+
+func TestServerListenCallbackReject(t *testing.T) {
+	origSetRejectReason := setrejectreasonFunc
+	defer func() { setrejectreasonFunc = origSetRejectReason }()
+	var rejected RejectReason
+	setrejectreasonFunc = func(fd int, reason int) error {
+		rejected = RejectReason(reason)
+		return nil
+	}
+
+	mux := NewServeMux()
+	mux.Handle("publish live/{name}", HandlerFunc(func(*SRTConn, StreamID) {}))
+	var nextCalled bool
+	srv := &Server{Handler: mux}
+	cb := srv.listenCallback(func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		nextCalled = true
+		return 0
+	})
+	peer := &syscall.SockaddrInet4{Port: 5000, Addr: [4]byte{127, 0, 0, 1}}
+
+	if r := cb(1, 5, peer, "#!::r=live/a,m=publish"); r != 0 || !nextCalled || rejected != 0 {
+		t.Errorf("accepted caller: got %d, next called %v, reason %v", r, nextCalled, rejected)
+	}
+	nextCalled = false
+	if r := cb(1, 5, peer, "#!::r=live/a"); r != -1 || nextCalled || rejected != RejectBadMode {
+		t.Errorf("rejected caller: got %d, next called %v, reason %v", r, nextCalled, rejected)
+	}
+	if r := cb(1, 5, peer, "#!::broken"); r != -1 || rejected != RejectBadRequest {
+		t.Errorf("malformed stream id: got %d, reason %v", r, rejected)
+	}
+	srv.Close()
+	if r := cb(1, 5, peer, "#!::r=live/a,m=publish"); r != -1 || rejected != RejectDown {
+		t.Errorf("closed server: got %d, reason %v", r, rejected)
+	}
+}
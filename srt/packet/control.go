@@ -0,0 +1,204 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Numbers of 32-bit fields in the ACK variants.
+const (
+	ACKLight = 1
+	ACKSmall = 4
+	ACKFull  = 7
+)
+
+const lossRangeFlag = 1 << 31
+
+// An ACK is the control information field of an acknowledgement.
+type ACK struct {
+	// LastACKPacketSequenceNumber is the sequence number of the packet
+	// following the last one received in order.
+	LastACKPacketSequenceNumber uint32
+
+	// RTT and RTTVariance are in microseconds.
+	RTT         uint32
+	RTTVariance uint32
+
+	// AvailableBufferSize is the free space of the receiver buffer in
+	// packets.
+	AvailableBufferSize uint32
+
+	// PacketsReceivingRate is in packets per second.
+	PacketsReceivingRate uint32
+
+	// EstimatedLinkCapacity is in packets per second.
+	EstimatedLinkCapacity uint32
+
+	// ReceivingRate is in bytes per second.
+	ReceivingRate uint32
+
+	// Fields is the number of fields present: ACKLight, ACKSmall or
+	// ACKFull. Zero means ACKFull.
+	Fields int
+}
+
+func (a *ACK) fields() int {
+	if a.Fields == 0 {
+		return ACKFull
+	}
+	return a.Fields
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (a *ACK) MarshalBinary() ([]byte, error) {
+	n := a.fields()
+	switch n {
+	case ACKLight, ACKSmall, ACKFull:
+	default:
+		return nil, fmt.Errorf("packet: invalid ack size %d", n)
+	}
+	v := []uint32{
+		a.LastACKPacketSequenceNumber, a.RTT, a.RTTVariance, a.AvailableBufferSize,
+		a.PacketsReceivingRate, a.EstimatedLinkCapacity, a.ReceivingRate,
+	}
+	b := make([]byte, 4*n)
+	for i := 0; i < n; i++ {
+		binary.BigEndian.PutUint32(b[4*i:], v[i])
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// Fields beyond the seventh are ignored.
+func (a *ACK) UnmarshalBinary(b []byte) error {
+	var n int
+	switch {
+	case len(b) >= 4*ACKFull:
+		n = ACKFull
+	case len(b) >= 4*ACKSmall:
+		n = ACKSmall
+	case len(b) >= 4*ACKLight:
+		n = ACKLight
+	default:
+		return ErrTruncated
+	}
+	var v [ACKFull]uint32
+	for i := 0; i < n; i++ {
+		v[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	*a = ACK{
+		LastACKPacketSequenceNumber: v[0],
+		RTT:                         v[1],
+		RTTVariance:                 v[2],
+		AvailableBufferSize:         v[3],
+		PacketsReceivingRate:        v[4],
+		EstimatedLinkCapacity:       v[5],
+		ReceivingRate:               v[6],
+		Fields:                      n,
+	}
+	return nil
+}
+
+// A LossRange is a range of lost sequence numbers, both ends included.
+// A single loss has From equal to To.
+type LossRange struct {
+	From, To uint32
+}
+
+// Len returns the number of packets in the range.
+func (r LossRange) Len() int {
+	return int((r.To-r.From)&MaxSequenceNumber) + 1
+}
+
+// A NAK is the loss list of a negative acknowledgement.
+type NAK struct {
+	Losses []LossRange
+}
+
+// Lost returns the number of packets reported lost.
+func (n *NAK) Lost() int {
+	total := 0
+	for _, r := range n.Losses {
+		total += r.Len()
+	}
+	return total
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (n *NAK) MarshalBinary() ([]byte, error) {
+	var b []byte
+	for _, r := range n.Losses {
+		if r.From > MaxSequenceNumber || r.To > MaxSequenceNumber {
+			return nil, fmt.Errorf("packet: loss range %d-%d out of range", r.From, r.To)
+		}
+		if r.From == r.To {
+			b = appendUint32(b, r.From)
+			continue
+		}
+		b = appendUint32(b, r.From|lossRangeFlag)
+		b = appendUint32(b, r.To)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (n *NAK) UnmarshalBinary(b []byte) error {
+	if len(b)%4 != 0 {
+		return ErrTruncated
+	}
+	var losses []LossRange
+	for i := 0; i < len(b); i += 4 {
+		w := binary.BigEndian.Uint32(b[i:])
+		if w&lossRangeFlag == 0 {
+			losses = append(losses, LossRange{From: w, To: w})
+			continue
+		}
+		i += 4
+		if i >= len(b) {
+			return ErrTruncated
+		}
+		to := binary.BigEndian.Uint32(b[i:])
+		if to&lossRangeFlag != 0 {
+			return fmt.Errorf("packet: malformed loss range %#x-%#x", w, to)
+		}
+		losses = append(losses, LossRange{From: w &^ lossRangeFlag, To: to})
+	}
+	n.Losses = losses
+	return nil
+}
+
+// A DropRequest is the control information field of a message drop
+// request.
+type DropRequest struct {
+	FirstSequenceNumber uint32
+	LastSequenceNumber  uint32
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (d *DropRequest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[0:], d.FirstSequenceNumber)
+	binary.BigEndian.PutUint32(b[4:], d.LastSequenceNumber)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (d *DropRequest) UnmarshalBinary(b []byte) error {
+	if len(b) < 8 {
+		return ErrTruncated
+	}
+	d.FirstSequenceNumber = binary.BigEndian.Uint32(b[0:])
+	d.LastSequenceNumber = binary.BigEndian.Uint32(b[4:])
+	return nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
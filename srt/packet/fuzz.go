@@ -0,0 +1,59 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// +build gofuzz
+
+package packet
+
+import "bytes"
+
+// Fuzz is the entry point of go-fuzz. Packets that parse must marshal
+// back to the same bytes.
+func Fuzz(data []byte) int {
+	p, err := Parse(data)
+	if err != nil {
+		return 0
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(b, data) {
+		panic("packet changed after round trip")
+	}
+	cp, ok := p.(*ControlPacket)
+	if !ok {
+		return 1
+	}
+	switch cp.Type {
+	case TypeHandshake:
+		h, err := cp.Handshake()
+		if err != nil {
+			return 0
+		}
+		cif, err := h.MarshalBinary()
+		if err != nil {
+			panic(err)
+		}
+		if !bytes.Equal(cif, cp.CIF) {
+			panic("handshake changed after round trip")
+		}
+		for _, e := range h.Extensions {
+			switch e.Type {
+			case ExtensionHSReq, ExtensionHSRsp:
+				e.HS()
+			case ExtensionKMReq, ExtensionKMRsp:
+				e.KeyMaterial()
+			case ExtensionSID:
+				e.Text()
+			}
+		}
+	case TypeACK:
+		cp.ACK()
+	case TypeNAK:
+		cp.NAK()
+	case TypeDropRequest:
+		cp.DropRequest()
+	}
+	return 1
+}
@@ -0,0 +1,602 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// HandshakeLen is the length of the handshake control information field
+// without extensions.
+const HandshakeLen = 48
+
+// HandshakeMagic is the extension field of an HSv5 induction response.
+const HandshakeMagic = 0x4a17
+
+// HandshakeType is the phase of a handshake, or a rejection.
+type HandshakeType int32
+
+// Handshake types.
+const (
+	HandshakeDone       HandshakeType = -3
+	HandshakeAgreement  HandshakeType = -2
+	HandshakeConclusion HandshakeType = -1
+	HandshakeWavehand   HandshakeType = 0
+	HandshakeInduction  HandshakeType = 1
+)
+
+// rejectionBase is the first handshake type that denotes a rejection.
+const rejectionBase = 1000
+
+// RejectionType returns the handshake type that rejects a connection
+// for reason.
+func RejectionType(reason RejectReason) HandshakeType {
+	return HandshakeType(rejectionBase + int32(reason))
+}
+
+// IsRejection reports whether t rejects the connection.
+func (t HandshakeType) IsRejection() bool {
+	return t >= rejectionBase
+}
+
+// RejectReason returns the reason of a rejection.
+func (t HandshakeType) RejectReason() RejectReason {
+	if !t.IsRejection() {
+		return RejectUnknown
+	}
+	return RejectReason(t - rejectionBase)
+}
+
+func (t HandshakeType) String() string {
+	switch t {
+	case HandshakeDone:
+		return "done"
+	case HandshakeAgreement:
+		return "agreement"
+	case HandshakeConclusion:
+		return "conclusion"
+	case HandshakeWavehand:
+		return "wavehand"
+	case HandshakeInduction:
+		return "induction"
+	}
+	if t.IsRejection() {
+		return "rejection(" + t.RejectReason().String() + ")"
+	}
+	return fmt.Sprintf("HandshakeType(%d)", int32(t))
+}
+
+// RejectReason is the reason a listener or caller rejected a connection.
+type RejectReason int32
+
+// Reject reasons defined by SRT. Values from RejectPredefined are
+// reserved for codes of the stream ID access control convention and
+// values from RejectUserDefined for applications.
+const (
+	RejectUnknown     RejectReason = 0
+	RejectSystem      RejectReason = 1
+	RejectPeer        RejectReason = 2
+	RejectResource    RejectReason = 3
+	RejectRogue       RejectReason = 4
+	RejectBacklog     RejectReason = 5
+	RejectIPE         RejectReason = 6
+	RejectClose       RejectReason = 7
+	RejectVersion     RejectReason = 8
+	RejectRdvCookie   RejectReason = 9
+	RejectBadSecret   RejectReason = 10
+	RejectUnsecure    RejectReason = 11
+	RejectMessageAPI  RejectReason = 12
+	RejectCongestion  RejectReason = 13
+	RejectFilter      RejectReason = 14
+	RejectGroup       RejectReason = 15
+	RejectTimeout     RejectReason = 16
+	RejectPredefined  RejectReason = 1000
+	RejectUserDefined RejectReason = 2000
+)
+
+var rejectReasonNames = []string{
+	"unknown", "system", "peer", "resource", "rogue", "backlog", "ipe", "close",
+	"version", "rdvcookie", "badsecret", "unsecure", "messageapi", "congestion",
+	"filter", "group", "timeout",
+}
+
+func (r RejectReason) String() string {
+	switch {
+	case r >= RejectUserDefined:
+		return fmt.Sprintf("user-defined %d", int32(r-RejectUserDefined))
+	case r >= RejectPredefined:
+		return fmt.Sprintf("predefined %d", int32(r-RejectPredefined))
+	case r >= 0 && int(r) < len(rejectReasonNames):
+		return rejectReasonNames[r]
+	}
+	return fmt.Sprintf("RejectReason(%d)", int32(r))
+}
+
+// HandshakeFlags are the extension flags of an HSv5 conclusion
+// handshake. They announce which extensions follow.
+type HandshakeFlags uint16
+
+// Handshake extension flags.
+const (
+	FlagHSReq  HandshakeFlags = 0x1
+	FlagKMReq  HandshakeFlags = 0x2
+	FlagConfig HandshakeFlags = 0x4
+)
+
+// A Handshake is the control information field of a handshake.
+type Handshake struct {
+	// Version is 4 or 5.
+	Version uint32
+
+	// EncryptionField advertises the key length in multiples of 8
+	// bytes in an HSv5 induction response: 2, 3 or 4 for AES-128,
+	// AES-192 or AES-256. Zero means no encryption.
+	EncryptionField uint16
+
+	// ExtensionField is HandshakeMagic in an HSv5 induction response,
+	// the HandshakeFlags of an HSv5 conclusion and the socket type in
+	// HSv4.
+	ExtensionField uint16
+
+	// InitialSequenceNumber is the first data packet sequence number.
+	InitialSequenceNumber uint32
+
+	// MTU is the maximum transmission unit size in bytes.
+	MTU uint32
+
+	// FlowWindow is the maximum flow window size in packets.
+	FlowWindow uint32
+
+	Type HandshakeType
+
+	// SocketID is the SRT socket ID of the sender.
+	SocketID uint32
+
+	// SYNCookie is the cookie of the caller-listener exchange.
+	SYNCookie uint32
+
+	// PeerIP is the address of the receiver as found on the wire. The
+	// SRT C library sends each 32-bit word in host order; use PeerAddr
+	// and SetPeerAddr to read and write it as a net.IP.
+	PeerIP [16]byte
+
+	// Extensions are the HSv5 extensions in wire order.
+	Extensions []Extension
+}
+
+// Flags returns the extension field interpreted as HandshakeFlags.
+func (h *Handshake) Flags() HandshakeFlags {
+	return HandshakeFlags(h.ExtensionField)
+}
+
+// KeyLength returns the key length in bytes advertised by EncryptionField.
+func (h *Handshake) KeyLength() int {
+	return int(h.EncryptionField) * 8
+}
+
+// PeerAddr returns PeerIP as an IPv4 or IPv6 address.
+func (h *Handshake) PeerAddr() net.IP {
+	ip := make(net.IP, 16)
+	for i := 0; i < 16; i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = h.PeerIP[i+3], h.PeerIP[i+2], h.PeerIP[i+1], h.PeerIP[i]
+	}
+	for _, b := range ip[4:] {
+		if b != 0 {
+			return ip
+		}
+	}
+	return net.IPv4(ip[0], ip[1], ip[2], ip[3])
+}
+
+// SetPeerAddr stores ip in PeerIP the way the SRT C library does.
+func (h *Handshake) SetPeerAddr(ip net.IP) {
+	var raw [16]byte
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(raw[:], ip4)
+	} else {
+		copy(raw[:], ip.To16())
+	}
+	for i := 0; i < 16; i += 4 {
+		h.PeerIP[i], h.PeerIP[i+1], h.PeerIP[i+2], h.PeerIP[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+}
+
+// Extension returns the first extension of type t.
+func (h *Handshake) Extension(t ExtensionType) (Extension, bool) {
+	for _, e := range h.Extensions {
+		if e.Type == t {
+			return e, true
+		}
+	}
+	return Extension{}, false
+}
+
+// StreamID returns the stream ID carried by the SID extension, if any.
+func (h *Handshake) StreamID() string {
+	if e, ok := h.Extension(ExtensionSID); ok {
+		return e.Text()
+	}
+	return ""
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (h *Handshake) MarshalBinary() ([]byte, error) {
+	b := make([]byte, HandshakeLen, HandshakeLen+64)
+	binary.BigEndian.PutUint32(b[0:], h.Version)
+	binary.BigEndian.PutUint16(b[4:], h.EncryptionField)
+	binary.BigEndian.PutUint16(b[6:], h.ExtensionField)
+	binary.BigEndian.PutUint32(b[8:], h.InitialSequenceNumber)
+	binary.BigEndian.PutUint32(b[12:], h.MTU)
+	binary.BigEndian.PutUint32(b[16:], h.FlowWindow)
+	binary.BigEndian.PutUint32(b[20:], uint32(h.Type))
+	binary.BigEndian.PutUint32(b[24:], h.SocketID)
+	binary.BigEndian.PutUint32(b[28:], h.SYNCookie)
+	copy(b[32:], h.PeerIP[:])
+	for _, e := range h.Extensions {
+		words := (len(e.Content) + 3) / 4
+		if words > 0xffff {
+			return nil, fmt.Errorf("packet: %v extension too long", e.Type)
+		}
+		b = appendUint16(b, uint16(e.Type))
+		b = appendUint16(b, uint16(words))
+		b = append(b, e.Content...)
+		for i := len(e.Content); i < 4*words; i++ {
+			b = append(b, 0)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (h *Handshake) UnmarshalBinary(b []byte) error {
+	if len(b) < HandshakeLen {
+		return ErrTruncated
+	}
+	*h = Handshake{
+		Version:               binary.BigEndian.Uint32(b[0:]),
+		EncryptionField:       binary.BigEndian.Uint16(b[4:]),
+		ExtensionField:        binary.BigEndian.Uint16(b[6:]),
+		InitialSequenceNumber: binary.BigEndian.Uint32(b[8:]),
+		MTU:                   binary.BigEndian.Uint32(b[12:]),
+		FlowWindow:            binary.BigEndian.Uint32(b[16:]),
+		Type:                  HandshakeType(binary.BigEndian.Uint32(b[20:])),
+		SocketID:              binary.BigEndian.Uint32(b[24:]),
+		SYNCookie:             binary.BigEndian.Uint32(b[28:]),
+	}
+	copy(h.PeerIP[:], b[32:48])
+	for rest := b[HandshakeLen:]; len(rest) > 0; {
+		if len(rest) < 4 {
+			return ErrTruncated
+		}
+		t := ExtensionType(binary.BigEndian.Uint16(rest[0:]))
+		n := 4 * int(binary.BigEndian.Uint16(rest[2:]))
+		if len(rest) < 4+n {
+			return ErrTruncated
+		}
+		h.Extensions = append(h.Extensions, Extension{Type: t, Content: append([]byte(nil), rest[4:4+n]...)})
+		rest = rest[4+n:]
+	}
+	return nil
+}
+
+// ExtensionType is the type of a handshake extension. In HSv4 it is the
+// subtype of the user-defined control packet carrying the extension.
+type ExtensionType uint16
+
+// Handshake extension types.
+const (
+	ExtensionHSReq      ExtensionType = 1
+	ExtensionHSRsp      ExtensionType = 2
+	ExtensionKMReq      ExtensionType = 3
+	ExtensionKMRsp      ExtensionType = 4
+	ExtensionSID        ExtensionType = 5
+	ExtensionCongestion ExtensionType = 6
+	ExtensionFilter     ExtensionType = 7
+	ExtensionGroup      ExtensionType = 8
+)
+
+func (t ExtensionType) String() string {
+	switch t {
+	case ExtensionHSReq:
+		return "hsreq"
+	case ExtensionHSRsp:
+		return "hsrsp"
+	case ExtensionKMReq:
+		return "kmreq"
+	case ExtensionKMRsp:
+		return "kmrsp"
+	case ExtensionSID:
+		return "sid"
+	case ExtensionCongestion:
+		return "congestion"
+	case ExtensionFilter:
+		return "filter"
+	case ExtensionGroup:
+		return "group"
+	}
+	return fmt.Sprintf("ExtensionType(%d)", uint16(t))
+}
+
+// An Extension is a handshake extension block.
+type Extension struct {
+	Type ExtensionType
+
+	// Content is the raw extension content, a multiple of 4 bytes
+	// long when parsed.
+	Content []byte
+}
+
+// TextExtension returns an extension of type t carrying s, as used by the
+// SID, congestion and filter extensions.
+//
+// The SRT C library copies such strings into 32-bit words and sends the
+// words in host order, so on the wire every group of 4 bytes appears
+// reversed. TextExtension and Text apply the same transformation.
+func TextExtension(t ExtensionType, s string) Extension {
+	b := make([]byte, (len(s)+3)/4*4)
+	copy(b, s)
+	swapWords(b)
+	return Extension{Type: t, Content: b}
+}
+
+// Text decodes the string carried by a SID, congestion or filter
+// extension.
+func (e Extension) Text() string {
+	b := make([]byte, len(e.Content)/4*4)
+	copy(b, e.Content)
+	swapWords(b)
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// HS decodes an HSREQ or HSRSP extension.
+func (e Extension) HS() (*HSExtension, error) {
+	hs := new(HSExtension)
+	if err := hs.UnmarshalBinary(e.Content); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+// KeyMaterial decodes a KMREQ or KMRSP extension.
+func (e Extension) KeyMaterial() (*KeyMaterial, error) {
+	km := new(KeyMaterial)
+	if err := km.UnmarshalBinary(e.Content); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
+
+// KMState returns the key material state carried by a KMRSP that
+// reports an error instead of echoing the key material.
+func (e Extension) KMState() (KMState, bool) {
+	if len(e.Content) != 4 {
+		return 0, false
+	}
+	return KMState(binary.BigEndian.Uint32(e.Content)), true
+}
+
+func swapWords(b []byte) {
+	for i := 0; i+4 <= len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+}
+
+// SRTFlags are the capabilities exchanged in HSREQ and HSRSP.
+type SRTFlags uint32
+
+// SRT flags.
+const (
+	FlagTSBPDSnd     SRTFlags = 0x01
+	FlagTSBPDRcv     SRTFlags = 0x02
+	FlagCrypt        SRTFlags = 0x04
+	FlagTLPktDrop    SRTFlags = 0x08
+	FlagPeriodicNAK  SRTFlags = 0x10
+	FlagRexmitFlag   SRTFlags = 0x20
+	FlagStream       SRTFlags = 0x40
+	FlagPacketFilter SRTFlags = 0x80
+)
+
+var srtFlagNames = []string{
+	"tsbpdsnd", "tsbpdrcv", "crypt", "tlpktdrop", "periodicnak", "rexmitflg", "stream", "filter",
+}
+
+func (f SRTFlags) String() string {
+	var names []string
+	for i, name := range srtFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if rest := f &^ (1<<uint(len(srtFlagNames)) - 1); rest != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(rest)))
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// An HSExtension is the content of an HSREQ or HSRSP extension.
+type HSExtension struct {
+	// Version is the SRT library version, as 0x00XXYYZZ for X.Y.Z.
+	Version uint32
+
+	Flags SRTFlags
+
+	// RecvTSBPDDelay and SendTSBPDDelay are latencies in milliseconds.
+	RecvTSBPDDelay uint16
+	SendTSBPDDelay uint16
+}
+
+// VersionString formats Version as X.Y.Z.
+func (hs *HSExtension) VersionString() string {
+	return fmt.Sprintf("%d.%d.%d", hs.Version>>16&0xff, hs.Version>>8&0xff, hs.Version&0xff)
+}
+
+// Extension returns hs as an extension of type t, ExtensionHSReq or
+// ExtensionHSRsp.
+func (hs *HSExtension) Extension(t ExtensionType) Extension {
+	b, _ := hs.MarshalBinary()
+	return Extension{Type: t, Content: b}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (hs *HSExtension) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 12)
+	binary.BigEndian.PutUint32(b[0:], hs.Version)
+	binary.BigEndian.PutUint32(b[4:], uint32(hs.Flags))
+	binary.BigEndian.PutUint16(b[8:], hs.RecvTSBPDDelay)
+	binary.BigEndian.PutUint16(b[10:], hs.SendTSBPDDelay)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (hs *HSExtension) UnmarshalBinary(b []byte) error {
+	if len(b) < 12 {
+		return ErrTruncated
+	}
+	*hs = HSExtension{
+		Version:        binary.BigEndian.Uint32(b[0:]),
+		Flags:          SRTFlags(binary.BigEndian.Uint32(b[4:])),
+		RecvTSBPDDelay: binary.BigEndian.Uint16(b[8:]),
+		SendTSBPDDelay: binary.BigEndian.Uint16(b[10:]),
+	}
+	return nil
+}
+
+// KMState is the state of the key material exchange.
+type KMState uint32
+
+// Key material states.
+const (
+	KMStateUnsecured KMState = 0
+	KMStateSecuring  KMState = 1
+	KMStateSecured   KMState = 2
+	KMStateNoSecret  KMState = 3
+	KMStateBadSecret KMState = 4
+)
+
+func (s KMState) String() string {
+	switch s {
+	case KMStateUnsecured:
+		return "unsecured"
+	case KMStateSecuring:
+		return "securing"
+	case KMStateSecured:
+		return "secured"
+	case KMStateNoSecret:
+		return "nosecret"
+	case KMStateBadSecret:
+		return "badsecret"
+	}
+	return fmt.Sprintf("KMState(%d)", uint32(s))
+}
+
+// Key material constants.
+const (
+	KMVersion    = 1
+	KMPacketType = 2
+	KMSign       = 0x2029
+)
+
+// Ciphers of the key material message.
+const (
+	CipherNone   = 0
+	CipherAESECB = 1
+	CipherAESCTR = 2
+	CipherAESCBC = 3
+)
+
+// KeyMaterial is a key material message, the content of KMREQ and KMRSP.
+type KeyMaterial struct {
+	Version    uint8 // KMVersion
+	PacketType uint8 // KMPacketType
+	Sign       uint16
+
+	// Keys tells which keys are wrapped: KeyEven, KeyOdd or KeyBoth.
+	Keys KeyFlag
+
+	// KEKI is the key encryption key index; 0 is the default stream
+	// key.
+	KEKI uint32
+
+	Cipher uint8
+	Auth   uint8
+
+	// SE is the stream encapsulation, 2 for SRT.
+	SE uint8
+
+	// KeyLength is the length of each wrapped key in bytes.
+	KeyLength int
+
+	Salt []byte
+
+	// WrappedKey holds the wrapped keys and the 8 byte integrity check
+	// vector of the AES key wrap.
+	WrappedKey []byte
+}
+
+// Extension returns km as an extension of type t, ExtensionKMReq or
+// ExtensionKMRsp.
+func (km *KeyMaterial) Extension(t ExtensionType) (Extension, error) {
+	b, err := km.MarshalBinary()
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{Type: t, Content: b}, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (km *KeyMaterial) MarshalBinary() ([]byte, error) {
+	if len(km.Salt)%4 != 0 || len(km.Salt) > 4*0xff {
+		return nil, fmt.Errorf("packet: invalid salt length %d", len(km.Salt))
+	}
+	if km.KeyLength%4 != 0 || km.KeyLength > 4*0xff {
+		return nil, fmt.Errorf("packet: invalid key length %d", km.KeyLength)
+	}
+	if len(km.WrappedKey)%4 != 0 {
+		return nil, fmt.Errorf("packet: invalid wrapped key length %d", len(km.WrappedKey))
+	}
+	b := make([]byte, 16, 16+len(km.Salt)+len(km.WrappedKey))
+	b[0] = (km.Version&7)<<4 | km.PacketType&0xf
+	binary.BigEndian.PutUint16(b[1:], km.Sign)
+	b[3] = uint8(km.Keys & 3)
+	binary.BigEndian.PutUint32(b[4:], km.KEKI)
+	b[8] = km.Cipher
+	b[9] = km.Auth
+	b[10] = km.SE
+	b[14] = uint8(len(km.Salt) / 4)
+	b[15] = uint8(km.KeyLength / 4)
+	b = append(b, km.Salt...)
+	b = append(b, km.WrappedKey...)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (km *KeyMaterial) UnmarshalBinary(b []byte) error {
+	if len(b) < 16 {
+		return ErrTruncated
+	}
+	saltLen := 4 * int(b[14])
+	if len(b) < 16+saltLen {
+		return ErrTruncated
+	}
+	*km = KeyMaterial{
+		Version:    b[0] >> 4 & 7,
+		PacketType: b[0] & 0xf,
+		Sign:       binary.BigEndian.Uint16(b[1:]),
+		Keys:       KeyFlag(b[3] & 3),
+		KEKI:       binary.BigEndian.Uint32(b[4:]),
+		Cipher:     b[8],
+		Auth:       b[9],
+		SE:         b[10],
+		KeyLength:  4 * int(b[15]),
+		Salt:       append([]byte(nil), b[16:16+saltLen]...),
+		WrappedKey: append([]byte(nil), b[16+saltLen:]...),
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package packet
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+var handshakeTests = []Handshake{
+	// HSv4 induction request of an HSv5 caller.
+	{
+		Version:               4,
+		ExtensionField:        2,
+		InitialSequenceNumber: 0x1234567,
+		MTU:                   1500,
+		FlowWindow:            8192,
+		Type:                  HandshakeInduction,
+		SocketID:              0x2a,
+	},
+	// HSv5 induction response.
+	{
+		Version:               5,
+		EncryptionField:       2,
+		ExtensionField:        HandshakeMagic,
+		InitialSequenceNumber: 0x1234567,
+		MTU:                   1500,
+		FlowWindow:            8192,
+		Type:                  HandshakeInduction,
+		SocketID:              0x2b,
+		SYNCookie:             0xdeadbeef,
+	},
+	// HSv5 conclusion with extensions.
+	{
+		Version:               5,
+		ExtensionField:        uint16(FlagHSReq | FlagKMReq | FlagConfig),
+		InitialSequenceNumber: 0x1234567,
+		MTU:                   1500,
+		FlowWindow:            8192,
+		Type:                  HandshakeConclusion,
+		SocketID:              0x2a,
+		SYNCookie:             0xdeadbeef,
+		Extensions: []Extension{
+			(&HSExtension{
+				Version:        0x010401,
+				Flags:          FlagTSBPDSnd | FlagTSBPDRcv | FlagCrypt | FlagTLPktDrop | FlagPeriodicNAK | FlagRexmitFlag,
+				RecvTSBPDDelay: 120,
+				SendTSBPDDelay: 120,
+			}).Extension(ExtensionHSReq),
+			{Type: ExtensionKMReq, Content: mustKM(&KeyMaterial{
+				Version:    KMVersion,
+				PacketType: KMPacketType,
+				Sign:       KMSign,
+				Keys:       KeyEven,
+				Cipher:     CipherAESCTR,
+				SE:         2,
+				KeyLength:  16,
+				Salt:       bytes.Repeat([]byte{0x5a}, 16),
+				WrappedKey: bytes.Repeat([]byte{0xa5}, 24),
+			})},
+			TextExtension(ExtensionSID, "#!::r=live/stream,m=publish"),
+		},
+	},
+	// Rejection.
+	{
+		Version: 5,
+		Type:    RejectionType(RejectBadSecret),
+	},
+}
+
+func mustKM(km *KeyMaterial) []byte {
+	b, err := km.MarshalBinary()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestHandshakeRoundTrip(t *testing.T) {
+	for i, h := range handshakeTests {
+		h.SetPeerAddr(net.IPv4(127, 0, 0, 1))
+		cif, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		cp := &ControlPacket{Type: TypeHandshake, DestinationSocketID: h.SocketID, CIF: cif}
+		b, err := cp.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		p, err := Parse(b)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		got, err := p.(*ControlPacket).Handshake()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(*got, h) {
+			t.Errorf("#%d: got %+v; want %+v", i, *got, h)
+		}
+	}
+}
+
+func TestHandshakeWire(t *testing.T) {
+	h := Handshake{Version: 5, Type: HandshakeConclusion, Extensions: []Extension{TextExtension(ExtensionSID, "abcde")}}
+	h.SetPeerAddr(net.IPv4(192, 168, 0, 1))
+	b, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b[32:36], []byte{1, 0, 168, 192}; !bytes.Equal(got, want) {
+		t.Errorf("peer ip is % x; want % x", got, want)
+	}
+	want := []byte{0, 5, 0, 2, 'd', 'c', 'b', 'a', 0, 0, 0, 'e'}
+	if got := b[HandshakeLen:]; !bytes.Equal(got, want) {
+		t.Errorf("sid extension is % x; want % x", got, want)
+	}
+	if typ := int32(b[20])<<24 | int32(b[21])<<16 | int32(b[22])<<8 | int32(b[23]); typ != -1 {
+		t.Errorf("type is %d; want -1", typ)
+	}
+}
+
+func TestHandshakeTruncated(t *testing.T) {
+	h := handshakeTests[2]
+	b, _ := h.MarshalBinary()
+	for _, n := range []int{0, HandshakeLen - 1, HandshakeLen + 2, HandshakeLen + 8, len(b) - 1} {
+		if err := new(Handshake).UnmarshalBinary(b[:n]); err != ErrTruncated {
+			t.Errorf("%d bytes: got %v; want %v", n, err, ErrTruncated)
+		}
+	}
+}
+
+func TestPeerAddr(t *testing.T) {
+	for _, ip := range []net.IP{
+		net.IPv4(10, 1, 2, 3),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("::1"),
+	} {
+		var h Handshake
+		h.SetPeerAddr(ip)
+		if got := h.PeerAddr(); !got.Equal(ip) {
+			t.Errorf("got %v; want %v", got, ip)
+		}
+	}
+}
+
+func TestStreamID(t *testing.T) {
+	for _, sid := range []string{"", "a", "abcd", "#!::u=admin,r=bar", "日本語"} {
+		h := Handshake{Extensions: []Extension{TextExtension(ExtensionSID, sid)}}
+		if got := h.StreamID(); got != sid {
+			t.Errorf("got %q; want %q", got, sid)
+		}
+	}
+	if sid := new(Handshake).StreamID(); sid != "" {
+		t.Errorf("got %q without extension", sid)
+	}
+}
+
+func TestHandshakeExtensions(t *testing.T) {
+	h := handshakeTests[2]
+	e, ok := h.Extension(ExtensionHSReq)
+	if !ok {
+		t.Fatal("no hsreq")
+	}
+	hs, err := e.HS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := hs.VersionString(); v != "1.4.1" {
+		t.Errorf("got version %s; want 1.4.1", v)
+	}
+	if hs.Flags&FlagCrypt == 0 || hs.RecvTSBPDDelay != 120 {
+		t.Errorf("unexpected hsreq %+v", hs)
+	}
+	if s := hs.Flags.String(); s != "tsbpdsnd|tsbpdrcv|crypt|tlpktdrop|periodicnak|rexmitflg" {
+		t.Errorf("flags string %q", s)
+	}
+
+	e, ok = h.Extension(ExtensionKMReq)
+	if !ok {
+		t.Fatal("no kmreq")
+	}
+	km, err := e.KeyMaterial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if km.Sign != KMSign || km.KeyLength != 16 || len(km.Salt) != 16 || len(km.WrappedKey) != 24 {
+		t.Errorf("unexpected key material %+v", km)
+	}
+	if _, ok := e.KMState(); ok {
+		t.Error("full key material decoded as state")
+	}
+	rsp := Extension{Type: ExtensionKMRsp, Content: []byte{0, 0, 0, 4}}
+	if s, ok := rsp.KMState(); !ok || s != KMStateBadSecret {
+		t.Errorf("got state %v, %v; want %v", s, ok, KMStateBadSecret)
+	}
+
+	if _, ok := h.Extension(ExtensionGroup); ok {
+		t.Error("found a group extension")
+	}
+}
+
+func TestRejection(t *testing.T) {
+	typ := RejectionType(RejectPeer)
+	if !typ.IsRejection() || typ.RejectReason() != RejectPeer {
+		t.Errorf("%d is not a peer rejection", typ)
+	}
+	if s := typ.String(); s != "rejection(peer)" {
+		t.Errorf("got %q", s)
+	}
+	if HandshakeConclusion.IsRejection() {
+		t.Error("conclusion is a rejection")
+	}
+	if s := (RejectUserDefined + 3).String(); s != "user-defined 3" {
+		t.Errorf("got %q", s)
+	}
+	if s := (RejectPredefined + 403).String(); s != "predefined 403" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestUserDefinedExtension(t *testing.T) {
+	hs := &HSExtension{Version: 0x010300, Flags: FlagTSBPDSnd}
+	e := hs.Extension(ExtensionHSReq)
+	cp := &ControlPacket{Type: TypeUserDefined, Subtype: uint16(e.Type), CIF: e.Content}
+	b, _ := cp.MarshalBinary()
+	p, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := p.(*ControlPacket).Extension()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("got %+v; want %+v", got, e)
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+/*
+Package packet parses and serialises SRT packets.
+
+It is a pure Go implementation of the wire format described in the SRT
+protocol specification and does not depend on the SRT C library, so it
+can be used to inspect captured traffic, to build test peers and to relay
+packets while looking at them.
+
+Parse decodes a UDP payload into a *DataPacket or a *ControlPacket. The
+control information field of a control packet is kept as raw bytes; the
+Handshake, ACK, NAK and DropRequest types decode and encode it for the
+control types that carry structured data.
+
+All multi-byte fields are big endian, as on the wire, except where the
+SRT C library stores strings and addresses word by word in host order.
+Those quirks are handled by the handshake types and documented there.
+*/
+package packet
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// HeaderLen is the length of the header shared by data and control
+// packets.
+const HeaderLen = 16
+
+// Maximum values of the packet fields narrower than 32 bits.
+const (
+	MaxSequenceNumber = 1<<31 - 1
+	MaxMessageNumber  = 1<<26 - 1
+)
+
+const controlFlag = 1 << 31
+
+// ErrTruncated is returned when a packet or one of its fields is shorter
+// than its format requires.
+var ErrTruncated = errors.New("packet: truncated")
+
+// A Packet is a *DataPacket or a *ControlPacket.
+type Packet interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+
+	// IsControl reports whether the packet is a control packet.
+	IsControl() bool
+}
+
+// Parse decodes a single SRT packet from b.
+// The returned packet does not retain b.
+func Parse(b []byte) (Packet, error) {
+	if len(b) < HeaderLen {
+		return nil, ErrTruncated
+	}
+	var p Packet
+	if binary.BigEndian.Uint32(b)&controlFlag != 0 {
+		p = new(ControlPacket)
+	} else {
+		p = new(DataPacket)
+	}
+	if err := p.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// IsControl reports whether b holds a control packet, without decoding
+// it.
+func IsControl(b []byte) bool {
+	return len(b) > 0 && b[0]&0x80 != 0
+}
+
+// Position is the position of a data packet within its message.
+type Position uint8
+
+// Packet positions.
+const (
+	PositionMiddle Position = 0 // 00
+	PositionLast   Position = 1 // 01
+	PositionFirst  Position = 2 // 10
+	PositionSolo   Position = 3 // 11
+)
+
+func (p Position) String() string {
+	switch p {
+	case PositionMiddle:
+		return "middle"
+	case PositionLast:
+		return "last"
+	case PositionFirst:
+		return "first"
+	case PositionSolo:
+		return "solo"
+	}
+	return fmt.Sprintf("Position(%d)", uint8(p))
+}
+
+// KeyFlag tells which key encrypts the payload of a data packet.
+type KeyFlag uint8
+
+// Key flags.
+const (
+	KeyNone KeyFlag = 0 // not encrypted
+	KeyEven KeyFlag = 1
+	KeyOdd  KeyFlag = 2
+	KeyBoth KeyFlag = 3 // only used in key material messages
+)
+
+func (k KeyFlag) String() string {
+	switch k {
+	case KeyNone:
+		return "none"
+	case KeyEven:
+		return "even"
+	case KeyOdd:
+		return "odd"
+	case KeyBoth:
+		return "both"
+	}
+	return fmt.Sprintf("KeyFlag(%d)", uint8(k))
+}
+
+// A DataPacket carries application payload.
+type DataPacket struct {
+	// SequenceNumber is the 31-bit packet sequence number.
+	SequenceNumber uint32
+
+	// Position is the position of the packet within its message.
+	Position Position
+
+	// InOrder is set when the message must be delivered in order.
+	InOrder bool
+
+	// Key is the key that encrypts Payload.
+	Key KeyFlag
+
+	// Retransmitted is set when the packet is sent again.
+	Retransmitted bool
+
+	// MessageNumber is the 26-bit message number.
+	MessageNumber uint32
+
+	// Timestamp is the sending time in microseconds relative to the
+	// start of the connection.
+	Timestamp uint32
+
+	// DestinationSocketID is the SRT socket ID of the receiver.
+	DestinationSocketID uint32
+
+	Payload []byte
+}
+
+// IsControl implements the Packet interface.
+func (p *DataPacket) IsControl() bool { return false }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (p *DataPacket) MarshalBinary() ([]byte, error) {
+	if p.SequenceNumber > MaxSequenceNumber {
+		return nil, fmt.Errorf("packet: sequence number %d out of range", p.SequenceNumber)
+	}
+	if p.MessageNumber > MaxMessageNumber {
+		return nil, fmt.Errorf("packet: message number %d out of range", p.MessageNumber)
+	}
+	b := make([]byte, HeaderLen+len(p.Payload))
+	binary.BigEndian.PutUint32(b[0:], p.SequenceNumber)
+	w := uint32(p.Position&3)<<30 | uint32(p.Key&3)<<27 | p.MessageNumber
+	if p.InOrder {
+		w |= 1 << 29
+	}
+	if p.Retransmitted {
+		w |= 1 << 26
+	}
+	binary.BigEndian.PutUint32(b[4:], w)
+	binary.BigEndian.PutUint32(b[8:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[12:], p.DestinationSocketID)
+	copy(b[HeaderLen:], p.Payload)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (p *DataPacket) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLen {
+		return ErrTruncated
+	}
+	w0 := binary.BigEndian.Uint32(b[0:])
+	if w0&controlFlag != 0 {
+		return errors.New("packet: not a data packet")
+	}
+	w1 := binary.BigEndian.Uint32(b[4:])
+	*p = DataPacket{
+		SequenceNumber:      w0,
+		Position:            Position(w1 >> 30),
+		InOrder:             w1&(1<<29) != 0,
+		Key:                 KeyFlag(w1 >> 27 & 3),
+		Retransmitted:       w1&(1<<26) != 0,
+		MessageNumber:       w1 & MaxMessageNumber,
+		Timestamp:           binary.BigEndian.Uint32(b[8:]),
+		DestinationSocketID: binary.BigEndian.Uint32(b[12:]),
+		Payload:             append([]byte(nil), b[HeaderLen:]...),
+	}
+	return nil
+}
+
+// ControlType is the type of a control packet.
+type ControlType uint16
+
+// Control packet types.
+const (
+	TypeHandshake   ControlType = 0x0000
+	TypeKeepalive   ControlType = 0x0001
+	TypeACK         ControlType = 0x0002
+	TypeNAK         ControlType = 0x0003
+	TypeCongestion  ControlType = 0x0004
+	TypeShutdown    ControlType = 0x0005
+	TypeACKACK      ControlType = 0x0006
+	TypeDropRequest ControlType = 0x0007
+	TypePeerError   ControlType = 0x0008
+	TypeUserDefined ControlType = 0x7fff
+)
+
+var controlTypeNames = map[ControlType]string{
+	TypeHandshake:   "handshake",
+	TypeKeepalive:   "keepalive",
+	TypeACK:         "ack",
+	TypeNAK:         "nak",
+	TypeCongestion:  "congestion",
+	TypeShutdown:    "shutdown",
+	TypeACKACK:      "ackack",
+	TypeDropRequest: "dropreq",
+	TypePeerError:   "peererror",
+	TypeUserDefined: "user",
+}
+
+func (t ControlType) String() string {
+	if s, ok := controlTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("ControlType(%#04x)", uint16(t))
+}
+
+// A ControlPacket carries protocol signalling.
+type ControlPacket struct {
+	// Type is the 15-bit control type.
+	Type ControlType
+
+	// Subtype is the control subtype. SRT uses it with
+	// TypeUserDefined to carry the HSv4 extension messages, in which
+	// case it holds an ExtensionType.
+	Subtype uint16
+
+	// TypeInfo is the type-specific information: the acknowledgement
+	// number of an ACK or ACKACK, the message number of a drop request
+	// or the error code of a peer error.
+	TypeInfo uint32
+
+	// Timestamp is the sending time in microseconds relative to the
+	// start of the connection.
+	Timestamp uint32
+
+	// DestinationSocketID is the SRT socket ID of the receiver. It is
+	// zero in the first handshake a caller sends.
+	DestinationSocketID uint32
+
+	// CIF is the raw control information field.
+	CIF []byte
+}
+
+// IsControl implements the Packet interface.
+func (p *ControlPacket) IsControl() bool { return true }
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (p *ControlPacket) MarshalBinary() ([]byte, error) {
+	if p.Type > 0x7fff {
+		return nil, fmt.Errorf("packet: control type %#x out of range", uint16(p.Type))
+	}
+	b := make([]byte, HeaderLen+len(p.CIF))
+	binary.BigEndian.PutUint32(b[0:], controlFlag|uint32(p.Type)<<16|uint32(p.Subtype))
+	binary.BigEndian.PutUint32(b[4:], p.TypeInfo)
+	binary.BigEndian.PutUint32(b[8:], p.Timestamp)
+	binary.BigEndian.PutUint32(b[12:], p.DestinationSocketID)
+	copy(b[HeaderLen:], p.CIF)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (p *ControlPacket) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLen {
+		return ErrTruncated
+	}
+	w0 := binary.BigEndian.Uint32(b[0:])
+	if w0&controlFlag == 0 {
+		return errors.New("packet: not a control packet")
+	}
+	*p = ControlPacket{
+		Type:                ControlType(w0 >> 16 & 0x7fff),
+		Subtype:             uint16(w0),
+		TypeInfo:            binary.BigEndian.Uint32(b[4:]),
+		Timestamp:           binary.BigEndian.Uint32(b[8:]),
+		DestinationSocketID: binary.BigEndian.Uint32(b[12:]),
+		CIF:                 append([]byte(nil), b[HeaderLen:]...),
+	}
+	return nil
+}
+
+// Handshake decodes the control information field of a handshake.
+func (p *ControlPacket) Handshake() (*Handshake, error) {
+	if p.Type != TypeHandshake {
+		return nil, fmt.Errorf("packet: %v is not a handshake", p.Type)
+	}
+	h := new(Handshake)
+	if err := h.UnmarshalBinary(p.CIF); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// ACK decodes the control information field of an ACK. The
+// acknowledgement number is in TypeInfo.
+func (p *ControlPacket) ACK() (*ACK, error) {
+	if p.Type != TypeACK {
+		return nil, fmt.Errorf("packet: %v is not an ack", p.Type)
+	}
+	a := new(ACK)
+	if err := a.UnmarshalBinary(p.CIF); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// NAK decodes the loss list of a NAK.
+func (p *ControlPacket) NAK() (*NAK, error) {
+	if p.Type != TypeNAK {
+		return nil, fmt.Errorf("packet: %v is not a nak", p.Type)
+	}
+	n := new(NAK)
+	if err := n.UnmarshalBinary(p.CIF); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// DropRequest decodes the sequence range of a drop request. The message
+// number is in TypeInfo.
+func (p *ControlPacket) DropRequest() (*DropRequest, error) {
+	if p.Type != TypeDropRequest {
+		return nil, fmt.Errorf("packet: %v is not a drop request", p.Type)
+	}
+	d := new(DropRequest)
+	if err := d.UnmarshalBinary(p.CIF); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Extension returns the HSv4 extension message carried by a
+// user-defined control packet.
+func (p *ControlPacket) Extension() (Extension, error) {
+	if p.Type != TypeUserDefined {
+		return Extension{}, fmt.Errorf("packet: %v does not carry an extension", p.Type)
+	}
+	return Extension{Type: ExtensionType(p.Subtype), Content: append([]byte(nil), p.CIF...)}, nil
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package packet
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+var dataPacketTests = []struct {
+	p    DataPacket
+	wire []byte
+}{
+	{
+		DataPacket{
+			SequenceNumber:      1,
+			Position:            PositionSolo,
+			MessageNumber:       1,
+			Timestamp:           0x1234,
+			DestinationSocketID: 0x0a0b0c0d,
+			Payload:             []byte("hello"),
+		},
+		[]byte{
+			0x00, 0x00, 0x00, 0x01,
+			0xc0, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x12, 0x34,
+			0x0a, 0x0b, 0x0c, 0x0d,
+			'h', 'e', 'l', 'l', 'o',
+		},
+	},
+	{
+		DataPacket{
+			SequenceNumber: MaxSequenceNumber,
+			Position:       PositionFirst,
+			InOrder:        true,
+			Key:            KeyOdd,
+			Retransmitted:  true,
+			MessageNumber:  MaxMessageNumber,
+		},
+		[]byte{
+			0x7f, 0xff, 0xff, 0xff,
+			0xb7, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00,
+		},
+	},
+}
+
+func TestDataPacket(t *testing.T) {
+	for i, tt := range dataPacketTests {
+		b, err := tt.p.MarshalBinary()
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if !bytes.Equal(b, tt.wire) {
+			t.Errorf("#%d: got % x; want % x", i, b, tt.wire)
+		}
+		p, err := Parse(tt.wire)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if p.IsControl() {
+			t.Errorf("#%d: parsed as control packet", i)
+		}
+		if !reflect.DeepEqual(p, &tt.p) {
+			t.Errorf("#%d: got %+v; want %+v", i, p, &tt.p)
+		}
+	}
+}
+
+func TestDataPacketOutOfRange(t *testing.T) {
+	for _, p := range []DataPacket{
+		{SequenceNumber: MaxSequenceNumber + 1},
+		{MessageNumber: MaxMessageNumber + 1},
+	} {
+		if _, err := p.MarshalBinary(); err == nil {
+			t.Errorf("%+v marshaled", p)
+		}
+	}
+}
+
+func TestControlPacket(t *testing.T) {
+	p := &ControlPacket{
+		Type:                TypeACKACK,
+		TypeInfo:            7,
+		Timestamp:           100,
+		DestinationSocketID: 42,
+		CIF:                 []byte{0, 0, 0, 0},
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x80, 0x06, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x07,
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00, 0x00, 0x2a,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got % x; want % x", b, want)
+	}
+	if !IsControl(b) {
+		t.Error("IsControl = false")
+	}
+	q, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q, p) {
+		t.Errorf("got %+v; want %+v", q, p)
+	}
+}
+
+func TestKeepaliveAndShutdown(t *testing.T) {
+	for _, typ := range []ControlType{TypeKeepalive, TypeShutdown} {
+		p := &ControlPacket{Type: typ, Timestamp: 1, DestinationSocketID: 2}
+		b, err := p.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(q, p) {
+			t.Errorf("%v: got %+v; want %+v", typ, q, p)
+		}
+	}
+}
+
+func TestACK(t *testing.T) {
+	for _, a := range []ACK{
+		{LastACKPacketSequenceNumber: 10, Fields: ACKLight},
+		{LastACKPacketSequenceNumber: 10, RTT: 100000, RTTVariance: 50000, AvailableBufferSize: 8192, Fields: ACKSmall},
+		{
+			LastACKPacketSequenceNumber: 10, RTT: 100000, RTTVariance: 50000, AvailableBufferSize: 8192,
+			PacketsReceivingRate: 1000, EstimatedLinkCapacity: 10000, ReceivingRate: 1316000, Fields: ACKFull,
+		},
+	} {
+		b, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != 4*a.Fields {
+			t.Errorf("%d field ack is %d bytes long", a.Fields, len(b))
+		}
+		cp := &ControlPacket{Type: TypeACK, TypeInfo: 3, CIF: b}
+		got, err := cp.ACK()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*got, a) {
+			t.Errorf("got %+v; want %+v", *got, a)
+		}
+	}
+	if _, err := (&ACK{Fields: 2}).MarshalBinary(); err == nil {
+		t.Error("2 field ack marshaled")
+	}
+	if err := new(ACK).UnmarshalBinary([]byte{1, 2}); err != ErrTruncated {
+		t.Errorf("got %v; want %v", err, ErrTruncated)
+	}
+}
+
+func TestNAK(t *testing.T) {
+	n := NAK{Losses: []LossRange{{5, 5}, {10, 20}, {MaxSequenceNumber, 1}}}
+	b, err := n.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0x00, 0x00, 0x00, 0x05,
+		0x80, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00, 0x14,
+		0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01,
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got % x; want % x", b, want)
+	}
+	got, err := (&ControlPacket{Type: TypeNAK, CIF: b}).NAK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*got, n) {
+		t.Errorf("got %+v; want %+v", *got, n)
+	}
+	if lost := got.Lost(); lost != 1+11+3 {
+		t.Errorf("got %d lost packets; want 15", lost)
+	}
+
+	for _, b := range [][]byte{
+		{0x80, 0, 0, 1},
+		{0x80, 0, 0, 1, 0x80, 0, 0, 2},
+		{0, 0, 1},
+	} {
+		if err := new(NAK).UnmarshalBinary(b); err == nil {
+			t.Errorf("% x unmarshaled", b)
+		}
+	}
+}
+
+func TestDropRequest(t *testing.T) {
+	d := DropRequest{FirstSequenceNumber: 100, LastSequenceNumber: 110}
+	b, _ := d.MarshalBinary()
+	got, err := (&ControlPacket{Type: TypeDropRequest, CIF: b}).DropRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != d {
+		t.Errorf("got %+v; want %+v", *got, d)
+	}
+}
+
+func TestWrongControlType(t *testing.T) {
+	p := &ControlPacket{Type: TypeKeepalive}
+	if _, err := p.Handshake(); err == nil {
+		t.Error("keepalive decoded as handshake")
+	}
+	if _, err := p.ACK(); err == nil {
+		t.Error("keepalive decoded as ack")
+	}
+	if _, err := p.NAK(); err == nil {
+		t.Error("keepalive decoded as nak")
+	}
+	if _, err := p.DropRequest(); err == nil {
+		t.Error("keepalive decoded as drop request")
+	}
+	if _, err := p.Extension(); err == nil {
+		t.Error("keepalive decoded as extension")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	for n := 0; n < HeaderLen; n++ {
+		if _, err := Parse(make([]byte, n)); err != ErrTruncated {
+			t.Errorf("%d bytes: got %v; want %v", n, err, ErrTruncated)
+		}
+	}
+}
+
+// TestParseRandom feeds mutated packets to Parse, like the gofuzz
+// harness does, and checks that whatever parses also round-trips.
+func TestParseRandom(t *testing.T) {
+	var seeds [][]byte
+	for _, tt := range dataPacketTests {
+		seeds = append(seeds, tt.wire)
+	}
+	for _, tt := range handshakeTests {
+		b, _ := tt.MarshalBinary()
+		cp := &ControlPacket{Type: TypeHandshake, CIF: b}
+		b, _ = cp.MarshalBinary()
+		seeds = append(seeds, b)
+	}
+	r := rand.New(rand.NewSource(1))
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	for i := 0; i < n; i++ {
+		b := append([]byte(nil), seeds[r.Intn(len(seeds))]...)
+		for j := r.Intn(8); j >= 0 && len(b) > 0; j-- {
+			switch r.Intn(3) {
+			case 0:
+				b[r.Intn(len(b))] = byte(r.Intn(256))
+			case 1:
+				b = b[:r.Intn(len(b))]
+			case 2:
+				b = append(b, byte(r.Intn(256)))
+			}
+		}
+		checkRoundTrip(t, b)
+	}
+}
+
+// checkRoundTrip is the property tested by Fuzz.
+func checkRoundTrip(t *testing.T, b []byte) {
+	p, err := Parse(b)
+	if err != nil {
+		return
+	}
+	out, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("% x: parsed but does not marshal: %v", b, err)
+	}
+	if !bytes.Equal(out, b) {
+		t.Fatalf("% x: marshaled as % x", b, out)
+	}
+	cp, ok := p.(*ControlPacket)
+	if !ok || cp.Type != TypeHandshake {
+		return
+	}
+	h, err := cp.Handshake()
+	if err != nil {
+		return
+	}
+	cif, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatalf("% x: handshake does not marshal: %v", b, err)
+	}
+	if !bytes.Equal(cif, cp.CIF) {
+		t.Fatalf("% x: handshake marshaled as % x", cp.CIF, cif)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// A Preset is a named set of options that agree with each other for a
+// kind of link. The options of a preset are a starting point: any of them
+// can be overridden when the OptionSet is made.
+type Preset struct {
+	name    string
+	options OptionSet
+}
+
+var (
+	// PresetLive is the libsrt live mode: timestamp based delivery of
+	// MPEG-TS sized messages with too-late packets dropped, and 120ms of
+	// latency.
+	PresetLive = Preset{"live", Options(
+		"transtype", strconv.Itoa(srtapi.TypeLive),
+		"messageapi", "true",
+		"tsbpdmode", "true",
+		"tlpktdrop", "true",
+		"nakreport", "true",
+		"congestion", "live",
+		"payloadsize", "1316",
+		"latency", "120",
+		"linger", "0",
+	)}
+
+	// PresetLowLatency is live mode for short, clean links, such as
+	// within a data center, with 40ms of latency.
+	PresetLowLatency = Preset{"lowlatency", Options(
+		"transtype", strconv.Itoa(srtapi.TypeLive),
+		"messageapi", "true",
+		"tsbpdmode", "true",
+		"tlpktdrop", "true",
+		"nakreport", "true",
+		"congestion", "live",
+		"payloadsize", "1316",
+		"latency", "40",
+		"snddropdelay", "0",
+		"linger", "0",
+	)}
+
+	// PresetLossyNetwork is live mode for long or lossy links, such as
+	// the public internet or cellular uplinks. It trades latency for
+	// time to retransmit, tolerates reordering and allows half of the
+	// input bandwidth for retransmissions.
+	PresetLossyNetwork = Preset{"lossy", Options(
+		"transtype", strconv.Itoa(srtapi.TypeLive),
+		"messageapi", "true",
+		"tsbpdmode", "true",
+		"tlpktdrop", "true",
+		"nakreport", "true",
+		"congestion", "live",
+		"payloadsize", "1316",
+		"latency", "1000",
+		"lossmaxttl", "40",
+		"oheadbw", "50",
+		"peeridletimeo", "10000",
+		"linger", "0",
+	)}
+
+	// PresetFile is the libsrt file mode: a reliable byte stream with the
+	// file congestion controller and large buffers, for transfers rather
+	// than live streams.
+	PresetFile = Preset{"file", Options(
+		"transtype", strconv.Itoa(srtapi.TypeFile),
+		"messageapi", "false",
+		"tsbpdmode", "false",
+		"tlpktdrop", "false",
+		"nakreport", "false",
+		"congestion", "file",
+		"payloadsize", "0",
+		"fc", "32768",
+		"sndbuf", "48234496",
+		"rcvbuf", "48234496",
+		"linger", "180",
+	)}
+)
+
+// String returns the name of p.
+func (p Preset) String() string { return p.name }
+
+// Options returns the options of p with the overrides applied in order.
+// A override replaces the option of p with the same key. The result is
+// checked with Validate before it is returned.
+func (p Preset) Options(overrides ...OptionSet) (OptionSet, error) {
+	options := OptionSet{list: append([]option(nil), p.options.list...)}
+	for _, o := range overrides {
+		options.list = append(options.list, o.list...)
+	}
+	if err := options.Validate(); err != nil {
+		return OptionSet{}, err
+	}
+	return options, nil
+}
+
+// An OptionError describes an invalid option value or a combination of
+// options that libsrt would refuse or silently change.
+type OptionError struct {
+	Key   string
+	Value string
+	Err   string
+}
+
+func (e *OptionError) Error() string {
+	if e == nil {
+		return "<nil>"
+	}
+	return "srt: option " + e.Key + "=" + e.Value + ": " + e.Err
+}
+
+// Defaults of libsrt used to check combinations of options.
+const (
+	defaultMss         = 1500
+	defaultFc          = 25600
+	maxLivePayloadSize = 1456
+	udpHeaderSize      = 28
+)
+
+// Validate checks that every option of s is known and has a valid value,
+// and that the options agree with each other, taking the libsrt defaults
+// for the options s does not hold. The last value of a key counts.
+func (s OptionSet) Validate() error {
+	values := make(map[string]interface{})
+	raw := make(map[string]string)
+	for _, o := range s.list {
+		so := lookupOption(o.key)
+		if so == nil {
+			return &OptionError{Key: o.key, Value: o.value, Err: "unknown option"}
+		}
+		v, err := so.extract(o.value)
+		if err != nil {
+			return &OptionError{Key: o.key, Value: o.value, Err: "invalid " + typeName(so.typ)}
+		}
+		values[o.key], raw[o.key] = v, o.value
+	}
+	intValue := func(key string, def int) int {
+		if v, ok := values[key].(int); ok {
+			return v
+		}
+		return def
+	}
+	isSet := func(key string, want bool) bool {
+		v, ok := values[key].(bool)
+		return ok && v == want
+	}
+	invalid := func(key, reason string) error {
+		return &OptionError{Key: key, Value: raw[key], Err: reason}
+	}
+
+	switch intValue("transtype", srtapi.TypeLive) {
+	case srtapi.TypeLive:
+		if isSet("messageapi", false) {
+			return invalid("messageapi", "live mode requires the message API")
+		}
+		if c, ok := raw["congestion"]; ok && c != "live" {
+			return invalid("congestion", "live mode requires the live congestion controller")
+		}
+		if intValue("payloadsize", 0) > maxLivePayloadSize {
+			return invalid("payloadsize", "exceeds "+strconv.Itoa(maxLivePayloadSize)+" bytes in live mode")
+		}
+	case srtapi.TypeFile:
+		if isSet("tsbpdmode", true) {
+			return invalid("tsbpdmode", "not supported in file mode")
+		}
+		if isSet("tlpktdrop", true) {
+			return invalid("tlpktdrop", "not supported in file mode")
+		}
+		if c, ok := raw["congestion"]; ok && c != "file" {
+			return invalid("congestion", "file mode requires the file congestion controller")
+		}
+	default:
+		return invalid("transtype", "unknown transmission type")
+	}
+	if f, ok := raw["packetfilter"]; ok && f != "" {
+		if intValue("transtype", srtapi.TypeLive) != srtapi.TypeLive {
+			return invalid("packetfilter", "only supported in live mode")
+		}
+		if strings.HasPrefix(f, "fec,") || f == "fec" {
+			if _, err := ParseFECConfig(f); err != nil {
+				return err
+			}
+		}
+	}
+	if isSet("tlpktdrop", true) && isSet("tsbpdmode", false) {
+		return invalid("tlpktdrop", "requires tsbpdmode")
+	}
+
+	mss := intValue("mss", defaultMss)
+	if mss < 76 || mss > defaultMss {
+		return invalid("mss", "out of range 76-1500")
+	}
+	fc := intValue("fc", defaultFc)
+	if fc < 32 {
+		return invalid("fc", "less than 32 packets")
+	}
+	if rcvbuf := intValue("rcvbuf", 0); rcvbuf > fc*(mss-udpHeaderSize) {
+		return invalid("rcvbuf", "exceeds fc packets of mss")
+	}
+	switch intValue("pbkeylen", 0) {
+	case 0, 16, 24, 32:
+	default:
+		return invalid("pbkeylen", "not 0, 16, 24 or 32")
+	}
+	if p, ok := raw["passphrase"]; ok && p != "" && (len(p) < 10 || len(p) > 79) {
+		return &OptionError{Key: "passphrase", Value: "<hidden>", Err: "not 10 to 79 characters long"}
+	}
+	if intValue("latency", 0) < 0 {
+		return invalid("latency", "negative")
+	}
+	return nil
+}
+
+func lookupOption(name string) *socketOption {
+	for i := range srtOptions {
+		if srtOptions[i].name == name {
+			return &srtOptions[i]
+		}
+	}
+	return nil
+}
+
+func typeName(typ int) string {
+	switch typ {
+	case typeInt, typeInt64, typeLinger:
+		return "integer"
+	case typeBool:
+		return "boolean"
+	}
+	return "string"
+}
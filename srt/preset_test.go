@@ -0,0 +1,77 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	for _, p := range []Preset{PresetLive, PresetLowLatency, PresetLossyNetwork, PresetFile} {
+		if _, err := p.Options(); err != nil {
+			t.Errorf("%v: %v", p, err)
+		}
+	}
+}
+
+func TestPresetOverride(t *testing.T) {
+	options, err := PresetLive.Options(Options("latency", "400"), Options("latency", "500", "streamid", "live/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithOptions(context.Background(), options)
+	for k, want := range map[string]string{"latency": "500", "streamid": "live/1", "tlpktdrop": "true"} {
+		if v, _ := Option(ctx, k); v != want {
+			t.Errorf("%s = %q; want %q", k, v, want)
+		}
+	}
+	if v, _ := Option(WithOptions(context.Background(), PresetLive.options), "latency"); v != "120" {
+		t.Errorf("preset changed by override: latency = %q", v)
+	}
+}
+
+var validateOptionsTests = []struct {
+	options OptionSet
+	key     string // key of the expected error, if any
+}{
+	{Options(), ""},
+	{Options("transtype", "1", "messageapi", "true"), ""},
+	{Options("messageapi", "false"), "messageapi"},
+	{Options("congestion", "file"), "congestion"},
+	{Options("payloadsize", "1500"), "payloadsize"},
+	{Options("transtype", "1", "tsbpdmode", "true"), "tsbpdmode"},
+	{Options("transtype", "1", "tlpktdrop", "true"), "tlpktdrop"},
+	{Options("transtype", "1", "congestion", "live"), "congestion"},
+	{Options("transtype", "2"), "transtype"},
+	{Options("tsbpdmode", "false", "tlpktdrop", "true"), "tlpktdrop"},
+	{Options("fc", "16"), "fc"},
+	{Options("fc", "100", "rcvbuf", "1000000"), "rcvbuf"},
+	{Options("mss", "9000"), "mss"},
+	{Options("pbkeylen", "20"), "pbkeylen"},
+	{Options("passphrase", "short"), "passphrase"},
+	{Options("latency", "-1"), "latency"},
+	{Options("latency", "fast"), "latency"},
+	{Options("nosuchoption", "1"), "nosuchoption"},
+	{Options("latency", "-1", "latency", "200"), ""},
+}
+
+func TestValidateOptions(t *testing.T) {
+	for i, tt := range validateOptionsTests {
+		err := tt.options.Validate()
+		if tt.key == "" {
+			if err != nil {
+				t.Errorf("#%d: %v", i, err)
+			}
+			continue
+		}
+		oe, ok := err.(*OptionError)
+		if !ok || oe.Key != tt.key {
+			t.Errorf("#%d: got %v; want error for %s", i, err, tt.key)
+		}
+	}
+	if _, err := PresetFile.Options(Options("tsbpdmode", "true")); err == nil {
+		t.Error("incompatible override accepted")
+	}
+}
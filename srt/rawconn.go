@@ -0,0 +1,54 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// A RawConn is a raw SRT socket, the counterpart of syscall.RawConn for
+// sockets of the SRT library.
+type RawConn interface {
+	// Control invokes f on the SRT socket id, which can be passed to
+	// the functions of package srtapi, for instance
+	// srtapi.SetsockflagInt. The socket id is valid only while f
+	// runs.
+	Control(f func(s int)) error
+}
+
+type rawConn struct {
+	fd *netFD
+}
+
+func (c *rawConn) ok() bool { return c != nil && c.fd != nil }
+
+func (c *rawConn) Control(f func(int)) error {
+	if !c.ok() {
+		return srtapi.EINVPARAM
+	}
+	err := c.fd.pfd.RawControl(f)
+	if err != nil {
+		err = &OpError{Op: "raw-control", Net: c.fd.net, Source: nil, Addr: c.fd.laddr, Err: err}
+	}
+	return err
+}
+
+func newRawConn(fd *netFD) *rawConn {
+	return &rawConn{fd: fd}
+}
+
+// controlContextKey is the type of contextKeys used for the Control
+// function of a Dialer or ListenConfig.
+type controlContextKey struct{}
+
+func withControl(ctx context.Context, fn func(string, string, RawConn) error) context.Context {
+	return context.WithValue(ctx, controlContextKey{}, fn)
+}
+
+func controlValue(ctx context.Context) func(string, string, RawConn) error {
+	fn, _ := ctx.Value(controlContextKey{}).(func(string, string, RawConn) error)
+	return fn
+}
@@ -0,0 +1,482 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+)
+
+// OutagePolicy tells a ReconnectingConn what to do with writes while it
+// is not connected.
+type OutagePolicy int
+
+const (
+	// OutageBlock makes Write wait for the connection to come back, or
+	// for the write deadline.
+	OutageBlock OutagePolicy = iota
+
+	// OutageDrop makes Write discard the data and report success.
+	OutageDrop
+
+	// OutageBuffer makes Write keep the data and send it once the
+	// connection is back. Data older than BufferTime is discarded.
+	OutageBuffer
+)
+
+// Defaults of ReconnectingConn.
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+	defaultBufferTime = time.Second
+)
+
+// A ReconnectingConn is a caller connection that dials again, with
+// exponential backoff and jitter, whenever the connection is lost. It
+// implements net.Conn, so it can replace the connection returned by
+// Dialer.DialContext in existing writers and readers.
+//
+// Reads wait for the connection to come back; a read in progress when
+// the connection is lost does not return an error. Writes follow Policy.
+//
+// The exported fields must not be changed after the first call to Read,
+// Write or Connect.
+type ReconnectingConn struct {
+	// MinBackoff is the delay before the first redial. It doubles
+	// after each failed attempt up to MaxBackoff. Zero means 100ms
+	// and 10s respectively.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Policy is applied to writes while disconnected.
+	Policy OutagePolicy
+
+	// BufferTime is how long OutageBuffer keeps written data. Zero
+	// means 1s.
+	BufferTime time.Duration
+
+	// OnConnect, if non-nil, is called each time a connection is
+	// established.
+	OnConnect func(c net.Conn)
+
+	// OnDisconnect, if non-nil, is called with the error that ended a
+	// connection.
+	OnDisconnect func(err error)
+
+	// OnRetry, if non-nil, is called after a failed dial with the
+	// number of consecutive failures and the delay before the next
+	// attempt.
+	OnRetry func(attempt int, delay time.Duration, err error)
+
+	dial    func(ctx context.Context, network, address string) (net.Conn, error)
+	network string
+	address string
+	ctx     context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+	lost    chan error
+
+	mu        sync.Mutex
+	conn      net.Conn
+	pending   net.Conn      // new conn while the buffered data is sent
+	ready     chan struct{} // closed while conn is set
+	laddr     net.Addr
+	raddr     net.Addr
+	rdeadline time.Time
+	wdeadline time.Time
+	buffered  []bufferedWrite
+	dropped   int64
+	rnd       *rand.Rand // jitter of the backoff
+}
+
+type bufferedWrite struct {
+	b []byte
+	t time.Time
+}
+
+// NewReconnectingConn returns a connection to address on the named
+// network, dialed with d, which may be nil. Dialing starts on the first
+// call to Read, Write or Connect and stops when ctx is done or the
+// connection is closed.
+func NewReconnectingConn(ctx context.Context, d *Dialer, network, address string) *ReconnectingConn {
+	if d == nil {
+		d = &Dialer{}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &ReconnectingConn{
+		dial:    d.DialContext,
+		network: network,
+		address: address,
+		ctx:     ctx,
+		cancel:  cancel,
+		lost:    make(chan error, 1),
+		ready:   make(chan struct{}),
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Connect starts dialing and waits until the connection is established
+// or ctx is done.
+func (c *ReconnectingConn) Connect(ctx context.Context) error {
+	c.start()
+	c.mu.Lock()
+	ready := c.ready
+	c.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-c.ctx.Done():
+		return c.opError("dial", poll.ErrNetClosing)
+	case <-ctx.Done():
+		return c.opError("dial", mapErr(ctx.Err()))
+	}
+}
+
+// Conn returns the current connection, or nil while disconnected.
+func (c *ReconnectingConn) Conn() net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn
+}
+
+// Dropped returns the number of writes discarded during outages.
+func (c *ReconnectingConn) Dropped() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Read reads from the current connection, waiting for a connection
+// while there is none.
+func (c *ReconnectingConn) Read(b []byte) (int, error) {
+	c.start()
+	for {
+		conn, err := c.current(c.readDeadline, "read")
+		if err != nil {
+			return 0, err
+		}
+		n, err := conn.Read(b)
+		if err == nil || isTimeout(err) {
+			return n, err
+		}
+		c.disconnect(conn, err)
+	}
+}
+
+// Write writes to the current connection, applying Policy while there
+// is none.
+func (c *ReconnectingConn) Write(b []byte) (int, error) {
+	c.start()
+	for {
+		c.mu.Lock()
+		if c.ctx.Err() != nil {
+			c.mu.Unlock()
+			return 0, c.opError("write", poll.ErrNetClosing)
+		}
+		if c.conn == nil {
+			switch c.Policy {
+			case OutageDrop:
+				c.dropped++
+				c.mu.Unlock()
+				return len(b), nil
+			case OutageBuffer:
+				c.bufferLocked(b)
+				c.mu.Unlock()
+				return len(b), nil
+			}
+		}
+		c.mu.Unlock()
+		conn, err := c.current(c.writeDeadline, "write")
+		if err != nil {
+			return 0, err
+		}
+		n, err := conn.Write(b)
+		if err == nil || isTimeout(err) {
+			return n, err
+		}
+		c.disconnect(conn, err)
+	}
+}
+
+// Close closes the current connection and stops redialing.
+func (c *ReconnectingConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return c.opError("close", poll.ErrNetClosing)
+	}
+	c.cancel()
+	if c.pending != nil {
+		c.pending.Close()
+	}
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the last connection.
+func (c *ReconnectingConn) LocalAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.laddr
+}
+
+// RemoteAddr returns the remote address of the last connection.
+func (c *ReconnectingConn) RemoteAddr() net.Addr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.raddr
+}
+
+// SetDeadline sets the read and write deadlines, which also bound the
+// wait for a connection.
+func (c *ReconnectingConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rdeadline, c.wdeadline = t, t
+	if c.conn != nil {
+		return c.conn.SetDeadline(t)
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline.
+func (c *ReconnectingConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rdeadline = t
+	if c.conn != nil {
+		return c.conn.SetReadDeadline(t)
+	}
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline.
+func (c *ReconnectingConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wdeadline = t
+	if c.conn != nil {
+		return c.conn.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+func (c *ReconnectingConn) start() {
+	c.once.Do(func() { go c.run() })
+}
+
+// run dials, waits for the connection to be lost and dials again until
+// the connection is closed.
+func (c *ReconnectingConn) run() {
+	attempt := 0
+	for {
+		conn, err := c.dial(c.ctx, c.network, c.address)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			attempt++
+			delay := c.backoff(attempt)
+			if c.OnRetry != nil {
+				c.OnRetry(attempt, delay, err)
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-c.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			continue
+		}
+		attempt = 0
+		if !c.connected(conn) {
+			conn.Close()
+			return
+		}
+		if c.OnConnect != nil {
+			c.OnConnect(conn)
+		}
+		select {
+		case <-c.ctx.Done():
+			return
+		case err := <-c.lost:
+			if c.OnDisconnect != nil {
+				c.OnDisconnect(err)
+			}
+		}
+	}
+}
+
+// connected installs conn, sends the data buffered during the outage and
+// wakes up waiting readers and writers. The buffered data is sent without
+// holding c.mu, so writes made meanwhile are buffered and sent after it.
+func (c *ReconnectingConn) connected(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return false
+	}
+	c.pending = conn
+	defer func() { c.pending = nil }()
+	for {
+		conn.SetWriteDeadline(c.wdeadline)
+		c.expireLocked(time.Now())
+		buffered := c.buffered
+		c.buffered = nil
+		if len(buffered) == 0 {
+			break
+		}
+		c.mu.Unlock()
+		err := flushBuffered(conn, buffered)
+		c.mu.Lock()
+		if c.ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			c.buffered = nil
+			break
+		}
+	}
+	conn.SetReadDeadline(c.rdeadline)
+	conn.SetWriteDeadline(c.wdeadline)
+	c.conn = conn
+	c.laddr, c.raddr = conn.LocalAddr(), conn.RemoteAddr()
+	close(c.ready)
+	return true
+}
+
+func flushBuffered(conn net.Conn, buffered []bufferedWrite) error {
+	for _, w := range buffered {
+		if _, err := conn.Write(w.b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// disconnect drops conn after it failed with err, unless another reader
+// or writer did so already.
+func (c *ReconnectingConn) disconnect(conn net.Conn, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != conn {
+		return
+	}
+	conn.Close()
+	c.conn = nil
+	c.ready = make(chan struct{})
+	select {
+	case c.lost <- err:
+	default:
+	}
+}
+
+// current returns the current connection, waiting for one until the
+// deadline returned by deadline.
+func (c *ReconnectingConn) current(deadline func() time.Time, op string) (net.Conn, error) {
+	for {
+		c.mu.Lock()
+		conn, ready := c.conn, c.ready
+		c.mu.Unlock()
+		if conn != nil {
+			return conn, nil
+		}
+		if err := c.wait(ready, deadline()); err != nil {
+			return nil, c.opError(op, err)
+		}
+	}
+}
+
+// wait waits for ready to be closed until the deadline, if not zero.
+func (c *ReconnectingConn) wait(ready <-chan struct{}, deadline time.Time) error {
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return poll.ErrTimeout
+		}
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-ready:
+		return nil
+	case <-c.ctx.Done():
+		return poll.ErrNetClosing
+	case <-expired:
+		return poll.ErrTimeout
+	}
+}
+
+func (c *ReconnectingConn) readDeadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rdeadline
+}
+
+func (c *ReconnectingConn) writeDeadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wdeadline
+}
+
+func (c *ReconnectingConn) bufferLocked(b []byte) {
+	now := time.Now()
+	c.expireLocked(now)
+	c.buffered = append(c.buffered, bufferedWrite{b: append([]byte(nil), b...), t: now})
+}
+
+// expireLocked discards buffered data older than BufferTime.
+func (c *ReconnectingConn) expireLocked(now time.Time) {
+	max := c.BufferTime
+	if max <= 0 {
+		max = defaultBufferTime
+	}
+	i := 0
+	for i < len(c.buffered) && now.Sub(c.buffered[i].t) > max {
+		i++
+	}
+	c.dropped += int64(i)
+	c.buffered = c.buffered[i:]
+}
+
+// backoff returns the delay before the given attempt: the exponential
+// backoff with half of it randomized.
+func (c *ReconnectingConn) backoff(attempt int) time.Duration {
+	min, max := c.MinBackoff, c.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return d/2 + time.Duration(c.rnd.Int63n(int64(d/2)+1))
+}
+
+func (c *ReconnectingConn) opError(op string, err error) error {
+	return &OpError{Op: op, Net: c.network, Source: nil, Addr: nil, Err: err}
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pipeDialer hands out the client ends of net.Pipe connections and sends
+// the server ends to peers. Dials fail while fail is positive.
+type pipeDialer struct {
+	fail  int
+	peers chan net.Conn
+}
+
+func (d *pipeDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.fail > 0 {
+		d.fail--
+		return nil, errors.New("connection refused")
+	}
+	c, s := net.Pipe()
+	select {
+	case d.peers <- s:
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newTestReconnectingConn(d *pipeDialer) *ReconnectingConn {
+	c := NewReconnectingConn(context.Background(), nil, "srt", "127.0.0.1:5000")
+	c.dial = d.dial
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = 4 * time.Millisecond
+	return c
+}
+
+func readString(t *testing.T, c net.Conn) string {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(someTimeout))
+	b := make([]byte, 64)
+	n, err := c.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b[:n])
+}
+
+func TestReconnectingConnRedial(t *testing.T) {
+	d := &pipeDialer{fail: 2, peers: make(chan net.Conn)}
+	c := newTestReconnectingConn(d)
+	defer c.Close()
+	retries := make(chan int, 10)
+	disconnects := make(chan error, 10)
+	c.OnRetry = func(attempt int, delay time.Duration, err error) { retries <- attempt }
+	c.OnDisconnect = func(err error) { disconnects <- err }
+
+	go c.Write([]byte("first"))
+	peer := <-d.peers
+	if got := readString(t, peer); got != "first" {
+		t.Errorf("got %q", got)
+	}
+	if len(retries) != 2 {
+		t.Errorf("got %d retries; want 2", len(retries))
+	}
+
+	peer.Close()
+	go c.Write([]byte("second"))
+	peer = <-d.peers
+	defer peer.Close()
+	if got := readString(t, peer); got != "second" {
+		t.Errorf("got %q", got)
+	}
+	if len(disconnects) != 1 {
+		t.Errorf("got %d disconnects; want 1", len(disconnects))
+	}
+}
+
+func TestReconnectingConnBuffer(t *testing.T) {
+	d := &pipeDialer{peers: make(chan net.Conn)}
+	c := newTestReconnectingConn(d)
+	defer c.Close()
+	c.Policy = OutageBuffer
+	for _, m := range []string{"a", "b"} {
+		if n, err := c.Write([]byte(m)); n != 1 || err != nil {
+			t.Fatalf("Write = %d, %v", n, err)
+		}
+	}
+	peer := <-d.peers
+	defer peer.Close()
+	for _, want := range []string{"a", "b"} {
+		if got := readString(t, peer); got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	}
+}
+
+func TestReconnectingConnBufferFlushUnlocked(t *testing.T) {
+	d := &pipeDialer{peers: make(chan net.Conn)}
+	c := newTestReconnectingConn(d)
+	defer c.Close()
+	c.Policy = OutageBuffer
+	c.Write([]byte("a"))
+	peer := <-d.peers
+	defer peer.Close()
+
+	// The flush of "a" blocks until the peer reads it. Meanwhile the
+	// connection must stay usable and keep buffering.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if conn := c.Conn(); conn != nil {
+			t.Errorf("Conn = %v during the flush; want nil", conn)
+		}
+		c.SetReadDeadline(time.Time{})
+		if n, err := c.Write([]byte("b")); n != 1 || err != nil {
+			t.Errorf("Write = %d, %v", n, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(someTimeout):
+		t.Fatal("connection blocked by the flush")
+	}
+	for _, want := range []string{"a", "b"} {
+		if got := readString(t, peer); got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	}
+	if err := c.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconnectingConnCloseDuringFlush(t *testing.T) {
+	d := &pipeDialer{peers: make(chan net.Conn)}
+	c := newTestReconnectingConn(d)
+	c.Policy = OutageBuffer
+	c.Write([]byte("a"))
+	peer := <-d.peers
+	defer peer.Close()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	peer.SetReadDeadline(time.Now().Add(someTimeout))
+	b := make([]byte, 64)
+	for {
+		if _, err := peer.Read(b); err != nil {
+			if isTimeout(err) {
+				t.Fatal("pending connection not closed")
+			}
+			break
+		}
+	}
+}
+
+func TestReconnectingConnDropAndDeadline(t *testing.T) {
+	d := &pipeDialer{peers: make(chan net.Conn)}
+	c := newTestReconnectingConn(d)
+	defer c.Close()
+	c.Policy = OutageDrop
+	if n, err := c.Write([]byte("x")); n != 1 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if c.Dropped() != 1 {
+		t.Errorf("dropped %d writes; want 1", c.Dropped())
+	}
+
+	c.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := c.Read(make([]byte, 1))
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("Read while disconnected = %v; want timeout", err)
+	}
+	c.Close()
+	if _, err := c.Read(make([]byte, 1)); err == nil {
+		t.Error("Read after Close succeeded")
+	}
+}
+
+func TestReconnectingConnBackoffJitter(t *testing.T) {
+	var delays [2][]time.Duration
+	for i := range delays {
+		c := NewReconnectingConn(context.Background(), nil, "srt", "127.0.0.1:5000")
+		c.MinBackoff, c.MaxBackoff = time.Hour, time.Hour
+		for attempt := 1; attempt <= 4; attempt++ {
+			d := c.backoff(attempt)
+			if d < 30*time.Minute || d > time.Hour {
+				t.Fatalf("backoff %v out of range", d)
+			}
+			delays[i] = append(delays[i], d)
+		}
+		time.Sleep(time.Microsecond)
+	}
+	if reflect.DeepEqual(delays[0], delays[1]) {
+		t.Errorf("two connections drew the same delays %v", delays[0])
+	}
+}
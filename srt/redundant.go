@@ -0,0 +1,401 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+)
+
+// Parameters of redundant transmission.
+const (
+	redundantHeaderSize      = 8
+	redundantMaxMessage      = 1500
+	defaultRedundantMaxDelay = 100 * time.Millisecond
+)
+
+var errShortMessage = errors.New("message shorter than the sequence header")
+
+// PathHealth is the state of one path of a RedundantWriter or
+// RedundantReader.
+type PathHealth struct {
+	RemoteAddr net.Addr
+
+	// Err is the error that took the path down, or nil while it is up.
+	Err error
+
+	// Messages is the number of messages written to or read from the
+	// path.
+	Messages int64
+
+	// First is the number of messages the path delivered before any
+	// other path. It is only counted by a RedundantReader.
+	First int64
+
+	// RTT, Lost and Dropped are the round trip time and the number of
+	// packets lost and dropped, as reported by libsrt: by the sender
+	// for a RedundantWriter, by the receiver for a RedundantReader.
+	// They are zero for connections other than *SRTConn.
+	RTT     time.Duration
+	Lost    int64
+	Dropped int64
+}
+
+// redundantPath is a connection used by redundant transmission.
+type redundantPath struct {
+	conn     net.Conn
+	err      error
+	messages int64
+	first    int64
+
+	// last is the sequence number the path delivered last, and epoch
+	// the number of writer restarts the reader had seen then.
+	last  uint64
+	epoch int
+}
+
+func newRedundantPaths(conns []net.Conn) []*redundantPath {
+	paths := make([]*redundantPath, len(conns))
+	for i, c := range conns {
+		paths[i] = &redundantPath{conn: c}
+	}
+	return paths
+}
+
+// health returns the health of p, with the loss counters of the
+// receiver if receiving is true, of the sender otherwise. The caller
+// holds the lock guarding p.
+func (p *redundantPath) health(receiving bool) PathHealth {
+	h := PathHealth{RemoteAddr: p.conn.RemoteAddr(), Err: p.err, Messages: p.messages, First: p.first}
+	c, ok := p.conn.(*SRTConn)
+	if !ok || !c.ok() || p.err != nil {
+		return h
+	}
+	st, err := getbstatsFunc(c.fd.pfd.Sysfd)
+	if err != nil {
+		return h
+	}
+	h.RTT = time.Duration(st.MsRTT * float64(time.Millisecond))
+	if receiving {
+		h.Lost, h.Dropped = st.PktRcvLossTotal, st.PktRcvDropTotal
+	} else {
+		h.Lost, h.Dropped = st.PktSndLossTotal, st.PktSndDropTotal
+	}
+	return h
+}
+
+func closePaths(paths []*redundantPath) error {
+	var err error
+	for _, p := range paths {
+		if cerr := p.conn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// A RedundantWriter sends each message on several connections, usually
+// over different networks, in the manner of SMPTE 2022-7. Each message
+// is prefixed with an 8 byte sequence number so that a RedundantReader
+// on the other end can merge the paths. The connections must be in live
+// mode, where each Write is a message, and the messages must leave room
+// for the header in the payload size.
+type RedundantWriter struct {
+	mu    sync.Mutex
+	paths []*redundantPath
+	seq   uint64
+	buf   []byte
+}
+
+// NewRedundantWriter returns a writer to all of conns.
+func NewRedundantWriter(conns ...net.Conn) *RedundantWriter {
+	return &RedundantWriter{paths: newRedundantPaths(conns)}
+}
+
+// Write writes b as one message to every path that is up. A path whose
+// write fails is taken down. Write fails only when no path is left.
+func (w *RedundantWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf[:0], make([]byte, redundantHeaderSize)...)
+	binary.BigEndian.PutUint64(w.buf, w.seq)
+	w.buf = append(w.buf, b...)
+	w.seq++
+	var lastErr error
+	written := false
+	for _, p := range w.paths {
+		if p.err != nil {
+			lastErr = p.err
+			continue
+		}
+		if _, err := p.conn.Write(w.buf); err != nil {
+			p.err, lastErr = err, err
+			continue
+		}
+		p.messages++
+		written = true
+	}
+	if !written {
+		if lastErr == nil {
+			lastErr = poll.ErrNetClosing
+		}
+		return 0, &OpError{Op: "write", Net: "srt", Source: nil, Addr: nil, Err: lastErr}
+	}
+	return len(b), nil
+}
+
+// Paths returns the health of each path, in the order of the connections
+// given to NewRedundantWriter.
+func (w *RedundantWriter) Paths() []PathHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	h := make([]PathHealth, len(w.paths))
+	for i, p := range w.paths {
+		h[i] = p.health(false)
+	}
+	return h
+}
+
+// Close closes all the connections.
+func (w *RedundantWriter) Close() error {
+	return closePaths(w.paths)
+}
+
+// A RedundantReader merges the messages sent by a RedundantWriter on
+// several connections. It returns each message once, in sequence order,
+// as soon as any path delivers it, so the loss or failure of a path is
+// hidden as long as another path delivers the message in time. The first
+// message is held for MaxDelay, so that the paths can be aligned on the
+// lowest sequence number they deliver.
+//
+// A path whose sequence numbers go backwards carries a restarted writer.
+// The reader then aligns again on the new sequence numbers and ignores
+// the other paths until they go backwards too.
+type RedundantReader struct {
+	// MaxDelay is how long a message that arrived out of order waits
+	// for the messages before it. When it expires, the missing
+	// messages are given up as lost. Zero means 100ms.
+	MaxDelay time.Duration
+
+	readMu sync.Mutex // serializes Read
+	in     chan redundantMessage
+	done   chan struct{}
+	once   sync.Once
+
+	mu         sync.Mutex
+	paths      []*redundantPath
+	pending    map[uint64]redundantMessage
+	next       uint64
+	started    bool
+	epoch      int // number of writer restarts
+	down       int
+	lost       int64
+	duplicates int64
+}
+
+type redundantMessage struct {
+	path    int
+	seq     uint64
+	b       []byte
+	arrived time.Time
+	err     error
+}
+
+// NewRedundantReader returns a reader merging conns and starts reading
+// from them.
+func NewRedundantReader(conns ...net.Conn) *RedundantReader {
+	r := &RedundantReader{
+		paths:   newRedundantPaths(conns),
+		in:      make(chan redundantMessage, len(conns)),
+		done:    make(chan struct{}),
+		pending: make(map[uint64]redundantMessage),
+	}
+	for i := range r.paths {
+		go r.readPath(i)
+	}
+	return r
+}
+
+// readPath reads the messages of path i until it fails.
+func (r *RedundantReader) readPath(i int) {
+	c := r.paths[i].conn
+	for {
+		b := make([]byte, redundantMaxMessage)
+		n, err := c.Read(b)
+		m := redundantMessage{path: i, arrived: time.Now(), err: err}
+		if err == nil {
+			if n < redundantHeaderSize {
+				m.err = errShortMessage
+			} else {
+				m.seq, m.b = binary.BigEndian.Uint64(b), b[redundantHeaderSize:n]
+			}
+		}
+		select {
+		case r.in <- m:
+		case <-r.done:
+			return
+		}
+		if m.err != nil {
+			return
+		}
+	}
+}
+
+// Read reads the next message into b. A message longer than b is
+// truncated. Read fails once all paths are down and the messages they
+// delivered have been read.
+func (r *RedundantReader) Read(b []byte) (int, error) {
+	r.readMu.Lock()
+	defer r.readMu.Unlock()
+	for {
+		r.mu.Lock()
+		if m, ok := r.pending[r.next]; ok && r.started {
+			delete(r.pending, r.next)
+			r.next++
+			r.mu.Unlock()
+			return copy(b, m.b), nil
+		}
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if oldest, ok := r.oldestLocked(); ok {
+			wait := time.Until(oldest.arrived.Add(r.maxDelay()))
+			if wait <= 0 {
+				if r.started {
+					r.lost += int64(oldest.seq - r.next)
+				}
+				r.next, r.started = oldest.seq, true
+				r.mu.Unlock()
+				continue
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		} else if r.down == len(r.paths) {
+			err := r.lastErrorLocked()
+			r.mu.Unlock()
+			return 0, err
+		}
+		r.mu.Unlock()
+
+		select {
+		case m := <-r.in:
+			r.receive(m)
+		case <-expired:
+		case <-r.done:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		select {
+		case <-r.done:
+			return 0, &OpError{Op: "read", Net: "srt", Source: nil, Addr: nil, Err: poll.ErrNetClosing}
+		default:
+		}
+	}
+}
+
+// receive takes a message read from a path into account.
+func (r *RedundantReader) receive(m redundantMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.paths[m.path]
+	if m.err != nil {
+		p.err = m.err
+		r.down++
+		return
+	}
+	p.messages++
+	if p.messages > 1 && m.seq < p.last {
+		// The writer restarted. The first path to show it starts a
+		// new epoch; the others join it.
+		if p.epoch == r.epoch {
+			r.epoch++
+			r.pending = make(map[uint64]redundantMessage)
+			r.started = false
+		}
+		p.epoch = r.epoch
+	}
+	p.last = m.seq
+	if p.epoch != r.epoch {
+		// The path still carries the stream of the previous writer.
+		r.duplicates++
+		return
+	}
+	if _, dup := r.pending[m.seq]; dup || r.started && m.seq < r.next {
+		r.duplicates++
+		return
+	}
+	p.first++
+	r.pending[m.seq] = m
+}
+
+// oldestLocked returns the pending message with the lowest sequence
+// number.
+func (r *RedundantReader) oldestLocked() (redundantMessage, bool) {
+	var oldest redundantMessage
+	found := false
+	for seq, m := range r.pending {
+		if !found || seq < oldest.seq {
+			oldest, found = m, true
+		}
+	}
+	return oldest, found
+}
+
+func (r *RedundantReader) lastErrorLocked() error {
+	var err error = io.EOF
+	for _, p := range r.paths {
+		if p.err != nil && p.err != io.EOF {
+			err = &OpError{Op: "read", Net: "srt", Source: nil, Addr: p.conn.RemoteAddr(), Err: p.err}
+		}
+	}
+	return err
+}
+
+// Lost returns the number of messages no path delivered in time.
+func (r *RedundantReader) Lost() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lost
+}
+
+// Duplicates returns the number of messages dropped because another path
+// delivered them first, because they arrived after being given up or
+// because they were sent before the writer restarted.
+func (r *RedundantReader) Duplicates() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.duplicates
+}
+
+// Paths returns the health of each path, in the order of the connections
+// given to NewRedundantReader.
+func (r *RedundantReader) Paths() []PathHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := make([]PathHealth, len(r.paths))
+	for i, p := range r.paths {
+		h[i] = p.health(true)
+	}
+	return h
+}
+
+// Close closes all the connections and makes pending and future reads
+// fail.
+func (r *RedundantReader) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return closePaths(r.paths)
+}
+
+func (r *RedundantReader) maxDelay() time.Duration {
+	if r.MaxDelay > 0 {
+		return r.MaxDelay
+	}
+	return defaultRedundantMaxDelay
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// lossyConn drops the messages for which drop returns true.
+type lossyConn struct {
+	net.Conn
+	n    int
+	drop func(n int) bool
+}
+
+func (c *lossyConn) Write(b []byte) (int, error) {
+	c.n++
+	if c.drop(c.n - 1) {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+func redundantPipes(t *testing.T, drops ...func(int) bool) (*RedundantWriter, *RedundantReader) {
+	var wconns, rconns []net.Conn
+	for _, drop := range drops {
+		w, r := net.Pipe()
+		wconns = append(wconns, &lossyConn{Conn: w, drop: drop})
+		rconns = append(rconns, r)
+	}
+	return NewRedundantWriter(wconns...), NewRedundantReader(rconns...)
+}
+
+func TestRedundantHidesLoss(t *testing.T) {
+	const N = 100
+	w, r := redundantPipes(t,
+		func(n int) bool { return n%3 == 0 },
+		func(n int) bool { return n%3 == 1 },
+	)
+	defer r.Close()
+	go func() {
+		defer w.Close()
+		for i := 0; i < N; i++ {
+			if _, err := w.Write([]byte(strconv.Itoa(i))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	b := make([]byte, 16)
+	for i := 0; i < N; i++ {
+		n, err := r.Read(b)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if got := string(b[:n]); got != strconv.Itoa(i) {
+			t.Fatalf("got message %q; want %d", got, i)
+		}
+	}
+	if _, err := r.Read(b); err != io.EOF {
+		t.Errorf("got %v; want EOF", err)
+	}
+	if lost := r.Lost(); lost != 0 {
+		t.Errorf("lost %d messages", lost)
+	}
+	paths := r.Paths()
+	if paths[0].Messages+paths[1].Messages != N+N/3 {
+		t.Errorf("paths got %d and %d messages", paths[0].Messages, paths[1].Messages)
+	}
+	if paths[0].First+paths[1].First != N || r.Duplicates() != N/3 {
+		t.Errorf("first %d+%d, %d duplicates", paths[0].First, paths[1].First, r.Duplicates())
+	}
+	if paths[0].Err != io.EOF {
+		t.Errorf("path error %v", paths[0].Err)
+	}
+}
+
+func TestRedundantLoss(t *testing.T) {
+	w, r := redundantPipes(t,
+		func(n int) bool { return n == 1 },
+		func(n int) bool { return n == 1 },
+	)
+	defer r.Close()
+	r.MaxDelay = 10 * time.Millisecond
+	go func() {
+		defer w.Close()
+		for i := 0; i < 3; i++ {
+			w.Write([]byte{byte(i)})
+		}
+	}()
+	b := make([]byte, 1)
+	for _, want := range []byte{0, 2} {
+		if _, err := r.Read(b); err != nil || b[0] != want {
+			t.Fatalf("got %d, %v; want %d", b[0], err, want)
+		}
+	}
+	if lost := r.Lost(); lost != 1 {
+		t.Errorf("lost %d messages; want 1", lost)
+	}
+}
+
+func TestRedundantWriterPathDown(t *testing.T) {
+	w1, r1 := net.Pipe()
+	w2, r2 := net.Pipe()
+	w := NewRedundantWriter(w1, w2)
+	r := NewRedundantReader(r1, r2)
+	defer r.Close()
+	w2.Close()
+	go func() {
+		if _, err := w.Write([]byte("a")); err != nil {
+			t.Error(err)
+		}
+		w1.Close()
+		if _, err := w.Write([]byte("b")); err == nil {
+			t.Error("write succeeded without paths")
+		}
+	}()
+	b := make([]byte, 1)
+	if _, err := r.Read(b); err != nil || b[0] != 'a' {
+		t.Fatalf("got %q, %v", b, err)
+	}
+	if _, err := r.Read(b); err != io.EOF {
+		t.Errorf("got %v; want EOF", err)
+	}
+	paths := w.Paths()
+	if paths[0].Messages != 1 || paths[1].Err == nil {
+		t.Errorf("got %+v", paths)
+	}
+}
+
+func TestRedundantWriterRestart(t *testing.T) {
+	w1, r1 := net.Pipe()
+	w2, r2 := net.Pipe()
+	r := NewRedundantReader(r1, r2)
+	defer r.Close()
+	r.MaxDelay = 10 * time.Millisecond
+	b := make([]byte, 16)
+	for _, prefix := range []string{"a", "b"} {
+		w := NewRedundantWriter(w1, w2)
+		go func() {
+			for i := 0; i < 5; i++ {
+				if _, err := w.Write([]byte(prefix + strconv.Itoa(i))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		for i := 0; i < 5; i++ {
+			n, err := r.Read(b)
+			if err != nil {
+				t.Fatalf("message %s%d: %v", prefix, i, err)
+			}
+			if got, want := string(b[:n]), prefix+strconv.Itoa(i); got != want {
+				t.Fatalf("got message %q; want %q", got, want)
+			}
+		}
+	}
+	w1.Close()
+	w2.Close()
+	if _, err := r.Read(b); err != io.EOF {
+		t.Errorf("got %v; want EOF", err)
+	}
+	if lost := r.Lost(); lost != 0 {
+		t.Errorf("lost %d messages", lost)
+	}
+}
+
+func TestRedundantPathHealth(t *testing.T) {
+	orig := getbstatsFunc
+	defer func() { getbstatsFunc = orig }()
+	getbstatsFunc = func(fd int) (srtapi.Bstats, error) {
+		return srtapi.Bstats{
+			MsRTT:           20,
+			PktSentTotal:    1000,
+			PktSndLossTotal: 7,
+			PktSndDropTotal: 8,
+			PktRecvTotal:    1000,
+			PktRcvLossTotal: 3,
+			PktRcvDropTotal: 4,
+		}, nil
+	}
+	p := &redundantPath{conn: newSRTConn(&netFD{net: "srt"})}
+	for _, tt := range []struct {
+		receiving     bool
+		lost, dropped int64
+	}{
+		{false, 7, 8},
+		{true, 3, 4},
+	} {
+		h := p.health(tt.receiving)
+		if h.RTT != 20*time.Millisecond || h.Lost != tt.lost || h.Dropped != tt.dropped {
+			t.Errorf("receiving %v: got %+v; want lost %d, dropped %d", tt.receiving, h, tt.lost, tt.dropped)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import "strconv"
+
+// RejectReason is the reason a listener gives to a caller it rejects
+// during the handshake. Values from RejectPredefined are the codes of the
+// SRT access control convention, most of them following HTTP status
+// codes; values from RejectUserDefined are free for applications.
+type RejectReason int
+
+const (
+	RejectPredefined  RejectReason = 1000
+	RejectUserDefined RejectReason = 2000
+)
+
+// Predefined reject reasons.
+const (
+	RejectFallback            RejectReason = 1000
+	RejectKeyNotSupported     RejectReason = 1001
+	RejectFilePath            RejectReason = 1002
+	RejectHostNotFound        RejectReason = 1003
+	RejectBadRequest          RejectReason = 1400
+	RejectUnauthorized        RejectReason = 1401
+	RejectOverload            RejectReason = 1402
+	RejectForbidden           RejectReason = 1403
+	RejectNotFound            RejectReason = 1404
+	RejectBadMode             RejectReason = 1405
+	RejectUnacceptable        RejectReason = 1406
+	RejectConflict            RejectReason = 1409
+	RejectUnsupportedMedia    RejectReason = 1415
+	RejectLocked              RejectReason = 1423
+	RejectFailedDependency    RejectReason = 1424
+	RejectInternalServerError RejectReason = 1500
+	RejectUnimplemented       RejectReason = 1501
+	RejectGateway             RejectReason = 1502
+	RejectDown                RejectReason = 1503
+	RejectVersion             RejectReason = 1505
+	RejectNoRoom              RejectReason = 1507
+)
+
+var rejectReasonNames = map[RejectReason]string{
+	RejectFallback:            "fallback",
+	RejectKeyNotSupported:     "key not supported",
+	RejectFilePath:            "file path",
+	RejectHostNotFound:        "host not found",
+	RejectBadRequest:          "bad request",
+	RejectUnauthorized:        "unauthorized",
+	RejectOverload:            "overload",
+	RejectForbidden:           "forbidden",
+	RejectNotFound:            "not found",
+	RejectBadMode:             "bad mode",
+	RejectUnacceptable:        "unacceptable",
+	RejectConflict:            "conflict",
+	RejectUnsupportedMedia:    "unsupported media",
+	RejectLocked:              "locked",
+	RejectFailedDependency:    "failed dependency",
+	RejectInternalServerError: "internal server error",
+	RejectUnimplemented:       "unimplemented",
+	RejectGateway:             "gateway",
+	RejectDown:                "down",
+	RejectVersion:             "version",
+	RejectNoRoom:              "no room",
+	RejectRateLimited:         "rate limited",
+}
+
+func (r RejectReason) String() string {
+	if s, ok := rejectReasonNames[r]; ok {
+		return s
+	}
+	if r >= RejectUserDefined {
+		return "user-defined " + strconv.Itoa(int(r-RejectUserDefined))
+	}
+	return "reject reason " + strconv.Itoa(int(r))
+}
+
+// Reject sets reason on the socket ns being accepted and returns the
+// value a listen callback returns to reject the caller:
+//
+//	if !allowed(streamid) {
+//		return srt.Reject(ns, srt.RejectForbidden)
+//	}
+func Reject(ns int, reason RejectReason) int {
+	setrejectreasonFunc(ns, int(reason))
+	return -1
+}
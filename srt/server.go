@@ -0,0 +1,365 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// A Handler serves an accepted SRT connection.
+//
+// ServeSRT should read from or write to c and return when the session is
+// over. The server closes c after ServeSRT returns.
+type Handler interface {
+	ServeSRT(c *SRTConn, sid StreamID)
+}
+
+// A HandshakeHandler is a Handler that also decides, during the
+// handshake, whether a caller is accepted at all. Rejecting a caller there
+// is cheaper than closing the connection after it was accepted.
+type HandshakeHandler interface {
+	Handler
+
+	// AcceptHandshake returns 0 to accept the caller with the given
+	// stream ID, or the reason to reject it. It is called from the
+	// listen callback and should return quickly.
+	AcceptHandshake(sid StreamID, peer net.Addr) RejectReason
+}
+
+// The HandlerFunc type is an adapter to allow the use of ordinary
+// functions as SRT handlers.
+type HandlerFunc func(c *SRTConn, sid StreamID)
+
+// ServeSRT calls f(c, sid).
+func (f HandlerFunc) ServeSRT(c *SRTConn, sid StreamID) {
+	f(c, sid)
+}
+
+// ErrServerClosed is returned by the Server's Serve and ListenAndServe
+// methods after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("srt: Server closed")
+
+var errNilHandler = errors.New("srt: Server.Handler is nil")
+
+// A Server accepts SRT connections and serves each of them with a
+// Handler on its own goroutine.
+type Server struct {
+	// Addr is the address to listen on, as in "host:port", for
+	// ListenAndServe.
+	Addr string
+
+	// Handler serves the accepted connections.
+	Handler Handler
+
+	// ListenConfig configures the listener created by ListenAndServe.
+	// If nil, the zero ListenConfig is used.
+	ListenConfig *ListenConfig
+
+	// ErrorLog specifies an optional logger for errors accepting
+	// connections and panics in handlers. If nil, logging goes to
+	// the log package's standard logger.
+	ErrorLog *log.Logger
+
+	// MaxConns limits the number of connections served at once.
+	// Callers beyond the limit are rejected during the handshake.
+	// Zero means no limit.
+	MaxConns int
+
+	inShutdown int32 // accessed atomically
+
+	mu         sync.Mutex
+	listeners  map[net.Listener]struct{}
+	conns      map[*SRTConn]struct{}
+	onShutdown []func()
+}
+
+// ListenAndServe listens on srv.Addr and then calls Serve to handle
+// incoming connections.
+func (srv *Server) ListenAndServe() error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	if srv.Addr == "" {
+		return &OpError{Op: "listen", Net: "srt", Source: nil, Addr: nil, Err: errMissingAddress}
+	}
+	lc := srv.ListenConfig
+	if lc == nil {
+		lc = &ListenConfig{}
+	}
+	ln, err := lc.Listen(context.WithValue(context.Background(), serverContextKey{}, srv), "srt", srv.Addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(ln)
+}
+
+// Serve accepts incoming connections on l, creating a new goroutine for
+// each. The goroutine reads the stream ID and calls srv.Handler.
+//
+// For an *SRTListener, the server checks callers during the handshake
+// from the moment Serve is called; callers that connected before are only
+// checked by the listener's own callback. ListenAndServe has no such gap.
+//
+// Serve always closes l and returns a non-nil error. After Shutdown or
+// Close, the returned error is ErrServerClosed.
+func (srv *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	if srv.Handler == nil {
+		return errNilHandler
+	}
+	if !srv.trackListener(l, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(l, false)
+
+	if sl, ok := l.(*SRTListener); ok && sl.ok() {
+		sl.fd.listenServer.Store(srv)
+	}
+
+	var tempDelay time.Duration // how long to sleep on accept failure
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := 1 * time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				srv.logf("srt: Accept error: %v; retrying in %v", err, tempDelay)
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		sc, ok := c.(*SRTConn)
+		if !ok {
+			srv.logf("srt: Serve accepted a %T; only *SRTConn is supported", c)
+			c.Close()
+			continue
+		}
+		if !srv.trackConn(sc, true) {
+			sc.Close()
+			continue
+		}
+		go srv.serveConn(sc)
+	}
+}
+
+// serverContextKey is the type of contextKeys used for the Server that
+// will serve a listener.
+type serverContextKey struct{}
+
+// serverListenCallback returns the listen callback of the listener fd. It
+// lets the Server serving the listener, if any, check each caller before
+// next.
+func (fd *netFD) serverListenCallback(next srtapi.SrtListenCallbackFunc) srtapi.SrtListenCallbackFunc {
+	return func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		if srv, _ := fd.listenServer.Load().(*Server); srv != nil {
+			return srv.listenCallback(next)(ns, hsversion, peeraddr, streamid)
+		}
+		if next != nil {
+			return next(ns, hsversion, peeraddr, streamid)
+		}
+		return 0
+	}
+}
+
+// listenCallback rejects callers while the server is full or shutting
+// down, or when a HandshakeHandler refuses them, and otherwise defers to
+// next, the callback carried by the listener's context.
+func (srv *Server) listenCallback(next srtapi.SrtListenCallbackFunc) srtapi.SrtListenCallbackFunc {
+	return func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		if srv.shuttingDown() {
+			return Reject(ns, RejectDown)
+		}
+		if srv.full() {
+			return Reject(ns, RejectOverload)
+		}
+		if h, ok := srv.Handler.(HandshakeHandler); ok {
+			sid, err := ParseStreamID(streamid)
+			if err != nil {
+				return Reject(ns, RejectBadRequest)
+			}
+			if reason := h.AcceptHandshake(sid, sockaddrToSRT(peeraddr)); reason != 0 {
+				return Reject(ns, reason)
+			}
+		}
+		if next != nil {
+			return next(ns, hsversion, peeraddr, streamid)
+		}
+		return 0
+	}
+}
+
+func (srv *Server) serveConn(c *SRTConn) {
+	defer func() {
+		if err := recover(); err != nil {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			srv.logf("srt: panic serving %v: %v\n%s", c.RemoteAddr(), err, buf)
+		}
+		c.Close()
+		srv.trackConn(c, false)
+	}()
+	s, err := c.StreamID()
+	if err != nil {
+		srv.logf("srt: reading stream id of %v: %v", c.RemoteAddr(), err)
+		return
+	}
+	sid, err := ParseStreamID(s)
+	if err != nil {
+		srv.logf("srt: stream id %q of %v: %v", s, c.RemoteAddr(), err)
+		return
+	}
+	srv.Handler.ServeSRT(c, sid)
+}
+
+// RegisterOnShutdown registers a function to call on Shutdown, for
+// instance to tell long running handlers to finish.
+func (srv *Server) RegisterOnShutdown(f func()) {
+	srv.mu.Lock()
+	srv.onShutdown = append(srv.onShutdown, f)
+	srv.mu.Unlock()
+}
+
+// shutdownPollInterval is how often Shutdown checks for handlers that are
+// still running.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown gracefully shuts down the server: it closes all listeners,
+// calls the functions registered with RegisterOnShutdown and then waits
+// for all handlers to return.
+//
+// If ctx is done first, Shutdown returns the context's error; the
+// remaining connections stay open until Close is called.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+
+	srv.mu.Lock()
+	lnerr := srv.closeListenersLocked()
+	for _, f := range srv.onShutdown {
+		go f()
+	}
+	srv.mu.Unlock()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.numConns() == 0 {
+			return lnerr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all listeners and connections. For a
+// graceful shutdown, use Shutdown.
+func (srv *Server) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	err := srv.closeListenersLocked()
+	for c := range srv.conns {
+		c.Close()
+	}
+	return err
+}
+
+func (srv *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+func (srv *Server) closeListenersLocked() error {
+	var err error
+	for l := range srv.listeners {
+		if cerr := l.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (srv *Server) trackListener(l net.Listener, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.shuttingDown() {
+			return false
+		}
+		if srv.listeners == nil {
+			srv.listeners = make(map[net.Listener]struct{})
+		}
+		srv.listeners[l] = struct{}{}
+	} else {
+		delete(srv.listeners, l)
+	}
+	return true
+}
+
+// trackConn adds or removes c. Adding fails when the server is shutting
+// down or full.
+func (srv *Server) trackConn(c *SRTConn, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.shuttingDown() || srv.MaxConns > 0 && len(srv.conns) >= srv.MaxConns {
+			return false
+		}
+		if srv.conns == nil {
+			srv.conns = make(map[*SRTConn]struct{})
+		}
+		srv.conns[c] = struct{}{}
+	} else {
+		delete(srv.conns, c)
+	}
+	return true
+}
+
+func (srv *Server) numConns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.conns)
+}
+
+func (srv *Server) full() bool {
+	return srv.MaxConns > 0 && srv.numConns() >= srv.MaxConns
+}
+
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// ListenAndServe listens on the SRT network address addr and then calls
+// Serve with handler to handle incoming connections.
+func ListenAndServe(addr string, handler Handler) error {
+	server := &Server{Addr: addr, Handler: handler}
+	return server.ListenAndServe()
+}
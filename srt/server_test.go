@@ -11,7 +11,9 @@ import (
 	"context"
 	"net"
 	"runtime"
+	"syscall"
 	"testing"
+	"time"
 )
 
 var srtServerTests = []struct {
@@ -132,3 +134,105 @@ func TestSRTServer(t *testing.T) {
 		}
 	}
 }
+
+func TestServerServeAndShutdown(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sids := make(chan StreamID, 1)
+	release := make(chan struct{})
+	srv := &Server{Handler: HandlerFunc(func(c *SRTConn, sid StreamID) {
+		sids <- sid
+		<-release
+	})}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	ctx := WithOptions(context.Background(), Options("streamid", "#!::r=live,m=publish"))
+	var d Dialer
+	c, err := d.DialContext(ctx, ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	sid := <-sids
+	if sid.Resource != "live" || sid.Mode != ModePublish {
+		t.Errorf("handler got stream id %+v", sid)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	err = srv.Shutdown(shutdownCtx)
+	cancel()
+	if err != context.DeadlineExceeded {
+		t.Errorf("Shutdown with a running handler = %v; want %v", err, context.DeadlineExceeded)
+	}
+	close(release)
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve = %v; want %v", err, ErrServerClosed)
+	}
+	if err := srv.Serve(ln); err != ErrServerClosed {
+		t.Errorf("Serve after Shutdown = %v; want %v", err, ErrServerClosed)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	ln, err := newLocalListener("srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	srv := &Server{MaxConns: 1, Handler: HandlerFunc(func(c *SRTConn, sid StreamID) {
+		<-release
+	})}
+	defer srv.Close()
+	defer close(release)
+	go srv.Serve(ln)
+
+	c1, err := Dial(ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	for srv.numConns() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c2, err := DialTimeout(ln.Addr().Network(), ln.Addr().String(), someTimeout)
+	if err == nil {
+		c2.Close()
+		t.Fatal("second connection was accepted over MaxConns")
+	}
+}
+
+func TestServerListenCallback(t *testing.T) {
+	defer func(f func(int, int) error) { setrejectreasonFunc = f }(setrejectreasonFunc)
+	var rejected RejectReason
+	setrejectreasonFunc = func(fd int, reason int) error {
+		rejected = RejectReason(reason)
+		return nil
+	}
+
+	var fd netFD
+	var nextCalled bool
+	cb := fd.serverListenCallback(func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		nextCalled = true
+		return 0
+	})
+	peer := &syscall.SockaddrInet4{Port: 5000, Addr: [4]byte{127, 0, 0, 1}}
+	if r := cb(1, 5, peer, ""); r != 0 || !nextCalled {
+		t.Errorf("no server: got %d, next called %v", r, nextCalled)
+	}
+
+	// A Server attached to the listener later checks the callers
+	// through the same callback.
+	srv := &Server{Handler: HandlerFunc(func(*SRTConn, StreamID) {})}
+	srv.Close()
+	fd.listenServer.Store(srv)
+	nextCalled = false
+	if r := cb(1, 5, peer, ""); r != -1 || nextCalled || rejected != RejectDown {
+		t.Errorf("closed server: got %d, next called %v, reason %v", r, nextCalled, rejected)
+	}
+}
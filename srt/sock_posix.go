@@ -17,6 +17,7 @@ import (
 	"syscall"
 
 	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
 	"github.com/openfresh/gosrt/srtapi"
 )
 
@@ -59,26 +60,99 @@ func socket(ctx context.Context, net string, family, sotype, proto int, ipv6only
 		return nil, err
 	}
 
+	if ctrlFn := controlValue(ctx); ctrlFn != nil {
+		var address string
+		if raddr != nil {
+			address = raddr.String()
+		} else if laddr != nil {
+			address = laddr.String()
+		}
+		if err := ctrlFn(fd.ctrlNetwork(), address, newRawConn(fd)); err != nil {
+			fd.Close()
+			return nil, err
+		}
+	}
+
 	if laddr != nil && raddr == nil {
-		if err := fd.listen(laddr, listenerBacklog); err != nil {
+		// The callback is registered once, before listening, so that
+		// no caller skips it. A Server serving the listener later
+		// takes part through fd.listenServer.
+		if srv, ok := ctx.Value(serverContextKey{}).(*Server); ok {
+			fd.listenServer.Store(srv)
+		}
+		callback := fd.serverListenCallback(listenCallbackValue(ctx))
+		if ac := accessControlValue(ctx); ac != nil {
+			// Callers the access control rejects reach neither
+			// the Server nor the listen callback.
+			callback = ac.listenCallback(callback)
+		}
+		callback = traceListenCallback(srttrace.ContextServerTrace(ctx), callback)
+		if err := fd.listenCallback(callback); err != nil {
 			fd.Close()
 			return nil, err
 		}
-		if callback := listenCallbackValue(ctx); callback != nil {
-			if err := fd.listenCallback(callback); err != nil {
-				fd.Close()
-				return nil, err
-			}
+		if err := fd.listen(laddr, listenBacklogValue(ctx)); err != nil {
+			fd.Close()
+			return nil, err
 		}
 		return fd, nil
 	}
+	trace := srttrace.ContextClientTrace(ctx)
 	if err := fd.dial(ctx, laddr, raddr); err != nil {
+		if trace != nil && trace.Rejected != nil {
+			if reason := getrejectreasonFunc(fd.pfd.Sysfd); reason != 0 {
+				trace.Rejected(raddr.String(), reason)
+			}
+		}
+		fd.Close()
+		return nil, err
+	}
+	if trace != nil {
+		if trace.HandshakeDone != nil {
+			trace.HandshakeDone(handshakeInfo(fd))
+		}
+		fd.closeTrace = trace.Closed
+	}
+	return fd, nil
+}
+
+// bindSocket returns a network file descriptor bound to laddr, which
+// neither listens nor connects. It keeps the UDP port of laddr open for
+// other SRT sockets binding the same address.
+func bindSocket(ctx context.Context, net string, family, sotype, proto int, ipv6only bool, laddr sockaddr) (fd *netFD, err error) {
+	s, err := srtSocket(family, sotype, proto)
+	if err != nil {
+		return nil, err
+	}
+	if err = setDefaultSockopts(s, family, sotype, ipv6only); err != nil {
+		poll.CloseFunc(s)
+		return nil, err
+	}
+	configure(ctx, s, bindPre)
+	if fd, err = newFD(s, family, sotype, net); err != nil {
+		poll.CloseFunc(s)
+		return nil, err
+	}
+	if err := fd.dial(ctx, laddr, nil); err != nil {
 		fd.Close()
 		return nil, err
 	}
 	return fd, nil
 }
 
+// ctrlNetwork returns the network name passed to Control functions, with
+// the address family made explicit.
+func (fd *netFD) ctrlNetwork() string {
+	switch fd.net {
+	case "srt4", "srt6":
+		return fd.net
+	}
+	if fd.family == syscall.AF_INET {
+		return "srt4"
+	}
+	return "srt6"
+}
+
 func (fd *netFD) addrFunc() func(syscall.Sockaddr) net.Addr {
 	switch fd.family {
 	case syscall.AF_INET, syscall.AF_INET6:
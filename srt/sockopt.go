@@ -6,6 +6,7 @@ package srt
 import (
 	"context"
 	"strconv"
+	"syscall"
 
 	"github.com/openfresh/gosrt/srtapi"
 )
@@ -15,6 +16,7 @@ const (
 	typeInt
 	typeInt64
 	typeBool
+	typeLinger
 )
 
 const (
@@ -44,6 +46,8 @@ func (o *socketOption) apply(s int, v string) error {
 		return srtapi.SetsockoptInt64(s, 0, o.sym, ov)
 	case bool:
 		return srtapi.SetsockoptBool(s, 0, o.sym, ov)
+	case *syscall.Linger:
+		return srtapi.SetsockoptLinger(s, 0, o.sym, ov)
 	}
 	return nil
 }
@@ -58,6 +62,16 @@ func (o *socketOption) extract(v string) (ov interface{}, err error) {
 		ov, err = strconv.ParseInt(v, 10, 64)
 	case typeBool:
 		ov, err = strconv.ParseBool(v)
+	case typeLinger:
+		// The value is the linger time in seconds; 0 turns linger off.
+		var sec int
+		if sec, err = strconv.Atoi(v); err == nil {
+			l := &syscall.Linger{Linger: int32(sec)}
+			if sec > 0 {
+				l.Onoff = 1
+			}
+			ov = l
+		}
 	}
 	return
 }
@@ -95,6 +109,10 @@ var srtOptions = []socketOption{
 	{"enforcedencryption", 0, srtapi.OptionEnforcedencryption, bindPre, typeBool},
 	{"peeridletimeo", 0, srtapi.OptionPeeridletimeo, bindPre, typeInt},
 	{"packetfilter", 0, srtapi.OptionPacketfilter, bindPre, typeString},
+	{"linger", 0, srtapi.OptionLinger, bindPre, typeLinger},
+	{"reuseaddr", 0, srtapi.OptionReuseaddr, bindPre, typeBool},
+	{"ipv6only", 0, srtapi.OptionIpv60only, bindPre, typeInt},
+	{"bindtodevice", 0, srtapi.OptionBindtodevice, bindPre, typeString},
 }
 
 type option struct {
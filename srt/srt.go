@@ -147,6 +147,9 @@ var (
 	// For connection setup operations.
 	errNoSuitableAddress = errors.New("no suitable address found")
 
+	// For connection setup operations with BindToDevice.
+	errBindToDeviceWithoutInterface = errors.New("BindToDevice set without Interface")
+
 	// For connection setup and write operations.
 	errMissingAddress = errors.New("missing address")
 
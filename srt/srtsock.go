@@ -12,6 +12,7 @@ import (
 	"context"
 	"io"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/openfresh/gosrt/srtapi"
@@ -90,6 +91,8 @@ func ResolveSRTAddr(network, address string) (*SRTAddr, error) {
 // connections.
 type SRTConn struct {
 	conn
+
+	inputbw inputEstimator
 }
 
 // ReadFrom implements the io.ReaderFrom ReadFrom method.
@@ -104,8 +107,50 @@ func (c *SRTConn) ReadFrom(r io.Reader) (int64, error) {
 	return n, err
 }
 
+// Close closes the connection.
+func (c *SRTConn) Close() error {
+	if !c.ok() {
+		return srtapi.EINVPARAM
+	}
+	c.inputbw.close()
+	return c.conn.Close()
+}
+
+// CloseWrite waits until the data queued for sending has been
+// acknowledged by the peer and then closes the connection. SRT has no
+// half-close, so the connection can no longer be read either.
+func (c *SRTConn) CloseWrite() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext is like CloseWrite but stops waiting when ctx is done.
+// The connection is closed in any case; the returned error tells whether
+// queued data may have been lost.
+func (c *SRTConn) CloseContext(ctx context.Context) error {
+	if !c.ok() {
+		return srtapi.EINVPARAM
+	}
+	c.inputbw.close()
+	err := c.fd.drain(ctx)
+	if cerr := c.fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		err = &OpError{Op: "close", Net: c.fd.net, Source: c.fd.laddr, Addr: c.fd.raddr, Err: err}
+	}
+	return err
+}
+
+// SyscallConn returns a raw SRT connection.
+func (c *SRTConn) SyscallConn() (RawConn, error) {
+	if !c.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	return newRawConn(c.fd), nil
+}
+
 func newSRTConn(fd *netFD) *SRTConn {
-	c := &SRTConn{conn{fd}}
+	c := &SRTConn{conn: conn{fd}}
 	return c
 }
 
@@ -139,6 +184,13 @@ func DialSRT(network string, laddr, raddr *SRTAddr) (*SRTConn, error) {
 type SRTListener struct {
 	fd  *netFD
 	ctx context.Context
+
+	// The deadline set with SetDeadline, which AcceptContext overrides
+	// while it interrupts a pending accept.
+	mu         sync.Mutex
+	deadline   time.Time
+	interrupts int
+	restored   chan struct{} // closed when interrupts drops to zero
 }
 
 // AcceptSRT accepts the next incoming call and returns the new
@@ -147,7 +199,7 @@ func (l *SRTListener) AcceptSRT() (*SRTConn, error) {
 	if !l.ok() {
 		return nil, srtapi.EINVPARAM
 	}
-	c, err := l.accept()
+	c, err := l.accept(context.Background())
 	if err != nil {
 		return nil, &OpError{Op: "accept", Net: l.fd.net, Source: nil, Addr: l.fd.laddr, Err: err}
 	}
@@ -160,7 +212,39 @@ func (l *SRTListener) Accept() (net.Conn, error) {
 	if !l.ok() {
 		return nil, srtapi.EINVPARAM
 	}
-	c, err := l.accept()
+	c, err := l.accept(context.Background())
+	if err != nil {
+		return nil, &OpError{Op: "accept", Net: l.fd.net, Source: nil, Addr: l.fd.laddr, Err: err}
+	}
+	return c, nil
+}
+
+// AcceptSRTContext is like AcceptSRT but returns early with an error if
+// ctx is done before a call arrives. The listener stays open.
+func (l *SRTListener) AcceptSRTContext(ctx context.Context) (*SRTConn, error) {
+	if !l.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	if ctx == nil {
+		panic("nil context")
+	}
+	c, err := l.accept(ctx)
+	if err != nil {
+		return nil, &OpError{Op: "accept", Net: l.fd.net, Source: nil, Addr: l.fd.laddr, Err: err}
+	}
+	return c, nil
+}
+
+// AcceptContext is like Accept but returns early with an error if ctx is
+// done before a call arrives. The listener stays open.
+func (l *SRTListener) AcceptContext(ctx context.Context) (net.Conn, error) {
+	if !l.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	if ctx == nil {
+		panic("nil context")
+	}
+	c, err := l.accept(ctx)
 	if err != nil {
 		return nil, &OpError{Op: "accept", Net: l.fd.net, Source: nil, Addr: l.fd.laddr, Err: err}
 	}
@@ -179,6 +263,14 @@ func (l *SRTListener) Close() error {
 	return nil
 }
 
+// SyscallConn returns a raw SRT listener.
+func (l *SRTListener) SyscallConn() (RawConn, error) {
+	if !l.ok() {
+		return nil, srtapi.EINVPARAM
+	}
+	return newRawConn(l.fd), nil
+}
+
 // Addr returns the listener's network address, a *SRTAddr.
 // The Addr returned is shared by all invocations of Addr, so
 // do not modify it.
@@ -190,6 +282,13 @@ func (l *SRTListener) SetDeadline(t time.Time) error {
 	if !l.ok() {
 		return srtapi.EINVPARAM
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.deadline = t
+	if l.interrupts > 0 {
+		// Applied when the interrupted accept returns.
+		return nil
+	}
 	if err := l.fd.pfd.SetDeadline(t); err != nil {
 		return &OpError{Op: "set", Net: l.fd.net, Source: nil, Addr: l.fd.laddr, Err: err}
 	}
@@ -13,6 +13,10 @@ import (
 	"io"
 	"net"
 	"syscall"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
 )
 
 func sockaddrToSRT(sa syscall.Sockaddr) net.Addr {
@@ -25,6 +29,12 @@ func sockaddrToSRT(sa syscall.Sockaddr) net.Addr {
 	return nil
 }
 
+// PeerAddr returns the address of a caller as given to a listen
+// callback.
+func PeerAddr(sa syscall.Sockaddr) net.Addr {
+	return sockaddrToSRT(sa)
+}
+
 func (a *SRTAddr) family() int {
 	if a == nil || len(a.IP) <= net.IPv4len {
 		return syscall.AF_INET
@@ -70,15 +80,102 @@ func doDialSRT(ctx context.Context, network string, laddr, raddr *SRTAddr) (*SRT
 
 func (ln *SRTListener) ok() bool { return ln != nil && ln.fd != nil }
 
-func (ln *SRTListener) accept() (*SRTConn, error) {
-	fd, err := ln.fd.accept()
+func (ln *SRTListener) accept(ctx context.Context) (*SRTConn, error) {
+	fd, err := ln.acceptFD(ctx)
 	if err != nil {
 		return nil, err
 	}
 	configure(ln.ctx, fd.pfd.Sysfd, bindPost)
+	if trace := srttrace.ContextServerTrace(ln.ctx); trace != nil {
+		if trace.Accepted != nil {
+			trace.Accepted(handshakeInfo(fd))
+		}
+		fd.closeTrace = trace.Closed
+	}
+	if ac := accessControlValue(ln.ctx); ac != nil {
+		ac.track(fd)
+	}
 	return newSRTConn(fd), nil
 }
 
+// acceptFD accepts the next call, giving up when ctx is done.
+func (ln *SRTListener) acceptFD(ctx context.Context) (*netFD, error) {
+	// Start the "interrupter" goroutine, if this context might be
+	// canceled. It interrupts the accept by altering the listener's
+	// read deadline, which wakes up waitRead, and the deadline set with
+	// SetDeadline is restored once the accept returned.
+	if ctx.Done() != nil {
+		done := make(chan struct{})
+		interruptRes := make(chan bool)
+		defer func() {
+			close(done)
+			if <-interruptRes {
+				ln.endInterrupt()
+			}
+		}()
+		go func() {
+			select {
+			case <-ctx.Done():
+				ln.beginInterrupt()
+				interruptRes <- true
+			case <-done:
+				interruptRes <- false
+			}
+		}()
+	}
+
+	for {
+		fd, err := ln.fd.accept()
+		if err == nil {
+			return fd, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, mapErr(ctxErr)
+		}
+		if err != poll.ErrTimeout || !ln.waitInterrupt() {
+			return nil, err
+		}
+	}
+}
+
+// beginInterrupt makes pending accepts return.
+func (ln *SRTListener) beginInterrupt() {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	if ln.interrupts == 0 {
+		ln.restored = make(chan struct{})
+	}
+	ln.interrupts++
+	ln.fd.pfd.SetReadDeadline(aLongTimeAgo)
+}
+
+// endInterrupt restores the deadline once no accept is being interrupted.
+func (ln *SRTListener) endInterrupt() {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	if ln.interrupts--; ln.interrupts == 0 {
+		ln.fd.pfd.SetDeadline(ln.deadline)
+		close(ln.restored)
+	}
+}
+
+// waitInterrupt reports whether a timeout returned by an accept was
+// caused by the interruption of another accept rather than by the
+// deadline, in which case it waits for the deadline to be restored.
+func (ln *SRTListener) waitInterrupt() bool {
+	ln.mu.Lock()
+	interrupted, restored := ln.interrupts > 0, ln.restored
+	expired := !ln.deadline.IsZero() && !time.Now().Before(ln.deadline)
+	ln.mu.Unlock()
+	if expired {
+		return false
+	}
+	if interrupted {
+		<-restored
+	}
+	return true
+}
+
 func (ln *SRTListener) close() error {
 	return ln.fd.Close()
 }
@@ -88,5 +185,10 @@ func listenSRT(ctx context.Context, network string, laddr *SRTAddr) (*SRTListene
 	if err != nil {
 		return nil, err
 	}
-	return &SRTListener{fd, ctx}, nil
+	return &SRTListener{fd: fd, ctx: ctx}, nil
+}
+
+func bindSRT(ctx context.Context, network string, laddr *SRTAddr) (*netFD, error) {
+	family, ipv6only := favoriteAddrFamily(network, laddr, nil, "listen")
+	return bindSocket(ctx, network, family, syscall.SOCK_DGRAM, 0, ipv6only, laddr)
 }
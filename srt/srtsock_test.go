@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/openfresh/gosrt/internal/testenv"
+	"github.com/openfresh/gosrt/srtapi"
 )
 
 func BenchmarkSRT4OneShot(b *testing.B) {
@@ -595,3 +596,106 @@ func TestSRTSelfConnect(t *testing.T) {
 		}
 	}
 }
+
+func TestSRTCloseWriteDrains(t *testing.T) {
+	const count = 200
+	ctx := WithOptions(context.Background(), Options("transtype", "1", "linger", "3"))
+	ln, err := newLocalListenerContext(ctx, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan int, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			received <- 0
+			return
+		}
+		defer c.Close()
+		c.SetReadDeadline(time.Now().Add(someTimeout))
+		n := 0
+		b := make([]byte, 1316)
+		for {
+			m, err := c.Read(b)
+			n += m
+			if err != nil {
+				break
+			}
+		}
+		received <- n
+	}()
+
+	var d Dialer
+	c, err := d.DialContext(ctx, "srt", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1316)
+	for i := 0; i < count; i++ {
+		if _, err := c.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.(*SRTConn).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if n := <-received; n != count*len(b) {
+		t.Errorf("received %d bytes; want %d", n, count*len(b))
+	}
+}
+
+func TestSRTDrain(t *testing.T) {
+	origSockstate := getsockstateFunc
+	origGetsockoptInt := getsockoptIntFunc
+	defer func() {
+		getsockstateFunc = origSockstate
+		getsockoptIntFunc = origGetsockoptInt
+	}()
+	var (
+		mu      sync.Mutex
+		pending = 3
+		state   = srtapi.StatusConnected
+	)
+	getsockoptIntFunc = func(fd, level, opt int) (int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if opt != srtapi.OptionSnddata {
+			t.Errorf("unexpected option %d", opt)
+		}
+		n := pending
+		if pending > 0 {
+			pending--
+		}
+		return n, nil
+	}
+	getsockstateFunc = func(fd int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return state
+	}
+	fd := &netFD{}
+
+	if err := fd.drain(context.Background()); err != nil {
+		t.Errorf("drain: %v", err)
+	}
+
+	mu.Lock()
+	pending = 1 << 30
+	mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := fd.drain(ctx); err == nil {
+		t.Error("drain returned before the context expired")
+	} else if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+		t.Errorf("got %v; want timeout", err)
+	}
+
+	mu.Lock()
+	state = srtapi.StatusBroken
+	mu.Unlock()
+	if err := fd.drain(context.Background()); err != srtapi.ECONNLOST {
+		t.Errorf("got %v; want %v", err, srtapi.ECONNLOST)
+	}
+}
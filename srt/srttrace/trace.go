@@ -0,0 +1,143 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// Package srttrace provides mechanisms to trace the events within SRT
+// connections: resolving and dialing on the caller side, listen callback
+// decisions and accepted connections on the listener side, the outcome of
+// the handshake and the closing of connections.
+//
+// A trace is attached to the context passed to srt.Dialer.DialContext or
+// srt.ListenContext.
+package srttrace
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/nettrace"
+)
+
+// unique type to prevent assignment.
+type clientEventContextKey struct{}
+type serverEventContextKey struct{}
+
+// HandshakeInfo describes a connection after a successful handshake.
+type HandshakeInfo struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	StreamID   string
+
+	// PeerVersion is the SRT version of the peer, as in "1.4.2".
+	PeerVersion string
+
+	// Latency and PeerLatency are the negotiated receiver latencies of
+	// this side and of the peer.
+	Latency     time.Duration
+	PeerLatency time.Duration
+
+	// Encrypted reports whether the connection is encrypted, with a
+	// key of KeyLength bytes.
+	Encrypted bool
+	KeyLength int
+}
+
+// CloseInfo describes a connection being closed.
+type CloseInfo struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+
+	// Broken reports whether the connection was already broken, because
+	// the peer closed it or stopped responding, when it was closed.
+	Broken bool
+
+	// Err is the error returned by Close.
+	Err error
+}
+
+// ClientTrace is a set of hooks to run at various stages of dialing an
+// SRT connection. Any particular hook may be nil. Functions may be called
+// concurrently from different goroutines and some may be called after
+// the dial has completed or failed.
+type ClientTrace struct {
+	// ResolveStart is called before the address is resolved.
+	ResolveStart func(network, address string)
+
+	// ResolveDone is called after the address is resolved, with the
+	// addresses that will be tried in order.
+	ResolveDone func(addrs []net.Addr, err error)
+
+	// ConnectStart is called when a connection to a resolved address
+	// starts.
+	ConnectStart func(network, addr string)
+
+	// ConnectDone is called when a connection to a resolved address
+	// completes or fails.
+	ConnectDone func(network, addr string, err error)
+
+	// HandshakeDone is called after a successful handshake.
+	HandshakeDone func(info HandshakeInfo)
+
+	// Rejected is called when the listener at addr rejected the
+	// handshake, with the reason it gave. Reasons below 1000 are set
+	// by libsrt itself, others by the listener; see srt.RejectReason.
+	Rejected func(addr string, reason int)
+
+	// Closed is called when a dialed connection is closed.
+	Closed func(info CloseInfo)
+}
+
+// ServerTrace is a set of hooks to run on a listener. Any particular hook
+// may be nil.
+type ServerTrace struct {
+	// ListenCallback is called with the decision taken on a caller
+	// during the handshake.
+	ListenCallback func(peer net.Addr, streamID string, accepted bool)
+
+	// Accepted is called when a connection is accepted.
+	Accepted func(info HandshakeInfo)
+
+	// Closed is called when an accepted connection is closed.
+	Closed func(info CloseInfo)
+}
+
+// WithClientTrace returns a new context based on the provided parent ctx.
+// SRT connections dialed with the returned context use the provided
+// trace hooks. A trace attached to ctx before is replaced.
+func WithClientTrace(ctx context.Context, trace *ClientTrace) context.Context {
+	if trace == nil {
+		panic("nil trace")
+	}
+	ctx = context.WithValue(ctx, clientEventContextKey{}, trace)
+	if trace.ConnectStart != nil || trace.ConnectDone != nil {
+		ctx = context.WithValue(ctx, nettrace.TraceKey{}, &nettrace.Trace{
+			ConnectStart: trace.ConnectStart,
+			ConnectDone:  trace.ConnectDone,
+		})
+	}
+	return ctx
+}
+
+// ContextClientTrace returns the ClientTrace associated with the
+// provided context. If none, it returns nil.
+func ContextClientTrace(ctx context.Context) *ClientTrace {
+	trace, _ := ctx.Value(clientEventContextKey{}).(*ClientTrace)
+	return trace
+}
+
+// WithServerTrace returns a new context based on the provided parent ctx.
+// Listeners created with the returned context use the provided trace
+// hooks.
+func WithServerTrace(ctx context.Context, trace *ServerTrace) context.Context {
+	if trace == nil {
+		panic("nil trace")
+	}
+	return context.WithValue(ctx, serverEventContextKey{}, trace)
+}
+
+// ContextServerTrace returns the ServerTrace associated with the
+// provided context. If none, it returns nil.
+func ContextServerTrace(ctx context.Context) *ServerTrace {
+	trace, _ := ctx.Value(serverEventContextKey{}).(*ServerTrace)
+	return trace
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srttrace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openfresh/gosrt/internal/nettrace"
+)
+
+func TestWithClientTrace(t *testing.T) {
+	var started string
+	trace := &ClientTrace{ConnectStart: func(network, addr string) { started = addr }}
+	ctx := WithClientTrace(context.Background(), trace)
+	if got := ContextClientTrace(ctx); got != trace {
+		t.Errorf("got %p; want %p", got, trace)
+	}
+	nt, _ := ctx.Value(nettrace.TraceKey{}).(*nettrace.Trace)
+	if nt == nil || nt.ConnectStart == nil {
+		t.Fatal("connect hooks not installed")
+	}
+	nt.ConnectStart("srt", "127.0.0.1:5000")
+	if started != "127.0.0.1:5000" {
+		t.Errorf("ConnectStart got %q", started)
+	}
+	if ContextServerTrace(ctx) != nil {
+		t.Error("client trace found as server trace")
+	}
+}
+
+func TestWithServerTrace(t *testing.T) {
+	if ContextServerTrace(context.Background()) != nil {
+		t.Error("trace found in empty context")
+	}
+	trace := &ServerTrace{}
+	if got := ContextServerTrace(WithServerTrace(context.Background(), trace)); got != trace {
+		t.Errorf("got %p; want %p", got, trace)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// State is the state of an SRT socket.
+type State int
+
+// Socket states.
+const (
+	StateInit       State = srtapi.StatusInit
+	StateOpened     State = srtapi.StatusOpened
+	StateListening  State = srtapi.StatusListening
+	StateConnecting State = srtapi.StatusConnecting
+	StateConnected  State = srtapi.StatusConnected
+	StateBroken     State = srtapi.StatusBroken
+	StateClosing    State = srtapi.StatusClosing
+	StateClosed     State = srtapi.StatusClosed
+	StateNonexist   State = srtapi.StatusNonexist
+)
+
+var stateNames = map[State]string{
+	StateInit:       "init",
+	StateOpened:     "opened",
+	StateListening:  "listening",
+	StateConnecting: "connecting",
+	StateConnected:  "connected",
+	StateBroken:     "broken",
+	StateClosing:    "closing",
+	StateClosed:     "closed",
+	StateNonexist:   "nonexist",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// State returns the current state of the connection.
+func (c *conn) State() State {
+	if !c.ok() {
+		return StateNonexist
+	}
+	return State(getsockstateFunc(c.fd.pfd.Sysfd))
+}
+
+// OnStateChange registers f to be called with the new state whenever
+// the state of the connection changes, for instance to StateBroken as
+// soon as the peer is lost, even while nobody is reading. Close reports
+// StateClosed.
+//
+// Calls to f are made on a separate goroutine, one at a time and in
+// order, so f may block without stalling the connection. A later call to
+// OnStateChange replaces f; a nil f stops the notifications.
+func (c *conn) OnStateChange(f func(State)) error {
+	if !c.ok() {
+		return srtapi.EINVPARAM
+	}
+	fd := c.fd
+	s := fd.pfd.Sysfd
+	fd.states.set(f, State(getsockstateFunc(s)))
+	var notify func()
+	if f != nil {
+		notify = func() {
+			fd.states.update(State(getsockstateFunc(s)))
+		}
+	}
+	if err := fd.pfd.SetNotify(notify); err != nil {
+		fd.states.set(nil, 0)
+		return &OpError{Op: "set", Net: fd.net, Source: nil, Addr: fd.laddr, Err: err}
+	}
+	return nil
+}
+
+// A stateNotifier delivers state changes to a callback in order.
+type stateNotifier struct {
+	mu      sync.Mutex
+	f       func(State)
+	last    State
+	pending []State
+	running bool
+}
+
+func (n *stateNotifier) set(f func(State), current State) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.f = f
+	n.last = current
+	n.pending = nil
+}
+
+// update records s and, if it differs from the last state seen, queues
+// it for delivery. It never blocks, as it is called from the poller.
+func (n *stateNotifier) update(s State) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.f == nil || s == n.last {
+		return
+	}
+	n.last = s
+	n.pending = append(n.pending, s)
+	if !n.running {
+		n.running = true
+		go n.run()
+	}
+}
+
+func (n *stateNotifier) run() {
+	for {
+		n.mu.Lock()
+		if len(n.pending) == 0 || n.f == nil {
+			n.pending = nil
+			n.running = false
+			n.mu.Unlock()
+			return
+		}
+		s, f := n.pending[0], n.f
+		n.pending = n.pending[1:]
+		n.mu.Unlock()
+		f(s)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateNotifierOrder(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []State
+	)
+	done := make(chan struct{})
+	var n stateNotifier
+	n.set(func(s State) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, s)
+		mu.Unlock()
+		if s == StateClosed {
+			close(done)
+		}
+	}, StateConnecting)
+	for _, s := range []State{StateConnecting, StateConnected, StateConnected, StateBroken, StateClosed} {
+		n.update(s)
+	}
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []State{StateConnected, StateBroken, StateClosed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestStateChangeOnPeerClose(t *testing.T) {
+	changes := make(chan State, 10)
+	withSRTConnPair(t, func(c *SRTConn) error {
+		if s := c.State(); s != StateConnected {
+			t.Errorf("got state %v; want %v", s, StateConnected)
+		}
+		if err := c.OnStateChange(func(s State) { changes <- s }); err != nil {
+			return err
+		}
+		// Wait for the peer to go away without reading.
+		timer := time.NewTimer(someTimeout)
+		defer timer.Stop()
+		for {
+			select {
+			case s := <-changes:
+				if s == StateBroken || s == StateClosed || s == StateNonexist {
+					return nil
+				}
+			case <-timer.C:
+				t.Error("no state change after the peer closed")
+				return nil
+			}
+		}
+	}, func(c *SRTConn) error {
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	})
+}
+
+func TestStateAfterClose(t *testing.T) {
+	withSRTConnPair(t, func(c *SRTConn) error {
+		closed := make(chan struct{})
+		c.OnStateChange(func(s State) {
+			if s == StateClosed {
+				close(closed)
+			}
+		})
+		c.Close()
+		select {
+		case <-closed:
+		case <-time.After(someTimeout):
+			t.Error("Close did not report StateClosed")
+		}
+		if s := c.State(); s == StateConnected {
+			t.Errorf("closed connection is %v", s)
+		}
+		return nil
+	}, func(c *SRTConn) error {
+		return nil
+	})
+}
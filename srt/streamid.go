@@ -0,0 +1,117 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// streamIDPrefix starts a stream ID that follows the SRT access control
+// convention.
+const streamIDPrefix = "#!::"
+
+// Stream ID modes.
+const (
+	ModeRequest       = "request"
+	ModePublish       = "publish"
+	ModeBidirectional = "bidirectional"
+)
+
+// StreamID is a stream ID parsed according to the SRT access control
+// convention, "#!::u=user,r=resource,m=publish". A stream ID that does not
+// follow the convention is taken as a resource name.
+type StreamID struct {
+	// Raw is the stream ID as sent by the caller.
+	Raw string
+
+	User     string // u
+	Resource string // r
+	Host     string // h
+	Session  string // s
+	Type     string // t: stream, file or auth
+	Mode     string // m: request, publish or bidirectional
+
+	// Fields holds every key and value, including the ones above and
+	// application specific keys.
+	Fields map[string]string
+
+	// Params holds the values of the wildcards of the ServeMux pattern
+	// that matched Resource.
+	Params map[string]string
+}
+
+var errMalformedStreamID = errors.New("malformed stream id")
+
+// ParseStreamID parses a stream ID.
+func ParseStreamID(s string) (StreamID, error) {
+	id := StreamID{Raw: s}
+	if !strings.HasPrefix(s, streamIDPrefix) {
+		id.Resource = s
+		return id, nil
+	}
+	id.Fields = make(map[string]string)
+	for _, item := range strings.Split(s[len(streamIDPrefix):], ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return StreamID{Raw: s}, errMalformedStreamID
+		}
+		id.Fields[kv[0]] = kv[1]
+		switch kv[0] {
+		case "u":
+			id.User = kv[1]
+		case "r":
+			id.Resource = kv[1]
+		case "h":
+			id.Host = kv[1]
+		case "s":
+			id.Session = kv[1]
+		case "t":
+			id.Type = kv[1]
+		case "m":
+			id.Mode = kv[1]
+		}
+	}
+	return id, nil
+}
+
+// RequestMode returns Mode, or ModeRequest when the caller did not give
+// one, which is the default of the convention.
+func (id StreamID) RequestMode() string {
+	if id.Mode == "" {
+		return ModeRequest
+	}
+	return id.Mode
+}
+
+// String formats id following the access control convention. Standard
+// keys come first, followed by the other fields in key order.
+func (id StreamID) String() string {
+	var items []string
+	add := func(k, v string) {
+		if v != "" {
+			items = append(items, k+"="+v)
+		}
+	}
+	add("u", id.User)
+	add("r", id.Resource)
+	add("h", id.Host)
+	add("s", id.Session)
+	add("t", id.Type)
+	add("m", id.Mode)
+	var keys []string
+	for k := range id.Fields {
+		switch k {
+		case "u", "r", "h", "s", "t", "m":
+		default:
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, id.Fields[k])
+	}
+	return streamIDPrefix + strings.Join(items, ",")
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"reflect"
+	"testing"
+)
+
+var parseStreamIDTests = []struct {
+	in  string
+	out StreamID
+	err error
+}{
+	{"", StreamID{}, nil},
+	{"live/stream", StreamID{Raw: "live/stream", Resource: "live/stream"}, nil},
+	{
+		"#!::u=alice,r=live/stream,m=publish",
+		StreamID{
+			Raw: "#!::u=alice,r=live/stream,m=publish", User: "alice", Resource: "live/stream", Mode: ModePublish,
+			Fields: map[string]string{"u": "alice", "r": "live/stream", "m": "publish"},
+		},
+		nil,
+	},
+	{
+		"#!::r=a,h=example.com,s=123,t=stream,x-token=k=v",
+		StreamID{
+			Raw: "#!::r=a,h=example.com,s=123,t=stream,x-token=k=v", Resource: "a", Host: "example.com", Session: "123", Type: "stream",
+			Fields: map[string]string{"r": "a", "h": "example.com", "s": "123", "t": "stream", "x-token": "k=v"},
+		},
+		nil,
+	},
+	{"#!::r=a,broken", StreamID{Raw: "#!::r=a,broken"}, errMalformedStreamID},
+	{"#!::=a", StreamID{Raw: "#!::=a"}, errMalformedStreamID},
+}
+
+func TestParseStreamID(t *testing.T) {
+	for _, tt := range parseStreamIDTests {
+		id, err := ParseStreamID(tt.in)
+		if err != tt.err {
+			t.Errorf("ParseStreamID(%q) error = %v; want %v", tt.in, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(id, tt.out) {
+			t.Errorf("ParseStreamID(%q) = %+v; want %+v", tt.in, id, tt.out)
+		}
+	}
+}
+
+func TestStreamIDString(t *testing.T) {
+	in := "#!::m=publish,z=1,r=live,u=bob,a=2"
+	id, err := ParseStreamID(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := id.String(), "#!::u=bob,r=live,m=publish,a=2,z=1"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+	if id.RequestMode() != ModePublish {
+		t.Errorf("got mode %q; want %q", id.RequestMode(), ModePublish)
+	}
+	if mode := (StreamID{}).RequestMode(); mode != ModeRequest {
+		t.Errorf("got default mode %q; want %q", mode, ModeRequest)
+	}
+}
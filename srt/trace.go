@@ -0,0 +1,50 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/openfresh/gosrt/srt/srttrace"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+// handshakeInfo reads the outcome of the handshake of fd.
+func handshakeInfo(fd *netFD) srttrace.HandshakeInfo {
+	s := fd.pfd.Sysfd
+	info := srttrace.HandshakeInfo{LocalAddr: fd.laddr, RemoteAddr: fd.raddr}
+	info.StreamID, _ = srtapi.GetsockflagString(s, srtapi.OptionStreamid)
+	if v, err := getsockoptIntFunc(s, 0, srtapi.OptionPeerversion); err == nil && v != 0 {
+		info.PeerVersion = fmt.Sprintf("%d.%d.%d", v>>16, v>>8&0xff, v&0xff)
+	}
+	if ms, err := getsockoptIntFunc(s, 0, srtapi.OptionRcvlatency); err == nil {
+		info.Latency = time.Duration(ms) * time.Millisecond
+	}
+	if ms, err := getsockoptIntFunc(s, 0, srtapi.OptionPeerlatency); err == nil {
+		info.PeerLatency = time.Duration(ms) * time.Millisecond
+	}
+	if km, err := getsockoptIntFunc(s, 0, srtapi.OptionKmstate); err == nil && km == srtapi.KmStateSecured {
+		info.Encrypted = true
+		info.KeyLength, _ = getsockoptIntFunc(s, 0, srtapi.OptionPbkeylen)
+	}
+	return info
+}
+
+// traceListenCallback returns callback wrapped to report its decisions
+// to trace, or callback itself when there is nothing to report.
+func traceListenCallback(trace *srttrace.ServerTrace, callback srtapi.SrtListenCallbackFunc) srtapi.SrtListenCallbackFunc {
+	if trace == nil || trace.ListenCallback == nil {
+		return callback
+	}
+	return func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		ret := 0
+		if callback != nil {
+			ret = callback(ns, hsversion, peeraddr, streamid)
+		}
+		trace.ListenCallback(sockaddrToSRT(peeraddr), streamid, ret >= 0)
+		return ret
+	}
+}
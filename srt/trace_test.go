@@ -0,0 +1,135 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package srt
+
+import (
+	"context"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/openfresh/gosrt/internal/poll"
+	"github.com/openfresh/gosrt/srt/srttrace"
+	"github.com/openfresh/gosrt/srtapi"
+)
+
+func TestTraceListenCallback(t *testing.T) {
+	if cb := traceListenCallback(nil, nil); cb != nil {
+		t.Error("callback installed without a trace")
+	}
+	type decision struct {
+		peer     string
+		streamID string
+		accepted bool
+	}
+	var got []decision
+	trace := &srttrace.ServerTrace{ListenCallback: func(peer net.Addr, streamID string, accepted bool) {
+		got = append(got, decision{peer.String(), streamID, accepted})
+	}}
+	cb := traceListenCallback(trace, func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int {
+		if streamid == "bad" {
+			return -1
+		}
+		return 0
+	})
+	peer := &syscall.SockaddrInet4{Port: 5000, Addr: [4]byte{127, 0, 0, 1}}
+	cb(1, 5, peer, "good")
+	cb(1, 5, peer, "bad")
+	want := []decision{{"127.0.0.1:5000", "good", true}, {"127.0.0.1:5000", "bad", false}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v; want %v", got, want)
+	}
+}
+
+func TestHandshakeInfo(t *testing.T) {
+	orig := getsockoptIntFunc
+	defer func() { getsockoptIntFunc = orig }()
+	getsockoptIntFunc = func(fd, level, opt int) (int, error) {
+		switch opt {
+		case srtapi.OptionPeerversion:
+			return 0x010402, nil
+		case srtapi.OptionRcvlatency:
+			return 120, nil
+		case srtapi.OptionPeerlatency:
+			return 200, nil
+		case srtapi.OptionKmstate:
+			return srtapi.KmStateSecured, nil
+		case srtapi.OptionPbkeylen:
+			return 16, nil
+		}
+		return 0, srtapi.EINVPARAM
+	}
+	info := handshakeInfo(&netFD{pfd: poll.FD{Sysfd: 1}})
+	if info.PeerVersion != "1.4.2" || info.Latency != 120*time.Millisecond || info.PeerLatency != 200*time.Millisecond || !info.Encrypted || info.KeyLength != 16 {
+		t.Errorf("got %+v", info)
+	}
+}
+
+func TestTraceConnection(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(e string) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	}
+	closed := make(chan struct{}, 2)
+	sctx := srttrace.WithServerTrace(context.Background(), &srttrace.ServerTrace{
+		ListenCallback: func(peer net.Addr, streamID string, accepted bool) { record("callback") },
+		Accepted:       func(info srttrace.HandshakeInfo) { record("accepted") },
+		Closed:         func(info srttrace.CloseInfo) { record("server closed"); closed <- struct{}{} },
+	})
+	ln, err := newLocalListenerContext(sctx, "srt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	var info srttrace.HandshakeInfo
+	cctx := srttrace.WithClientTrace(context.Background(), &srttrace.ClientTrace{
+		ResolveStart:  func(network, address string) { record("resolve start") },
+		ResolveDone:   func(addrs []net.Addr, err error) { record("resolve done") },
+		ConnectStart:  func(network, addr string) { record("connect start") },
+		ConnectDone:   func(network, addr string, err error) { record("connect done") },
+		HandshakeDone: func(i srttrace.HandshakeInfo) { info = i; record("handshake done") },
+		Closed:        func(info srttrace.CloseInfo) { record("client closed"); closed <- struct{}{} },
+	})
+	var d Dialer
+	c, err := d.DialContext(cctx, ln.Addr().Network(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.RemoteAddr == nil || info.PeerVersion == "" {
+		t.Errorf("incomplete handshake info %+v", info)
+	}
+	c.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-closed:
+		case <-time.After(someTimeout):
+			t.Fatal("close not traced")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	seen := make(map[string]bool)
+	for _, e := range events {
+		seen[e] = true
+	}
+	for _, e := range []string{"resolve start", "resolve done", "connect start", "connect done", "handshake done", "client closed", "callback", "accepted", "server closed"} {
+		if !seen[e] {
+			t.Errorf("no %q event in %q", e, events)
+		}
+	}
+}
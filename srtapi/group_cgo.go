@@ -0,0 +1,137 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+// +build srtgroups
+
+package srtapi
+
+/*
+#cgo CFLAGS: -DENABLE_EXPERIMENTAL_BONDING
+#cgo LDFLAGS: -lsrt
+
+#include <srt/srt.h>
+*/
+import "C"
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// GroupMask is the bit set in the ids of groups.
+const GroupMask = C.SRTGROUP_MASK
+
+// SRT group type
+const (
+	GroupBroadcast = C.SRT_GTYPE_BROADCAST
+	GroupBackup    = C.SRT_GTYPE_BACKUP
+)
+
+// SRT group member status
+const (
+	MemberPending = C.SRT_GST_PENDING
+	MemberIdle    = C.SRT_GST_IDLE
+	MemberRunning = C.SRT_GST_RUNNING
+	MemberBroken  = C.SRT_GST_BROKEN
+)
+
+// SRT group options
+const (
+	OptionGroupconnect   = C.SRTO_GROUPCONNECT
+	OptionGroupstabtimeo = C.SRTO_GROUPSTABTIMEO
+	OptionGrouptype      = C.SRTO_GROUPTYPE
+)
+
+// GroupEndpoint is a member connection to make with ConnectGroup.
+type GroupEndpoint struct {
+	Source syscall.Sockaddr // may be nil
+	Peer   syscall.Sockaddr
+	Weight int
+}
+
+// GroupMemberData is the state of a member of a group.
+type GroupMemberData struct {
+	ID          int
+	Peer        syscall.Sockaddr
+	State       int
+	MemberState int
+	Weight      int
+	Result      int
+}
+
+// CreateGroup call srt_create_group
+func CreateGroup(typ int) (fd int, err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	fd = int(C.srt_create_group(C.SRT_GROUP_TYPE(typ)))
+	if fd == APIError {
+		err = getLastError()
+	}
+	return
+}
+
+// GroupOf call srt_groupof
+func GroupOf(fd int) int {
+	return int(C.srt_groupof(C.SRTSOCKET(fd)))
+}
+
+// ConnectGroup call srt_connect_group. It returns the id of the member
+// socket that connected first when the group is in blocking mode.
+func ConnectGroup(group int, endpoints []GroupEndpoint) (fd int, err error) {
+	if len(endpoints) == 0 {
+		return 0, EINVPARAM
+	}
+	configs := make([]C.SRT_SOCKGROUPCONFIG, len(endpoints))
+	for i, e := range endpoints {
+		peer, n, err := sockaddr(e.Peer)
+		if err != nil {
+			return 0, err
+		}
+		var src unsafe.Pointer
+		if e.Source != nil {
+			if src, _, err = sockaddr(e.Source); err != nil {
+				return 0, err
+			}
+		}
+		configs[i] = C.srt_prepare_endpoint((*C.struct_sockaddr)(src), (*C.struct_sockaddr)(peer), C.int(n))
+		configs[i].weight = C.uint16_t(e.Weight)
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	fd = int(C.srt_connect_group(C.SRTSOCKET(group), &configs[0], C.int(len(configs))))
+	if fd == APIError {
+		err = getLastError()
+	}
+	return
+}
+
+// GroupData call srt_group_data
+func GroupData(group int) ([]GroupMemberData, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	data := make([]C.SRT_SOCKGROUPDATA, 8)
+	for {
+		n := C.size_t(len(data))
+		if C.srt_group_data(C.SRTSOCKET(group), &data[0], &n) == APIError {
+			if int(n) > len(data) {
+				data = make([]C.SRT_SOCKGROUPDATA, n)
+				continue
+			}
+			return nil, getLastError()
+		}
+		members := make([]GroupMemberData, n)
+		for i := range members {
+			d := &data[i]
+			peer, _ := anyToSockaddr((*syscall.RawSockaddrAny)(unsafe.Pointer(&d.peeraddr)))
+			members[i] = GroupMemberData{
+				ID:          int(d.id),
+				Peer:        peer,
+				State:       int(d.sockstate),
+				MemberState: int(d.memberstate),
+				Weight:      int(d.weight),
+				Result:      int(d.result),
+			}
+		}
+		return members, nil
+	}
+}
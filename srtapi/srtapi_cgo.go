@@ -8,6 +8,11 @@ package srtapi
 
 #include <srt/srt.h>
 
+// srt_setrejectreason and SRTO_BINDTODEVICE appeared in libsrt 1.4.2.
+#if defined(SRT_VERSION_VALUE) && SRT_VERSION_VALUE < 0x010402
+#error "gosrt needs libsrt 1.4.2 or later"
+#endif
+
 int SrtListenCallback_cgo(void* opaq, SRTSOCKET ns, int hsversion,
     const struct sockaddr* peeraddr, const char* streamid);
 */
@@ -17,6 +22,7 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -24,7 +30,12 @@ import (
 // SrtListenCallbackFunc listen callback function type
 type SrtListenCallbackFunc func(ns int, hsversion int, peeraddr syscall.Sockaddr, streamid string) int
 
-var listenCallbackMap map[string]SrtListenCallbackFunc
+var (
+	// listenCallbackMu guards listenCallbackMap, which the handshake
+	// threads of libsrt read concurrently with its updates.
+	listenCallbackMu  sync.RWMutex
+	listenCallbackMap map[string]SrtListenCallbackFunc
+)
 
 // Startup call srt_startup
 func Startup() (err error) {
@@ -34,7 +45,9 @@ func Startup() (err error) {
 	if stat == APIError {
 		err = getLastError()
 	}
+	listenCallbackMu.Lock()
 	listenCallbackMap = map[string]SrtListenCallbackFunc{}
+	listenCallbackMu.Unlock()
 	return
 }
 
@@ -46,7 +59,9 @@ func Cleanup() (err error) {
 	if stat == APIError {
 		err = getLastError()
 	}
+	listenCallbackMu.Lock()
 	listenCallbackMap = nil
+	listenCallbackMu.Unlock()
 	return
 }
 
@@ -273,7 +288,9 @@ func Listen(s int, n int) (err error) {
 //export srtListenCallback
 func srtListenCallback(opaq unsafe.Pointer, ns C.SRTSOCKET, hsversion int, peeraddr *C.struct_sockaddr, streamid *C.char) int {
 	key := C.GoString((*C.char)(*(*unsafe.Pointer)(opaq)))
+	listenCallbackMu.RLock()
 	callback, ok := listenCallbackMap[key]
+	listenCallbackMu.RUnlock()
 	if !ok {
 		println("srtListenCallback: not found callback with key ", key)
 		return -1
@@ -291,7 +308,9 @@ func ListenCallback(s int, callback SrtListenCallbackFunc) (err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 	key := strconv.Itoa(s)
+	listenCallbackMu.Lock()
 	listenCallbackMap[key] = callback
+	listenCallbackMu.Unlock()
 	cKey := C.CString(key)
 	stat := C.srt_listen_callback(C.SRTSOCKET(s), (*C.srt_listen_callback_fn)(C.SrtListenCallback_cgo), unsafe.Pointer(&cKey))
 	if stat == APIError {
@@ -305,7 +324,9 @@ func Close(fd int) (err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 	key := strconv.Itoa(fd)
+	listenCallbackMu.Lock()
 	delete(listenCallbackMap, key)
+	listenCallbackMu.Unlock()
 	stat := C.srt_close(C.SRTSOCKET(fd))
 	if stat == APIError {
 		err = getLastError()
@@ -313,6 +334,98 @@ func Close(fd int) (err error) {
 	return
 }
 
+// GetSockState call srt_getsockstate
+func GetSockState(fd int) int {
+	return int(C.srt_getsockstate(C.SRTSOCKET(fd)))
+}
+
+// GetSndBuffer call srt_getsndbuffer. It returns the number of packets
+// and bytes in the sender buffer and the time span they cover.
+func GetSndBuffer(fd int) (blocks, bytes int, ms int, err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var b, n C.size_t
+	r0 := C.srt_getsndbuffer(C.SRTSOCKET(fd), &b, &n)
+	if r0 == APIError {
+		err = getLastError()
+		return
+	}
+	return int(b), int(n), int(r0), nil
+}
+
+// Bstats are the counters of a socket read by GetBstats, named after
+// the fields of CBytePerfMon. The totals count from the creation of the
+// socket; the other values are current.
+type Bstats struct {
+	MsRTT         float64 // smoothed round trip time
+	MbpsBandwidth float64 // estimated link bandwidth
+
+	PktSentTotal    int64
+	PktRecvTotal    int64
+	PktSndLossTotal int64
+	PktRcvLossTotal int64
+	PktSndDropTotal int64
+	PktRcvDropTotal int64
+
+	PktSndBuf  int
+	ByteSndBuf int
+	MsSndBuf   int
+	PktRcvBuf  int
+	ByteRcvBuf int
+	MsRcvBuf   int
+
+	PktSndFilterExtraTotal  int
+	PktRcvFilterExtraTotal  int
+	PktRcvFilterSupplyTotal int
+	PktRcvFilterLossTotal   int
+}
+
+// GetBstats call srt_bstats without clearing the interval counters
+func GetBstats(fd int) (s Bstats, err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var mon C.struct_CBytePerfMon
+	if C.srt_bstats(C.SRTSOCKET(fd), &mon, 0) == APIError {
+		err = getLastError()
+		return
+	}
+	return Bstats{
+		MsRTT:                   float64(mon.msRTT),
+		MbpsBandwidth:           float64(mon.mbpsBandwidth),
+		PktSentTotal:            int64(mon.pktSentTotal),
+		PktRecvTotal:            int64(mon.pktRecvTotal),
+		PktSndLossTotal:         int64(mon.pktSndLossTotal),
+		PktRcvLossTotal:         int64(mon.pktRcvLossTotal),
+		PktSndDropTotal:         int64(mon.pktSndDropTotal),
+		PktRcvDropTotal:         int64(mon.pktRcvDropTotal),
+		PktSndBuf:               int(mon.pktSndBuf),
+		ByteSndBuf:              int(mon.byteSndBuf),
+		MsSndBuf:                int(mon.msSndBuf),
+		PktRcvBuf:               int(mon.pktRcvBuf),
+		ByteRcvBuf:              int(mon.byteRcvBuf),
+		MsRcvBuf:                int(mon.msRcvBuf),
+		PktSndFilterExtraTotal:  int(mon.pktSndFilterExtraTotal),
+		PktRcvFilterExtraTotal:  int(mon.pktRcvFilterExtraTotal),
+		PktRcvFilterSupplyTotal: int(mon.pktRcvFilterSupplyTotal),
+		PktRcvFilterLossTotal:   int(mon.pktRcvFilterLossTotal),
+	}, nil
+}
+
+// GetRejectReason call srt_getrejectreason
+func GetRejectReason(fd int) int {
+	return int(C.srt_getrejectreason(C.SRTSOCKET(fd)))
+}
+
+// SetRejectReason call srt_setrejectreason
+func SetRejectReason(fd int, reason int) (err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if C.srt_setrejectreason(C.SRTSOCKET(fd), C.int(reason)) == APIError {
+		err = getLastError()
+	}
+	return
+}
+
 func read(fd int, p []byte) (n int, err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
@@ -142,6 +142,11 @@ func SetsockoptBool(fd, level, opt int, value bool) (err error) {
 	return setsockopt(fd, level, opt, unsafe.Pointer(&n), 4)
 }
 
+// SetsockoptLinger call srt_setsockopt
+func SetsockoptLinger(fd, level, opt int, l *syscall.Linger) (err error) {
+	return setsockopt(fd, level, opt, unsafe.Pointer(l), unsafe.Sizeof(*l))
+}
+
 // SetsockflagByte call srt_setsockopt
 func SetsockflagByte(fd, opt int, value byte) (err error) {
 	return setsockflag(fd, opt, unsafe.Pointer(&value), 1)
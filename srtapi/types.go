@@ -101,6 +101,7 @@ const (
 	OptionIpv60only     = C.SRTO_IPV6ONLY
 	OptionPeeridletimeo = C.SRTO_PEERIDLETIMEO
 	OptionPacketfilter = C.SRTO_PACKETFILTER
+	OptionBindtodevice = C.SRTO_BINDTODEVICE
 )
 
 // SRT trans type
@@ -110,6 +111,15 @@ const (
 	TypeInvalid = C.SRTT_INVALID
 )
 
+// SRT key material state
+const (
+	KmStateUnsecured = C.SRT_KM_S_UNSECURED
+	KmStateSecuring  = C.SRT_KM_S_SECURING
+	KmStateSecured   = C.SRT_KM_S_SECURED
+	KmStateNosecret  = C.SRT_KM_S_NOSECRET
+	KmStateBadsecret = C.SRT_KM_S_BADSECRET
+)
+
 // SRT log level
 const (
 	LogEmerg   = 0
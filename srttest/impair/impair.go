@@ -0,0 +1,555 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+/*
+Package impair provides a UDP proxy that degrades the traffic passing
+through it, for testing SRT behaviour on bad links.
+
+A Proxy listens on a local UDP address and relays every datagram it
+receives to a target address, and the replies back to the sender. Each
+direction applies its own Impairment: random and burst loss, fixed delay,
+jitter, reordering, duplication and a bandwidth cap. All random decisions
+are drawn from a generator seeded with Config.Seed, so the same seed and
+the same packet sequence always yield the same drop, duplicate and reorder
+pattern.
+
+A caller connects to the proxy instead of the listener:
+
+	p, err := impair.Listen("127.0.0.1:0", ln.Addr().String(), impair.Config{
+		Seed:     1,
+		Upstream: impair.Impairment{Loss: 0.05, Delay: 20 * time.Millisecond},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Close()
+	c, err := srt.Dial("srt", p.Addr().String())
+*/
+package impair
+
+import (
+	"container/heap"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxDatagram is the largest UDP payload the proxy relays.
+const maxDatagram = 65535
+
+// defaultQueueLimit is the amount of data a rate limited direction may
+// hold back before it starts dropping, when Impairment.QueueLimit is zero.
+const defaultQueueLimit = 1 << 20
+
+// errClosed is returned by operations on a closed Proxy.
+var errClosed = errors.New("impair: proxy closed")
+
+// An Impairment describes how one direction of a Proxy degrades traffic.
+//
+// The zero value relays packets unchanged.
+type Impairment struct {
+	// Loss is the probability, from 0 to 1, that a packet is dropped.
+	Loss float64
+
+	// BurstLoss is the probability, from 0 to 1, that a packet starts
+	// a burst of BurstLength consecutive losses.
+	BurstLoss float64
+
+	// BurstLength is the number of packets dropped by a burst,
+	// including the one that started it. Zero means 1.
+	BurstLength int
+
+	// Delay is added to every packet.
+	Delay time.Duration
+
+	// Jitter adds a uniformly distributed delay between 0 and Jitter
+	// to every packet. Jitter alone never reorders packets; a packet
+	// that would overtake its predecessor is held back behind it.
+	Jitter time.Duration
+
+	// Reorder is the probability, from 0 to 1, that a packet is held
+	// back by ReorderDelay so that the packets sent after it overtake
+	// it.
+	Reorder float64
+
+	// ReorderDelay is the extra delay of a reordered packet.
+	// Zero means 10ms.
+	ReorderDelay time.Duration
+
+	// Duplicate is the probability, from 0 to 1, that a packet is
+	// delivered twice.
+	Duplicate float64
+
+	// Rate caps the throughput in bits per second. Packets exceeding
+	// the rate are queued. Zero means no cap.
+	Rate int64
+
+	// QueueLimit is the number of bytes a rate limited direction may
+	// queue before dropping new packets. Zero means 1MB.
+	QueueLimit int
+}
+
+func (im *Impairment) burstLength() int {
+	if im.BurstLength > 0 {
+		return im.BurstLength
+	}
+	return 1
+}
+
+func (im *Impairment) reorderDelay() time.Duration {
+	if im.ReorderDelay > 0 {
+		return im.ReorderDelay
+	}
+	return 10 * time.Millisecond
+}
+
+func (im *Impairment) queueLimit() int {
+	if im.QueueLimit > 0 {
+		return im.QueueLimit
+	}
+	return defaultQueueLimit
+}
+
+// Config contains the settings of a Proxy.
+type Config struct {
+	// Seed initializes the random generators of both directions.
+	Seed int64
+
+	// Upstream is applied to packets from the caller to the target.
+	Upstream Impairment
+
+	// Downstream is applied to packets from the target back to the
+	// caller.
+	Downstream Impairment
+}
+
+// DirectionStats reports what one direction of a Proxy did.
+type DirectionStats struct {
+	// Packets and Bytes count the datagrams received.
+	Packets int64
+	Bytes   int64
+
+	// Delivered counts the datagrams sent out, including duplicates.
+	Delivered int64
+
+	// Lost counts the packets dropped by random loss.
+	Lost int64
+
+	// BurstLost counts the packets dropped by burst loss.
+	BurstLost int64
+
+	// Overflowed counts the packets dropped because the rate limit
+	// queue was full.
+	Overflowed int64
+
+	// Duplicated counts the packets delivered twice.
+	Duplicated int64
+
+	// Reordered counts the packets held back to be overtaken.
+	Reordered int64
+}
+
+// Dropped returns the total number of packets the direction dropped.
+func (s DirectionStats) Dropped() int64 {
+	return s.Lost + s.BurstLost + s.Overflowed
+}
+
+// Stats reports what a Proxy did in each direction.
+type Stats struct {
+	Upstream   DirectionStats
+	Downstream DirectionStats
+}
+
+// A Proxy relays UDP datagrams between callers and a target address
+// while impairing them.
+type Proxy struct {
+	conn   *net.UDPConn
+	target *net.UDPAddr
+
+	up, down *pipe
+
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// session is the relay state of a single caller address.
+type session struct {
+	client *net.UDPAddr
+	conn   *net.UDPConn
+}
+
+// Listen starts a Proxy on the UDP address laddr that relays to the UDP
+// address target.
+func Listen(laddr, target string, cfg Config) (*Proxy, error) {
+	la, err := net.ResolveUDPAddr("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	ta, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", la)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{
+		conn:     conn,
+		target:   ta,
+		up:       newPipe(cfg.Upstream, cfg.Seed),
+		down:     newPipe(cfg.Downstream, cfg.Seed+1),
+		sessions: make(map[string]*session),
+		closed:   make(chan struct{}),
+	}
+	p.wg.Add(3)
+	go p.up.run(&p.wg, p.closed)
+	go p.down.run(&p.wg, p.closed)
+	go p.serve()
+	return p, nil
+}
+
+// Addr returns the address callers should connect to.
+func (p *Proxy) Addr() net.Addr {
+	return p.conn.LocalAddr()
+}
+
+// Target returns the address the proxy relays to.
+func (p *Proxy) Target() net.Addr {
+	return p.target
+}
+
+// SetUpstream replaces the impairment applied to packets from the
+// caller to the target. Packets already scheduled are not affected.
+func (p *Proxy) SetUpstream(im Impairment) {
+	p.up.setImpairment(im)
+}
+
+// SetDownstream replaces the impairment applied to packets from the
+// target to the caller. Packets already scheduled are not affected.
+func (p *Proxy) SetDownstream(im Impairment) {
+	p.down.setImpairment(im)
+}
+
+// Stats returns a snapshot of the proxy counters.
+func (p *Proxy) Stats() Stats {
+	return Stats{Upstream: p.up.snapshot(), Downstream: p.down.snapshot()}
+}
+
+// Close stops the proxy and discards packets not delivered yet.
+func (p *Proxy) Close() error {
+	err := errClosed
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		err = p.conn.Close()
+		p.mu.Lock()
+		for _, s := range p.sessions {
+			s.conn.Close()
+		}
+		p.mu.Unlock()
+		p.wg.Wait()
+	})
+	return err
+}
+
+func (p *Proxy) serve() {
+	defer p.wg.Done()
+	buf := make([]byte, maxDatagram)
+	for {
+		n, from, err := p.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-p.closed:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		s, err := p.session(from)
+		if err != nil {
+			continue
+		}
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		p.up.push(b, func(b []byte) { s.conn.Write(b) })
+	}
+}
+
+// session returns the relay for the caller at from, creating it on the
+// first packet.
+func (p *Proxy) session(from *net.UDPAddr) (*session, error) {
+	key := from.String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.sessions[key]; ok {
+		return s, nil
+	}
+	select {
+	case <-p.closed:
+		return nil, errClosed
+	default:
+	}
+	conn, err := net.DialUDP("udp", nil, p.target)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{client: from, conn: conn}
+	p.sessions[key] = s
+	p.wg.Add(1)
+	go p.reply(s)
+	return s, nil
+}
+
+// reply relays the datagrams the target sends to a session back to its
+// caller.
+func (p *Proxy) reply(s *session) {
+	defer p.wg.Done()
+	buf := make([]byte, maxDatagram)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			select {
+			case <-p.closed:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			// ICMP port unreachable surfaces as a read error on a
+			// connected UDP socket; the target may come up later.
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				continue
+			}
+			return
+		}
+		b := make([]byte, n)
+		copy(b, buf[:n])
+		p.down.push(b, func(b []byte) { p.conn.WriteToUDP(b, s.client) })
+	}
+}
+
+// packet is a datagram scheduled for delivery.
+type packet struct {
+	b     []byte
+	at    time.Time
+	seq   uint64 // breaks ties so that equal release times keep order
+	write func([]byte)
+}
+
+type packetQueue []*packet
+
+func (q packetQueue) Len() int { return len(q) }
+func (q packetQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q packetQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *packetQueue) Push(x interface{}) { *q = append(*q, x.(*packet)) }
+func (q *packetQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return x
+}
+
+// pipe impairs and schedules the packets of one direction.
+type pipe struct {
+	stats DirectionStats // first for 64-bit atomic alignment
+
+	mu        sync.Mutex
+	im        Impairment
+	rnd       *rand.Rand
+	burstLeft int
+	seq       uint64
+	last      time.Time // release time of the last in-order packet
+	linkFree  time.Time // when the rate limited link is idle again
+	queued    int       // bytes waiting for the rate limited link
+	queue     packetQueue
+	wake      chan struct{}
+}
+
+func newPipe(im Impairment, seed int64) *pipe {
+	return &pipe{
+		im:   im,
+		rnd:  rand.New(rand.NewSource(seed)),
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (pp *pipe) setImpairment(im Impairment) {
+	pp.mu.Lock()
+	pp.im = im
+	pp.burstLeft = 0
+	pp.mu.Unlock()
+}
+
+func (pp *pipe) snapshot() DirectionStats {
+	return DirectionStats{
+		Packets:    atomic.LoadInt64(&pp.stats.Packets),
+		Bytes:      atomic.LoadInt64(&pp.stats.Bytes),
+		Delivered:  atomic.LoadInt64(&pp.stats.Delivered),
+		Lost:       atomic.LoadInt64(&pp.stats.Lost),
+		BurstLost:  atomic.LoadInt64(&pp.stats.BurstLost),
+		Overflowed: atomic.LoadInt64(&pp.stats.Overflowed),
+		Duplicated: atomic.LoadInt64(&pp.stats.Duplicated),
+		Reordered:  atomic.LoadInt64(&pp.stats.Reordered),
+	}
+}
+
+// push applies the impairment to b and schedules its delivery through
+// write.
+func (pp *pipe) push(b []byte, write func([]byte)) {
+	atomic.AddInt64(&pp.stats.Packets, 1)
+	atomic.AddInt64(&pp.stats.Bytes, int64(len(b)))
+
+	pp.mu.Lock()
+	im := pp.im
+	// Every decision draws from the generator whether or not its
+	// probability is set, so that enabling one impairment does not
+	// shift the pattern of the others.
+	lossDraw := pp.rnd.Float64()
+	burstDraw := pp.rnd.Float64()
+	dupDraw := pp.rnd.Float64()
+	reorderDraw := pp.rnd.Float64()
+	jitterDraw := pp.rnd.Float64()
+
+	if pp.burstLeft > 0 {
+		pp.burstLeft--
+		pp.mu.Unlock()
+		atomic.AddInt64(&pp.stats.BurstLost, 1)
+		return
+	}
+	if burstDraw < im.BurstLoss {
+		pp.burstLeft = im.burstLength() - 1
+		pp.mu.Unlock()
+		atomic.AddInt64(&pp.stats.BurstLost, 1)
+		return
+	}
+	if lossDraw < im.Loss {
+		pp.mu.Unlock()
+		atomic.AddInt64(&pp.stats.Lost, 1)
+		return
+	}
+
+	copies := 1
+	if dupDraw < im.Duplicate {
+		copies = 2
+	}
+	size := len(b) * copies
+	if im.Rate > 0 && pp.queued+size > im.queueLimit() {
+		pp.mu.Unlock()
+		atomic.AddInt64(&pp.stats.Overflowed, 1)
+		return
+	}
+
+	now := time.Now()
+	at := now.Add(im.Delay)
+	if im.Jitter > 0 {
+		at = at.Add(time.Duration(jitterDraw * float64(im.Jitter)))
+	}
+	reordered := reorderDraw < im.Reorder
+	if !reordered && at.Before(pp.last) {
+		at = pp.last
+	}
+	rated := false
+	if im.Rate > 0 {
+		if pp.linkFree.Before(now) {
+			pp.linkFree = now
+		}
+		pp.linkFree = pp.linkFree.Add(time.Duration(int64(size) * 8 * int64(time.Second) / im.Rate))
+		if at.Before(pp.linkFree) {
+			at = pp.linkFree
+		}
+		pp.queued += size
+		rated = true
+	}
+	if reordered {
+		at = at.Add(im.reorderDelay())
+	} else {
+		pp.last = at
+	}
+	for i := 0; i < copies; i++ {
+		pp.seq++
+		heap.Push(&pp.queue, &packet{b: b, at: at, seq: pp.seq, write: pp.deliver(write, len(b), rated)})
+	}
+	pp.mu.Unlock()
+
+	if copies > 1 {
+		atomic.AddInt64(&pp.stats.Duplicated, 1)
+	}
+	if reordered {
+		atomic.AddInt64(&pp.stats.Reordered, 1)
+	}
+	select {
+	case pp.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliver wraps write to keep the counters in step with the packets that
+// actually leave the pipe.
+func (pp *pipe) deliver(write func([]byte), size int, rated bool) func([]byte) {
+	return func(b []byte) {
+		if rated {
+			pp.mu.Lock()
+			pp.queued -= size
+			pp.mu.Unlock()
+		}
+		atomic.AddInt64(&pp.stats.Delivered, 1)
+		write(b)
+	}
+}
+
+// run delivers the scheduled packets at their release time until closed
+// is closed.
+func (pp *pipe) run(wg *sync.WaitGroup, closed <-chan struct{}) {
+	defer wg.Done()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		pp.mu.Lock()
+		var due []*packet
+		now := time.Now()
+		for len(pp.queue) > 0 && !pp.queue[0].at.After(now) {
+			due = append(due, heap.Pop(&pp.queue).(*packet))
+		}
+		wait := time.Hour
+		if len(pp.queue) > 0 {
+			wait = pp.queue[0].at.Sub(now)
+		}
+		pp.mu.Unlock()
+
+		for _, p := range due {
+			p.write(p.b)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-closed:
+			return
+		case <-pp.wake:
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2020 CyberAgent, Inc. All rights reserved.
+// https://github.com/openfresh/gosrt
+
+package impair
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newSink returns a UDP socket on the loopback interface.
+func newSink(t testing.TB) *net.UDPConn {
+	c, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// collect reads sequence numbers from c until nothing arrives for idle.
+func collect(c *net.UDPConn, idle time.Duration) []uint32 {
+	var seqs []uint32
+	b := make([]byte, maxDatagram)
+	for {
+		c.SetReadDeadline(time.Now().Add(idle))
+		n, err := c.Read(b)
+		if err != nil {
+			return seqs
+		}
+		if n >= 4 {
+			seqs = append(seqs, binary.BigEndian.Uint32(b))
+		}
+	}
+}
+
+// send writes count numbered datagrams of size bytes to addr.
+func send(t testing.TB, addr net.Addr, count, size int) *net.UDPConn {
+	c, err := net.DialUDP("udp4", nil, addr.(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, size)
+	for i := 0; i < count; i++ {
+		binary.BigEndian.PutUint32(b, uint32(i))
+		if _, err := c.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		if i%50 == 49 {
+			// Keep the loopback socket buffers from overflowing.
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return c
+}
+
+// relay runs count packets through a proxy configured with cfg and
+// returns the sequence numbers that reached the target.
+func relay(t *testing.T, cfg Config, count, size int) ([]uint32, Stats) {
+	sink := newSink(t)
+	defer sink.Close()
+	p, err := Listen("127.0.0.1:0", sink.LocalAddr().String(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	done := make(chan []uint32)
+	go func() { done <- collect(sink, 200*time.Millisecond) }()
+	c := send(t, p.Addr(), count, size)
+	defer c.Close()
+	seqs := <-done
+	return seqs, p.Stats()
+}
+
+func TestPassThrough(t *testing.T) {
+	seqs, st := relay(t, Config{}, 200, 100)
+	if len(seqs) != 200 {
+		t.Fatalf("got %d packets; want 200", len(seqs))
+	}
+	for i, seq := range seqs {
+		if seq != uint32(i) {
+			t.Fatalf("packet %d has sequence %d", i, seq)
+		}
+	}
+	if st.Upstream.Packets != 200 || st.Upstream.Delivered != 200 || st.Upstream.Dropped() != 0 {
+		t.Errorf("unexpected stats %+v", st.Upstream)
+	}
+}
+
+func TestLossIsDeterministic(t *testing.T) {
+	cfg := Config{Seed: 42, Upstream: Impairment{Loss: 0.2}}
+	first, st := relay(t, cfg, 500, 100)
+	second, _ := relay(t, cfg, 500, 100)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("same seed produced different loss patterns")
+	}
+	if st.Upstream.Lost == 0 || st.Upstream.Lost > 200 {
+		t.Errorf("lost %d of 500 packets at 20%% loss", st.Upstream.Lost)
+	}
+	if got := int64(len(first)); got != st.Upstream.Delivered || got != 500-st.Upstream.Lost {
+		t.Errorf("received %d packets; stats %+v", got, st.Upstream)
+	}
+
+	other, _ := relay(t, Config{Seed: 43, Upstream: Impairment{Loss: 0.2}}, 500, 100)
+	if reflect.DeepEqual(first, other) {
+		t.Error("different seeds produced the same loss pattern")
+	}
+}
+
+func TestBurstLoss(t *testing.T) {
+	cfg := Config{Seed: 7, Upstream: Impairment{BurstLoss: 0.05, BurstLength: 5}}
+	seqs, st := relay(t, cfg, 500, 100)
+	if st.Upstream.BurstLost == 0 {
+		t.Fatal("no burst loss")
+	}
+	// A burst still running at the end may be cut short, so only
+	// the gaps in front of received packets are checked.
+	next := uint32(0)
+	for _, seq := range seqs {
+		if gap := seq - next; gap%5 != 0 {
+			t.Errorf("gap of %d packets before %d", gap, seq)
+		}
+		next = seq + 1
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	seqs, st := relay(t, Config{Seed: 3, Upstream: Impairment{Duplicate: 0.3}}, 300, 100)
+	if st.Upstream.Duplicated == 0 {
+		t.Fatal("no packet was duplicated")
+	}
+	if want := 300 + int(st.Upstream.Duplicated); len(seqs) != want {
+		t.Errorf("got %d packets; want %d", len(seqs), want)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	cfg := Config{Seed: 5, Upstream: Impairment{Reorder: 0.1, ReorderDelay: 20 * time.Millisecond}}
+	seqs, st := relay(t, cfg, 300, 100)
+	if len(seqs) != 300 {
+		t.Fatalf("got %d packets; want 300", len(seqs))
+	}
+	if st.Upstream.Reordered == 0 {
+		t.Fatal("no packet was reordered")
+	}
+	if sort.SliceIsSorted(seqs, func(i, j int) bool { return seqs[i] < seqs[j] }) {
+		t.Error("packets arrived in order")
+	}
+}
+
+func TestJitterKeepsOrder(t *testing.T) {
+	seqs, _ := relay(t, Config{Seed: 9, Upstream: Impairment{Jitter: 5 * time.Millisecond}}, 200, 100)
+	if len(seqs) != 200 {
+		t.Fatalf("got %d packets; want 200", len(seqs))
+	}
+	if !sort.SliceIsSorted(seqs, func(i, j int) bool { return seqs[i] < seqs[j] }) {
+		t.Error("jitter reordered packets")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	echo := newSink(t)
+	defer echo.Close()
+	go func() {
+		b := make([]byte, maxDatagram)
+		for {
+			n, from, err := echo.ReadFromUDP(b)
+			if err != nil {
+				return
+			}
+			echo.WriteToUDP(b[:n], from)
+		}
+	}()
+	p, err := Listen("127.0.0.1:0", echo.LocalAddr().String(), Config{
+		Upstream:   Impairment{Delay: delay},
+		Downstream: Impairment{Delay: delay},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	start := time.Now()
+	c := send(t, p.Addr(), 1, 16)
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 16)
+	if _, err := c.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if rtt := time.Since(start); rtt < 2*delay {
+		t.Errorf("round trip took %v; want at least %v", rtt, 2*delay)
+	}
+	if st := p.Stats(); st.Downstream.Delivered != 1 {
+		t.Errorf("downstream delivered %d packets; want 1", st.Downstream.Delivered)
+	}
+}
+
+func TestRate(t *testing.T) {
+	// 100 packets of 1000 bytes at 8Mbps take 100ms on the wire.
+	start := time.Now()
+	seqs, _ := relay(t, Config{Upstream: Impairment{Rate: 8000000}}, 100, 1000)
+	if len(seqs) != 100 {
+		t.Fatalf("got %d packets; want 100", len(seqs))
+	}
+	// relay waits 200ms of silence after the last packet.
+	if elapsed := time.Since(start) - 200*time.Millisecond; elapsed < 90*time.Millisecond {
+		t.Errorf("transfer took %v; want at least 90ms", elapsed)
+	}
+}
+
+func TestRateQueueLimit(t *testing.T) {
+	cfg := Config{Upstream: Impairment{Rate: 80000, QueueLimit: 10000}}
+	seqs, st := relay(t, cfg, 100, 1000)
+	if st.Upstream.Overflowed == 0 {
+		t.Fatal("no packet overflowed the queue")
+	}
+	if int64(len(seqs)) > st.Upstream.Packets-st.Upstream.Overflowed {
+		t.Errorf("received %d packets; stats %+v", len(seqs), st.Upstream)
+	}
+}
+
+func TestClose(t *testing.T) {
+	sink := newSink(t)
+	defer sink.Close()
+	p, err := Listen("127.0.0.1:0", sink.LocalAddr().String(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := send(t, p.Addr(), 1, 16)
+	defer c.Close()
+	collect(sink, 50*time.Millisecond)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err == nil {
+		t.Error("second Close succeeded")
+	}
+}
+
+func BenchmarkPassThrough(b *testing.B) {
+	sink := newSink(b)
+	defer sink.Close()
+	go collect(sink, time.Second)
+	p, err := Listen("127.0.0.1:0", sink.LocalAddr().String(), Config{Upstream: Impairment{Loss: 0.01}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+	c, err := net.DialUDP("udp4", nil, p.Addr().(*net.UDPAddr))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	buf := make([]byte, 1316)
+	b.SetBytes(int64(len(buf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Write(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}